@@ -0,0 +1,83 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+)
+
+// OfflineGetter returns the Offline of the given name in the namespace of
+// the Offline whose dependencies are walked, nil when there is none.
+type OfflineGetter func(name string) (*Offline, error)
+
+// HasDependencyCycle walks the dependencies of off looking for off itself.
+// Dependencies that don't exist yet end the walk along their branch.
+func HasDependencyCycle(off *Offline, get OfflineGetter) (bool, error) {
+	visited := map[string]bool{off.Name: true}
+	pending := DependencyNames(off)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if name == off.Name {
+			return true, nil
+		}
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		dep, err := get(name)
+		if err != nil {
+			return false, err
+		}
+		if dep == nil {
+			continue
+		}
+		pending = append(pending, DependencyNames(dep)...)
+	}
+	return false, nil
+}
+
+// DependencyNames returns the names of the Offlines off depends on.
+func DependencyNames(off *Offline) []string {
+	names := make([]string, 0, len(off.Spec.DependsOn))
+	for _, dep := range off.Spec.DependsOn {
+		names = append(names, dep.Name)
+	}
+	return names
+}
+
+// validateDependencies rejects dependencies on off itself, repeated ones and
+// phases off can't wait for.
+func validateDependencies(off *Offline) error {
+	seen := make(map[string]bool, len(off.Spec.DependsOn))
+	for _, dep := range off.Spec.DependsOn {
+		switch {
+		case dep.Name == "":
+			return fmt.Errorf("spec.dependsOn: name must not be empty")
+		case dep.Name == off.Name:
+			return fmt.Errorf("spec.dependsOn: %s depends on itself", off.Name)
+		case seen[dep.Name]:
+			return fmt.Errorf("spec.dependsOn: %s is listed more than once", dep.Name)
+		}
+		switch dep.Phase {
+		case "", DependencySucceededPhase, DependencyCompletedPhase:
+		default:
+			return fmt.Errorf("spec.dependsOn: unknown phase %q of %s", dep.Phase, dep.Name)
+		}
+		seen[dep.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func dependingOn(name string, deps ...string) *Offline {
+	off := &Offline{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	for _, dep := range deps {
+		off.Spec.DependsOn = append(off.Spec.DependsOn, OfflineDependency{Name: dep})
+	}
+	return off
+}
+
+// getterOf serves offs by name and counts the lookups.
+func getterOf(lookups *int, offs ...*Offline) OfflineGetter {
+	byName := make(map[string]*Offline, len(offs))
+	for _, off := range offs {
+		byName[off.Name] = off
+	}
+	return func(name string) (*Offline, error) {
+		*lookups++
+		return byName[name], nil
+	}
+}
+
+var _ = Describe("HasDependencyCycle", func() {
+	var lookups int
+
+	BeforeEach(func() {
+		lookups = 0
+	})
+
+	It("finds no cycle without dependencies", func() {
+		cycle, err := HasDependencyCycle(dependingOn("a"), getterOf(&lookups))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycle).To(BeFalse())
+		Expect(lookups).To(Equal(0))
+	})
+
+	It("finds a dependency on the Offline itself", func() {
+		cycle, err := HasDependencyCycle(dependingOn("a", "a"), getterOf(&lookups))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycle).To(BeTrue())
+	})
+
+	It("finds a cycle through other Offlines", func() {
+		b := dependingOn("b", "c")
+		c := dependingOn("c", "a")
+		cycle, err := HasDependencyCycle(dependingOn("a", "b"), getterOf(&lookups, b, c))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycle).To(BeTrue())
+	})
+
+	It("ignores cycles the Offline is not part of", func() {
+		b := dependingOn("b", "c")
+		c := dependingOn("c", "b")
+		cycle, err := HasDependencyCycle(dependingOn("a", "b"), getterOf(&lookups, b, c))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycle).To(BeFalse())
+		Expect(lookups).To(Equal(2))
+	})
+
+	It("looks a shared dependency up once", func() {
+		b := dependingOn("b", "d")
+		c := dependingOn("c", "d")
+		d := dependingOn("d")
+		cycle, err := HasDependencyCycle(dependingOn("a", "b", "c"), getterOf(&lookups, b, c, d))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycle).To(BeFalse())
+		Expect(lookups).To(Equal(3))
+	})
+
+	It("stops at dependencies that don't exist yet", func() {
+		b := dependingOn("b", "missing")
+		cycle, err := HasDependencyCycle(dependingOn("a", "b"), getterOf(&lookups, b))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cycle).To(BeFalse())
+	})
+
+	It("returns the errors of the getter", func() {
+		_, err := HasDependencyCycle(dependingOn("a", "b"), func(string) (*Offline, error) {
+			return nil, fmt.Errorf("unavailable")
+		})
+		Expect(err).To(MatchError("unavailable"))
+	})
+})
+
+var _ = Describe("ValidateCreate", func() {
+	It("accepts distinct dependencies of known phases", func() {
+		off := dependingOn("a", "b", "c")
+		off.Spec.DependsOn[1].Phase = DependencyCompletedPhase
+		Expect(off.ValidateCreate()).To(Succeed())
+	})
+
+	It("rejects a dependency on the Offline itself", func() {
+		Expect(dependingOn("a", "a").ValidateCreate()).NotTo(Succeed())
+	})
+
+	It("rejects a repeated dependency", func() {
+		Expect(dependingOn("a", "b", "b").ValidateCreate()).NotTo(Succeed())
+	})
+
+	It("rejects an unknown phase", func() {
+		off := dependingOn("a", "b")
+		off.Spec.DependsOn[0].Phase = "Running"
+		Expect(off.ValidateCreate()).NotTo(Succeed())
+	})
+})
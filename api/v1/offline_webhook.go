@@ -0,0 +1,182 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// BatchCPUResource is the reclaimable cpu of a node in millicores.
+	BatchCPUResource v1.ResourceName = "colocation.cmyun.io/batch-cpu"
+	// BatchMemoryResource is the reclaimable memory of a node in bytes.
+	BatchMemoryResource v1.ResourceName = "colocation.cmyun.io/batch-memory"
+
+	// NativeResourcesAnnotation set to "true" keeps the Offline's tasks
+	// requesting native cpu and memory.
+	NativeResourcesAnnotation = "colocation.cmyun.io/native-resources"
+
+	mutatePath   = "/mutate-colocation-cmyun-io-v1-offline"
+	validatePath = "/validate-colocation-cmyun-io-v1-offline"
+)
+
+// log is for logging in this package.
+var offlinelog = logf.Log.WithName("offline-resource")
+
+// offlineReader reads the dependencies of the validated Offlines.
+var offlineReader client.Reader
+
+// SetupWebhookWithManager serves the validating webhook of Offline, and its
+// defaulting webhook when batchResources is set. Otherwise the defaulting
+// webhook admits Offlines as they are, so a deployed mutating webhook
+// configuration doesn't reject them.
+func (r *Offline) SetupWebhookWithManager(mgr ctrl.Manager, batchResources bool) error {
+	offlineReader = mgr.GetAPIReader()
+	server := mgr.GetWebhookServer()
+	server.Register(validatePath, admission.ValidatingWebhookFor(r))
+	if batchResources {
+		server.Register(mutatePath, admission.DefaultingWebhookFor(r))
+	} else {
+		server.Register(mutatePath, &webhook.Admission{Handler: admission.HandlerFunc(
+			func(context.Context, admission.Request) admission.Response {
+				return admission.Allowed("batch resources are disabled")
+			})})
+	}
+	return nil
+}
+
+// +kubebuilder:webhook:path=/mutate-colocation-cmyun-io-v1-offline,mutating=true,failurePolicy=fail,groups=colocation.cmyun.io,resources=offlines,verbs=create;update,versions=v1,name=moffline.kb.io
+
+var _ webhook.Defaulter = &Offline{}
+
+// Default rewrites the task templates of the Offline to request the
+// reclaimable batch resources instead of native cpu and memory.
+func (r *Offline) Default() {
+	if r.Annotations[NativeResourcesAnnotation] == "true" {
+		return
+	}
+	offlinelog.Info("default", "name", r.Name)
+	for _, task := range r.Spec.Tasks {
+		toBatchResources(&task.Spec)
+	}
+	for _, task := range r.Spec.Elastic {
+		if task.Template != nil {
+			toBatchResources(&task.Template.Spec)
+		}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-colocation-cmyun-io-v1-offline,mutating=false,failurePolicy=fail,groups=colocation.cmyun.io,resources=offlines,verbs=create;update,versions=v1,name=voffline.kb.io
+
+var _ webhook.Validator = &Offline{}
+
+// ValidateCreate rejects an Offline whose dependencies can never be
+// satisfied: itself, directly or through other Offlines.
+func (r *Offline) ValidateCreate() error {
+	offlinelog.Info("validate create", "name", r.Name)
+	return r.validateDependsOn()
+}
+
+// ValidateUpdate rejects the same dependencies as ValidateCreate.
+func (r *Offline) ValidateUpdate(old runtime.Object) error {
+	offlinelog.Info("validate update", "name", r.Name)
+	return r.validateDependsOn()
+}
+
+// ValidateDelete accepts every deletion.
+func (r *Offline) ValidateDelete() error {
+	return nil
+}
+
+func (r *Offline) validateDependsOn() error {
+	if err := validateDependencies(r); err != nil {
+		return err
+	}
+	if offlineReader == nil || len(r.Spec.DependsOn) == 0 {
+		return nil
+	}
+	cycle, err := HasDependencyCycle(r, func(name string) (*Offline, error) {
+		dep := &Offline{}
+		if err := offlineReader.Get(context.Background(), types.NamespacedName{Namespace: r.Namespace, Name: name}, dep); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return dep, nil
+	})
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return fmt.Errorf("spec.dependsOn: the dependencies of %s lead back to it", r.Name)
+	}
+	return nil
+}
+
+func toBatchResources(spec *v1.PodSpec) {
+	for i := range spec.InitContainers {
+		toBatchRequirements(&spec.InitContainers[i].Resources)
+	}
+	for i := range spec.Containers {
+		toBatchRequirements(&spec.Containers[i].Resources)
+	}
+}
+
+// BatchEquivalent returns the batch resources that stand for the cpu and
+// memory of list.
+func BatchEquivalent(list v1.ResourceList) v1.ResourceList {
+	batch := v1.ResourceList{}
+	if cpu, ok := list[v1.ResourceCPU]; ok {
+		batch[BatchCPUResource] = *resource.NewQuantity(cpu.MilliValue(), resource.DecimalSI)
+	}
+	if memory, ok := list[v1.ResourceMemory]; ok {
+		batch[BatchMemoryResource] = *resource.NewQuantity(memory.Value(), resource.BinarySI)
+	}
+	return batch
+}
+
+// toBatchRequirements moves the cpu and memory of a container to the batch
+// resources. Extended resources can't be overcommitted, so the request is
+// used as the limit too.
+func toBatchRequirements(reqs *v1.ResourceRequirements) {
+	batch := BatchEquivalent(reqs.Requests)
+	if len(batch) == 0 {
+		return
+	}
+	for _, list := range []*v1.ResourceList{&reqs.Requests, &reqs.Limits} {
+		if *list == nil {
+			*list = v1.ResourceList{}
+		}
+		delete(*list, v1.ResourceCPU)
+		delete(*list, v1.ResourceMemory)
+		for name, quantity := range batch {
+			(*list)[name] = quantity.DeepCopy()
+		}
+	}
+}
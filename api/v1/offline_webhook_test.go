@@ -0,0 +1,89 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func requesting(cpu, memory string) *corev1.PodTemplateSpec {
+	reqs := corev1.ResourceList{}
+	if cpu != "" {
+		reqs[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		reqs[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	template := &corev1.PodTemplateSpec{}
+	template.Spec.Containers = []corev1.Container{{Name: "main", Resources: corev1.ResourceRequirements{Requests: reqs}}}
+	return template
+}
+
+func withBatchTasks(tasks ...*corev1.PodTemplateSpec) *Offline {
+	off := &Offline{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "batch"}}
+	off.Spec.Tasks = tasks
+	return off
+}
+
+var _ = Describe("Default", func() {
+	It("moves cpu and memory requests to the batch resources", func() {
+		off := withBatchTasks(requesting("1500m", "1Gi"))
+		off.Default()
+
+		resources := off.Spec.Tasks[0].Spec.Containers[0].Resources
+		for _, list := range []corev1.ResourceList{resources.Requests, resources.Limits} {
+			Expect(list).NotTo(HaveKey(corev1.ResourceCPU))
+			Expect(list).NotTo(HaveKey(corev1.ResourceMemory))
+			cpu := list[BatchCPUResource]
+			memory := list[BatchMemoryResource]
+			Expect(cpu.Value()).To(Equal(int64(1500)))
+			Expect(memory.Value()).To(Equal(int64(1024 * 1024 * 1024)))
+		}
+	})
+
+	It("rewrites init containers and elastic templates too", func() {
+		template := requesting("1", "")
+		template.Spec.InitContainers = []corev1.Container{{Name: "init", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+		}}}
+		off := withBatchTasks(template)
+		off.Spec.Elastic = []ElasticTask{{Name: "workers", Template: requesting("", "256Mi"), MaxReplicas: 2}, {Name: "empty"}}
+		off.Default()
+
+		Expect(off.Spec.Tasks[0].Spec.InitContainers[0].Resources.Requests).To(HaveKey(BatchCPUResource))
+		Expect(off.Spec.Tasks[0].Spec.Containers[0].Resources.Requests).NotTo(HaveKey(BatchMemoryResource))
+		Expect(off.Spec.Elastic[0].Template.Spec.Containers[0].Resources.Requests).To(HaveKey(BatchMemoryResource))
+		Expect(off.Spec.Elastic[0].Template.Spec.Containers[0].Resources.Requests).NotTo(HaveKey(BatchCPUResource))
+	})
+
+	It("leaves containers without cpu or memory requests alone", func() {
+		off := withBatchTasks(requesting("", ""))
+		off.Default()
+		Expect(off.Spec.Tasks[0].Spec.Containers[0].Resources.Limits).To(BeNil())
+	})
+
+	It("keeps native resources when the Offline asks for them", func() {
+		off := withBatchTasks(requesting("1", "1Gi"))
+		off.Annotations = map[string]string{NativeResourcesAnnotation: "true"}
+		off.Default()
+		Expect(off.Spec.Tasks[0].Spec.Containers[0].Resources.Requests).To(HaveKey(corev1.ResourceCPU))
+		Expect(off.Spec.Tasks[0].Spec.Containers[0].Resources.Requests).NotTo(HaveKey(BatchCPUResource))
+	})
+})
@@ -0,0 +1,85 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodGroupLabel names the PodGroup of a pod, the pods of an Offline are
+// selected by it to place them relative to each other.
+const PodGroupLabel = "colocation.cmyun.io/pod-group"
+
+// PodGroupSpec is the gang an Offline schedules together. PodGroups live in
+// colocation.cmyun.io, gang schedulers that watch the PodGroups of their own
+// API group don't act on them.
+type PodGroupSpec struct {
+	// MinMember is the number of pods that must be scheduled together.
+	MinMember int32 `json:"minMember,omitempty"`
+	// MinResources is what the MinMember pods request all together.
+	MinResources *v1.ResourceList `json:"minResources,omitempty"`
+	Queue        string           `json:"queue,omitempty"`
+}
+
+type PodGroupPhase string
+
+const (
+	//the offline isn't admitted yet
+	PodGroupPending = "Pending"
+	//pods are created, fewer than minMember are running
+	PodGroupScheduling = "Scheduling"
+	//minMember pods are running
+	PodGroupRunning = "Running"
+	//the offline succeeded
+	PodGroupFinished = "Finished"
+	//the offline failed
+	PodGroupFailed = "Failed"
+)
+
+// PodGroupStatus mirrors the OfflineStatus of the Offline owning the PodGroup
+type PodGroupStatus struct {
+	Phase     PodGroupPhase `json:"phase,omitempty"`
+	Running   int32         `json:"running,omitempty"`
+	Succeeded int32         `json:"succeeded,omitempty"`
+	Failed    int32         `json:"failed,omitempty"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +kubebuilder:object:root=true
+
+// PodGroup is the group of pods of an Offline, maintained by the controller
+type PodGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodGroupSpec   `json:"spec,omitempty"`
+	Status PodGroupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PodGroupList contains a list of PodGroup
+type PodGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodGroup{}, &PodGroupList{})
+}
@@ -33,6 +33,91 @@ type OfflineSpec struct {
 	Selector *metav1.LabelSelector `json:"selector"`
 	Tasks    []*v1.PodTemplateSpec `json:"tasks"`
 	Queue    string 			   `json:"queue,omitempty"`
+	// MaxRuntimeSeconds declares how long the Offline runs at most once its
+	// gang is running. Offlines that declare it may be backfilled ahead of a
+	// blocked head of their queue. It is an estimate only, the Offline is
+	// failed by ActiveDeadlineSeconds.
+	MaxRuntimeSeconds *int64 `json:"maxRuntimeSeconds,omitempty"`
+	// DependsOn lists the Offlines in the same namespace that must reach
+	// their required phase before this Offline is queued.
+	DependsOn []OfflineDependency `json:"dependsOn,omitempty"`
+	// Elastic lists the tasks that grow beyond their MinReplicas while the
+	// cluster has idle capacity and shrink back when online workloads
+	// need room. Their pods must match Selector too.
+	Elastic []ElasticTask `json:"elastic,omitempty"`
+	// MinResources is what the gang needs all together to run, declared
+	// on its PodGroup. It is the sum of the requests of the tasks and of
+	// MinReplicas pods of each elastic task when empty.
+	MinResources v1.ResourceList `json:"minResources,omitempty"`
+	// Suspend deletes the Offline's pods and takes it out of its queue
+	// until it is unset. The Offline keeps its place in the queue.
+	Suspend *bool `json:"suspend,omitempty"`
+	// ActiveDeadlineSeconds is how long the Offline may be active once its
+	// pods are created before the whole gang is failed.
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// SchedulingTimeoutSeconds is how long the Offline may take to get
+	// MinGang pods running once its pods are created. The Offline is failed
+	// and its pods deleted when it doesn't.
+	SchedulingTimeoutSeconds *int64 `json:"schedulingTimeoutSeconds,omitempty"`
+	// TTLSecondsAfterFinished deletes the Offline and its pods this long
+	// after it succeeded or failed.
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+	// Topology places the pods of the gang relative to each other. The
+	// pods are placed without regard to each other when it is nil.
+	Topology *TopologyPolicy `json:"topology,omitempty"`
+}
+
+// ElasticTask is a task whose number of pods varies between MinReplicas
+// and MaxReplicas
+type ElasticTask struct {
+	Name     string              `json:"name"`
+	Template *v1.PodTemplateSpec `json:"template"`
+	// MinReplicas pods are created with the gang and never shrunk.
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+	MaxReplicas int32 `json:"maxReplicas"`
+}
+
+type TopologyMode string
+
+const (
+	//every pod of the gang on one node
+	TopologySameNode TopologyMode = "SameNode"
+	//every pod of the gang in one topology domain
+	TopologyPack TopologyMode = "Pack"
+	//every pod of the gang in a different topology domain
+	TopologySpread TopologyMode = "Spread"
+)
+
+// TopologyPolicy is how the pods of a gang are placed relative to each
+// other
+type TopologyPolicy struct {
+	// +kubebuilder:validation:Enum=SameNode;Pack;Spread
+	Mode TopologyMode `json:"mode"`
+	// TopologyKey is the node label whose values are the domains of Pack
+	// and Spread, the zone label when empty. SameNode always uses the
+	// hostname label.
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// Required holds the Offline pending while no node layout of the
+	// cluster can satisfy the policy and places its pods only where they
+	// satisfy it. The policy is only a preference otherwise.
+	Required bool `json:"required,omitempty"`
+}
+
+type DependencyPhase string
+
+const (
+	//the dependency must succeed, its failure fails the dependent
+	DependencySucceededPhase DependencyPhase = "Succeeded"
+	//the dependency must either succeed or fail
+	DependencyCompletedPhase DependencyPhase = "Completed"
+)
+
+// OfflineDependency is an Offline another Offline waits for
+type OfflineDependency struct {
+	Name string `json:"name"`
+	// Phase the dependency must reach, Succeeded when empty.
+	// +kubebuilder:validation:Enum=Succeeded;Completed
+	Phase DependencyPhase `json:"phase,omitempty"`
 }
 
 type OfflinePhase string
@@ -50,8 +135,67 @@ const (
 	OfflineSchedulingPhase = "Scheduling"
 	//Failed to schedule,running+succeeded<minGang
 	OfflineFailedPhase = "Failed"
+	//dependencies haven't completed yet
+	OfflineWaitingPhase = "Waiting"
+	//spec.suspend is set, or its pods are still being deleted after it was unset
+	OfflineSuspendedPhase = "Suspended"
+)
+
+const (
+	//a dependency required to succeed failed
+	OfflineDependencyFailedReason = "DependencyFailed"
+	//dependencies lead back to the offline itself
+	OfflineDependencyCycleReason = "DependencyCycle"
+	//active longer than spec.activeDeadlineSeconds
+	OfflineDeadlineExceededReason = "DeadlineExceeded"
+	//minGang not reached within spec.schedulingTimeoutSeconds
+	OfflineSchedulingTimeoutReason = "SchedulingTimeout"
+	//no node layout satisfies the required spec.topology, the offline is
+	//kept pending out of its queue until one does
+	OfflineTopologyUnsatisfiableReason = "TopologyUnsatisfiable"
+	//failed to schedule as many times as the controller retries
+	OfflineUnschedulableReason = "Unschedulable"
+)
+
+
+// MemoryPressureLabel is set on a node above its middle watermark. The pods
+// of Offlines require nodes without it, online pods ignore it.
+const MemoryPressureLabel = "colocation.cmyun.io/memory-pressure"
+
+const (
+	//cpu quota of offline pods suppressed above the low watermark
+	PressureThrottledAction = "Throttled"
+	//node labelled against new offline pods above the middle watermark
+	PressureFencedAction = "Fenced"
+	//pod evicted above the high watermark
+	PressureEvictedAction = "Evicted"
+	//node back under the low watermark
+	PressureRelievedAction = "Relieved"
 )
 
+// OfflinePressure is an action taken on a node under memory pressure
+type OfflinePressure struct {
+	Node string `json:"node"`
+	// Watermark is the highest watermark the node's memory usage crossed,
+	// Low, Middle, High or None.
+	Watermark string      `json:"watermark"`
+	Action    string      `json:"action"`
+	Time      metav1.Time `json:"time"`
+}
+
+// OfflineTransition is a change of the phase or reason of an Offline
+type OfflineTransition struct {
+	Phase OfflinePhase `json:"phase"`
+	// From is the phase the Offline left, empty when it was created.
+	From   OfflinePhase `json:"from,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+	Time   metav1.Time  `json:"time"`
+	// QueuePosition is the place of the Offline in its queue, from 1,
+	// while it waits there, 0 otherwise.
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+	// Attempt counts the times the Offline was unschedulable before.
+	Attempt int32 `json:"attempt,omitempty"`
+}
 
 // INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 // Important: Run "make" to regenerate code after modifying this file
@@ -63,9 +207,38 @@ type OfflineStatus struct {
 	PodSucceeded int32        `json:"succeeded,omitempty"`
 	PodFailed    int32        `json:"failed,omitempty"`
 	PodUnknown   int32        `json:"unknown,omitempty"`
+	// StartTime is when the Offline's pods were created by the controller.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the Offline succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// ElasticReplicas is the current number of pods of each elastic task.
+	ElasticReplicas map[string]int32 `json:"elasticReplicas,omitempty"`
+	// CurrentSize is the current number of pods of the Offline.
+	CurrentSize int32 `json:"currentSize,omitempty"`
+	// MemoryPressure is the last action taken against the Offline's pods
+	// because their node crossed a memory watermark.
+	MemoryPressure *OfflinePressure `json:"memoryPressure,omitempty"`
+	// PodEvicted counts the pods evicted under memory pressure.
+	PodEvicted int32 `json:"evicted,omitempty"`
+	// Reason is a brief CamelCase message indicating why the controller
+	// failed the Offline regardless of its pods' phases.
+	Reason string `json:"reason,omitempty"`
+	// EffectivePriority is the Level raised by the time the Offline has
+	// been waiting in its queue. It orders the queue and preemption victims
+	// instead of Level once aging has set it.
+	EffectivePriority *int32 `json:"effectivePriority,omitempty"`
+	// Backfilled is true when the Offline was admitted ahead of the head
+	// of its queue.
+	Backfilled bool `json:"backfilled,omitempty"`
+	// History lists the latest transitions of the Offline, oldest first.
+	// It is bounded, the oldest transitions are dropped first.
+	History []OfflineTransition `json:"history,omitempty"`
 }
 
+// +genclient
+// +genclient:noStatus
 // +kubebuilder:object:root=true
+// +kubebuilder:storageversion
 
 // Offline is the Schema for the offlines API
 type Offline struct {
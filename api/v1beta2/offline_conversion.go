@@ -0,0 +1,366 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/YunWang/colocation/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	// TasksAnnotation keeps on a v1 Offline the names and replicas of the
+	// tasks it had in v1beta2, which v1 flattens into one template per pod.
+	// It is only set when the tasks aren't the ones a v1 Offline converts
+	// to by default.
+	TasksAnnotation = "colocation.cmyun.io/v1beta2-tasks"
+	// ConditionsAnnotation keeps on a v1 Offline the v1beta2 conditions it
+	// had, when they aren't the ones derived from its v1 status.
+	ConditionsAnnotation = "colocation.cmyun.io/v1beta2-conditions"
+)
+
+var _ conversion.Convertible = &Offline{}
+
+// taskLayout is an entry of TasksAnnotation. A task with a MaxReplicas is
+// an elastic task of v1 and keeps its name there, any other task stands
+// for Replicas consecutive templates of v1, or for Template when it has
+// none.
+type taskLayout struct {
+	Name     string                  `json:"name,omitempty"`
+	Replicas int32                   `json:"replicas,omitempty"`
+	Elastic  bool                    `json:"elastic,omitempty"`
+	Template *corev1.PodTemplateSpec `json:"template,omitempty"`
+}
+
+// ConvertTo converts this Offline to the v1 hub.
+func (src *Offline) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.Offline)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec = v1.OfflineSpec{
+		MinGang:                  src.Spec.MinAvailable,
+		Level:                    src.Spec.Priority,
+		Selector:                 src.Spec.Selector.DeepCopy(),
+		Queue:                    src.Spec.Queue,
+		MaxRuntimeSeconds:        copyInt64(src.Spec.RunPolicy.MaxRuntimeSeconds),
+		MinResources:             src.Spec.MinResources.DeepCopy(),
+		Suspend:                  copyBool(src.Spec.Suspend),
+		ActiveDeadlineSeconds:    copyInt64(src.Spec.RunPolicy.ActiveDeadlineSeconds),
+		SchedulingTimeoutSeconds: copyInt64(src.Spec.RunPolicy.SchedulingTimeoutSeconds),
+		TTLSecondsAfterFinished:  copyInt32(src.Spec.RunPolicy.TTLSecondsAfterFinished),
+	}
+	if src.Spec.Tasks != nil {
+		dst.Spec.Tasks = []*corev1.PodTemplateSpec{}
+	}
+	layout := make([]taskLayout, 0, len(src.Spec.Tasks))
+	for i := range src.Spec.Tasks {
+		task := &src.Spec.Tasks[i]
+		if task.MaxReplicas != nil {
+			dst.Spec.Elastic = append(dst.Spec.Elastic, v1.ElasticTask{
+				Name:        task.Name,
+				Template:    task.Template.DeepCopy(),
+				MinReplicas: task.Replicas,
+				MaxReplicas: *task.MaxReplicas,
+			})
+			layout = append(layout, taskLayout{Elastic: true})
+			continue
+		}
+		entry := taskLayout{Name: task.Name, Replicas: task.Replicas}
+		if task.Replicas <= 0 {
+			entry.Template = task.Template.DeepCopy()
+		}
+		for r := int32(0); r < task.Replicas; r++ {
+			dst.Spec.Tasks = append(dst.Spec.Tasks, task.Template.DeepCopy())
+		}
+		layout = append(layout, entry)
+	}
+	for _, dep := range src.Spec.DependsOn {
+		dst.Spec.DependsOn = append(dst.Spec.DependsOn, v1.OfflineDependency{Name: dep.Name, Phase: v1.DependencyPhase(dep.Phase)})
+	}
+	if topology := src.Spec.Topology; topology != nil {
+		dst.Spec.Topology = &v1.TopologyPolicy{Mode: v1.TopologyMode(topology.Mode), TopologyKey: topology.TopologyKey, Required: topology.Required}
+	}
+
+	dst.Status = v1.OfflineStatus{
+		Phase:             v1.OfflinePhase(src.Status.Phase),
+		PodPending:        src.Status.Pods.Pending,
+		PodRunning:        src.Status.Pods.Running,
+		PodSucceeded:      src.Status.Pods.Succeeded,
+		PodFailed:         src.Status.Pods.Failed,
+		PodUnknown:        src.Status.Pods.Unknown,
+		PodEvicted:        src.Status.Pods.Evicted,
+		StartTime:         src.Status.StartTime.DeepCopy(),
+		CompletionTime:    src.Status.CompletionTime.DeepCopy(),
+		CurrentSize:       src.Status.CurrentSize,
+		Reason:            src.Status.Reason,
+		EffectivePriority: copyInt32(src.Status.EffectivePriority),
+		Backfilled:        src.Status.Backfilled,
+	}
+	if src.Status.Replicas != nil {
+		dst.Status.ElasticReplicas = make(map[string]int32, len(src.Status.Replicas))
+		for name, replicas := range src.Status.Replicas {
+			dst.Status.ElasticReplicas[name] = replicas
+		}
+	}
+	if pressure := src.Status.MemoryPressure; pressure != nil {
+		dst.Status.MemoryPressure = &v1.OfflinePressure{Node: pressure.Node, Watermark: pressure.Watermark, Action: pressure.Action, Time: *pressure.Time.DeepCopy()}
+	}
+	for _, t := range src.Status.History {
+		dst.Status.History = append(dst.Status.History, v1.OfflineTransition{
+			Phase:         v1.OfflinePhase(t.Phase),
+			From:          v1.OfflinePhase(t.From),
+			Reason:        t.Reason,
+			Time:          *t.Time.DeepCopy(),
+			QueuePosition: t.QueuePosition,
+			Attempt:       t.Attempt,
+		})
+	}
+
+	if !apiequality.Semantic.DeepEqual(layout, defaultLayout(len(dst.Spec.Tasks), len(dst.Spec.Elastic))) {
+		if err := setAnnotation(&dst.ObjectMeta, TasksAnnotation, layout); err != nil {
+			return err
+		}
+	}
+	if !apiequality.Semantic.DeepEqual(src.Status.Conditions, conditionsOf(&dst.Status)) {
+		if err := setAnnotation(&dst.ObjectMeta, ConditionsAnnotation, src.Status.Conditions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1 hub to this Offline.
+func (dst *Offline) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.Offline)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	layout := tasksLayout(src)
+	var conditions []OfflineCondition
+	if value, ok := dst.Annotations[ConditionsAnnotation]; !ok || json.Unmarshal([]byte(value), &conditions) != nil {
+		conditions = conditionsOf(&src.Status)
+	}
+	delete(dst.Annotations, TasksAnnotation)
+	delete(dst.Annotations, ConditionsAnnotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	dst.Spec = OfflineSpec{
+		Queue:        src.Spec.Queue,
+		Priority:     src.Spec.Level,
+		MinAvailable: src.Spec.MinGang,
+		MinResources: src.Spec.MinResources.DeepCopy(),
+		Selector:     src.Spec.Selector.DeepCopy(),
+		Suspend:      copyBool(src.Spec.Suspend),
+		RunPolicy: RunPolicy{
+			MaxRuntimeSeconds:        copyInt64(src.Spec.MaxRuntimeSeconds),
+			ActiveDeadlineSeconds:    copyInt64(src.Spec.ActiveDeadlineSeconds),
+			SchedulingTimeoutSeconds: copyInt64(src.Spec.SchedulingTimeoutSeconds),
+			TTLSecondsAfterFinished:  copyInt32(src.Spec.TTLSecondsAfterFinished),
+		},
+	}
+	if src.Spec.Tasks != nil || src.Spec.Elastic != nil {
+		dst.Spec.Tasks = make([]Task, 0, len(layout))
+	}
+	fixed, elastic := 0, 0
+	for _, entry := range layout {
+		if entry.Elastic {
+			e := src.Spec.Elastic[elastic]
+			elastic++
+			max := e.MaxReplicas
+			dst.Spec.Tasks = append(dst.Spec.Tasks, Task{Name: e.Name, Replicas: e.MinReplicas, MaxReplicas: &max, Template: templateOf(e.Template)})
+			continue
+		}
+		task := Task{Name: entry.Name, Replicas: entry.Replicas}
+		if entry.Replicas > 0 {
+			task.Template = templateOf(src.Spec.Tasks[fixed])
+			fixed += int(entry.Replicas)
+		} else {
+			task.Template = templateOf(entry.Template)
+		}
+		dst.Spec.Tasks = append(dst.Spec.Tasks, task)
+	}
+	for _, dep := range src.Spec.DependsOn {
+		dst.Spec.DependsOn = append(dst.Spec.DependsOn, Dependency{Name: dep.Name, Phase: DependencyPhase(dep.Phase)})
+	}
+	if topology := src.Spec.Topology; topology != nil {
+		dst.Spec.Topology = &TopologyPolicy{Mode: TopologyMode(topology.Mode), TopologyKey: topology.TopologyKey, Required: topology.Required}
+	}
+
+	dst.Status = OfflineStatus{
+		Phase:      OfflinePhase(src.Status.Phase),
+		Reason:     src.Status.Reason,
+		Conditions: conditions,
+		Pods: PodCounts{
+			Pending:   src.Status.PodPending,
+			Running:   src.Status.PodRunning,
+			Succeeded: src.Status.PodSucceeded,
+			Failed:    src.Status.PodFailed,
+			Unknown:   src.Status.PodUnknown,
+			Evicted:   src.Status.PodEvicted,
+		},
+		CurrentSize:       src.Status.CurrentSize,
+		StartTime:         src.Status.StartTime.DeepCopy(),
+		CompletionTime:    src.Status.CompletionTime.DeepCopy(),
+		EffectivePriority: copyInt32(src.Status.EffectivePriority),
+		Backfilled:        src.Status.Backfilled,
+	}
+	if src.Status.ElasticReplicas != nil {
+		dst.Status.Replicas = make(map[string]int32, len(src.Status.ElasticReplicas))
+		for name, replicas := range src.Status.ElasticReplicas {
+			dst.Status.Replicas[name] = replicas
+		}
+	}
+	if pressure := src.Status.MemoryPressure; pressure != nil {
+		dst.Status.MemoryPressure = &MemoryPressure{Node: pressure.Node, Watermark: pressure.Watermark, Action: pressure.Action, Time: *pressure.Time.DeepCopy()}
+	}
+	for _, t := range src.Status.History {
+		dst.Status.History = append(dst.Status.History, Transition{
+			Phase:         OfflinePhase(t.Phase),
+			From:          OfflinePhase(t.From),
+			Reason:        t.Reason,
+			Time:          *t.Time.DeepCopy(),
+			QueuePosition: t.QueuePosition,
+			Attempt:       t.Attempt,
+		})
+	}
+	return nil
+}
+
+// defaultLayout is the layout of a v1 Offline without TasksAnnotation: a
+// task of one replica named task-<index> for each of its templates, then
+// its elastic tasks.
+func defaultLayout(tasks, elastic int) []taskLayout {
+	layout := make([]taskLayout, 0, tasks+elastic)
+	for i := 0; i < tasks; i++ {
+		layout = append(layout, taskLayout{Name: fmt.Sprintf("task-%d", i), Replicas: 1})
+	}
+	for i := 0; i < elastic; i++ {
+		layout = append(layout, taskLayout{Elastic: true})
+	}
+	return layout
+}
+
+// tasksLayout returns the layout TasksAnnotation records when it still
+// matches the tasks of off, the default layout otherwise.
+func tasksLayout(off *v1.Offline) []taskLayout {
+	fallback := defaultLayout(len(off.Spec.Tasks), len(off.Spec.Elastic))
+	value, ok := off.Annotations[TasksAnnotation]
+	if !ok {
+		return fallback
+	}
+	var layout []taskLayout
+	if err := json.Unmarshal([]byte(value), &layout); err != nil {
+		return fallback
+	}
+	fixed, elastic := 0, 0
+	for _, entry := range layout {
+		switch {
+		case entry.Elastic:
+			elastic++
+		case entry.Replicas <= 0:
+			if entry.Template == nil {
+				return fallback
+			}
+		default:
+			// The templates a task stands for must still be the same.
+			end := fixed + int(entry.Replicas)
+			if end > len(off.Spec.Tasks) {
+				return fallback
+			}
+			for _, template := range off.Spec.Tasks[fixed+1 : end] {
+				if !apiequality.Semantic.DeepEqual(template, off.Spec.Tasks[fixed]) {
+					return fallback
+				}
+			}
+			fixed = end
+		}
+	}
+	if fixed != len(off.Spec.Tasks) || elastic != len(off.Spec.Elastic) {
+		return fallback
+	}
+	return layout
+}
+
+// conditionsOf derives the conditions of an Offline from its v1 status.
+func conditionsOf(status *v1.OfflineStatus) []OfflineCondition {
+	var conditions []OfflineCondition
+	if status.StartTime != nil {
+		reason := "Admitted"
+		if status.Backfilled {
+			reason = "Backfilled"
+		}
+		conditions = append(conditions, OfflineCondition{Type: OfflineAdmitted, Status: corev1.ConditionTrue, Reason: reason, LastTransitionTime: *status.StartTime.DeepCopy()})
+	}
+	switch status.Phase {
+	case v1.OfflineSuspendedPhase:
+		conditions = append(conditions, OfflineCondition{Type: OfflineSuspendedCondition, Status: corev1.ConditionTrue})
+	case v1.OfflineFailedPhase:
+		failed := OfflineCondition{Type: OfflineFailedCondition, Status: corev1.ConditionTrue, Reason: status.Reason}
+		if status.CompletionTime != nil {
+			failed.LastTransitionTime = *status.CompletionTime.DeepCopy()
+		}
+		conditions = append(conditions, failed)
+	}
+	return conditions
+}
+
+func setAnnotation(meta *metav1.ObjectMeta, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = string(data)
+	return nil
+}
+
+func templateOf(template *corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	if template == nil {
+		return corev1.PodTemplateSpec{}
+	}
+	return *template.DeepCopy()
+}
+
+func copyBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	out := *b
+	return &out
+}
+
+func copyInt32(i *int32) *int32 {
+	if i == nil {
+		return nil
+	}
+	out := *i
+	return &out
+}
+
+func copyInt64(i *int64) *int64 {
+	if i == nil {
+		return nil
+	}
+	out := *i
+	return &out
+}
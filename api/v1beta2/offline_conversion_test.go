@@ -0,0 +1,156 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"encoding/json"
+	"math/rand"
+
+	v1 "github.com/YunWang/colocation/api/v1"
+	fuzz "github.com/google/gofuzz"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+const fuzzIterations = 500
+
+// offlineFuzzerFuncs keep fuzzed Offlines to what the API server stores:
+// no nil templates, a handful of replicas, and times of second precision
+// as they are serialized. Managed fields are left out, their trie grows
+// without bound under the fuzzer.
+func offlineFuzzerFuncs(_ serializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(f *metav1.Fields, c fuzz.Continue) {
+			*f = metav1.Fields{}
+		},
+		func(t *metav1.Time, c fuzz.Continue) {
+			*t = metav1.Unix(c.Int63n(1<<32), 0).Rfc3339Copy()
+		},
+		func(task *Task, c fuzz.Continue) {
+			c.FuzzNoCustom(task)
+			task.Replicas = c.Int31n(4)
+			if task.MaxReplicas == nil && task.Replicas == 0 {
+				task.Replicas = 1
+			}
+		},
+		func(spec *v1.OfflineSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			for i := range spec.Tasks {
+				if spec.Tasks[i] == nil {
+					spec.Tasks[i] = &corev1.PodTemplateSpec{}
+				}
+			}
+			for i := range spec.Elastic {
+				if spec.Elastic[i].Template == nil {
+					spec.Elastic[i].Template = &corev1.PodTemplateSpec{}
+				}
+			}
+		},
+	}
+}
+
+// jsonDiff shows where two objects differ, which ObjectReflectDiff takes
+// too long to on pod templates.
+func jsonDiff(a, b interface{}) string {
+	aJSON, _ := json.MarshalIndent(a, "", "  ")
+	bJSON, _ := json.MarshalIndent(b, "", "  ")
+	return diff.StringDiff(string(aJSON), string(bJSON))
+}
+
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	scheme := runtime.NewScheme()
+	funcs := fuzzer.MergeFuzzerFuncs(metafuzzer.Funcs, offlineFuzzerFuncs)
+	return fuzzer.FuzzerFor(funcs, rand.NewSource(seed), serializer.NewCodecFactory(scheme)).NilChance(0.2).NumElements(0, 3)
+}
+
+var _ = Describe("Offline conversion", func() {
+	It("round-trips v1beta2 through v1", func() {
+		f := newFuzzer(GinkgoRandomSeed())
+		for i := 0; i < fuzzIterations; i++ {
+			original := &Offline{}
+			f.Fuzz(original)
+			hub := &v1.Offline{}
+			Expect(original.DeepCopy().ConvertTo(hub)).To(Succeed())
+			converted := &Offline{}
+			Expect(converted.ConvertFrom(hub)).To(Succeed())
+			Expect(apiequality.Semantic.DeepEqual(original, converted)).To(BeTrue(), diff.ObjectReflectDiff(original, converted))
+		}
+	})
+
+	It("round-trips v1 through v1beta2", func() {
+		f := newFuzzer(GinkgoRandomSeed())
+		for i := 0; i < fuzzIterations; i++ {
+			original := &v1.Offline{}
+			f.Fuzz(original)
+			spoke := &Offline{}
+			Expect(spoke.ConvertFrom(original.DeepCopy())).To(Succeed())
+			converted := &v1.Offline{}
+			Expect(spoke.ConvertTo(converted)).To(Succeed())
+			Expect(apiequality.Semantic.DeepEqual(original, converted)).To(BeTrue(), diff.ObjectReflectDiff(original, converted))
+		}
+	})
+
+	It("groups the templates of a v1 Offline back into named tasks", func() {
+		template := corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "worker", Image: "busybox"}}}}
+		max := int32(4)
+		original := &Offline{Spec: OfflineSpec{Tasks: []Task{
+			{Name: "workers", Replicas: 3, Template: template},
+			{Name: "standby", Replicas: 0, Template: template},
+			{Name: "elastic", Replicas: 1, MaxReplicas: &max, Template: template},
+		}}}
+		hub := &v1.Offline{}
+		Expect(original.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Spec.Tasks).To(HaveLen(3))
+		Expect(hub.Spec.Elastic).To(HaveLen(1))
+		Expect(hub.Annotations).To(HaveKey(TasksAnnotation))
+
+		converted := &Offline{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted.Spec.Tasks).To(Equal(original.Spec.Tasks))
+		Expect(converted.Annotations).To(BeNil())
+
+		// A v1 client changing the templates invalidates the grouping.
+		hub.Spec.Tasks[1].Spec.Containers[0].Image = "alpine"
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted.Spec.Tasks).To(HaveLen(4))
+		Expect(converted.Spec.Tasks[0].Name).To(Equal("task-0"))
+	})
+
+	It("derives conditions from a v1 status", func() {
+		start := metav1.Unix(100, 0)
+		hub := &v1.Offline{Status: v1.OfflineStatus{
+			Phase:      v1.OfflineFailedPhase,
+			Reason:     v1.OfflineDeadlineExceededReason,
+			StartTime:  &start,
+			Backfilled: true,
+		}}
+		converted := &Offline{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted.Status.Phase).To(Equal(OfflineFailed))
+		Expect(converted.Status.Conditions).To(Equal([]OfflineCondition{
+			{Type: OfflineAdmitted, Status: corev1.ConditionTrue, Reason: "Backfilled", LastTransitionTime: start},
+			{Type: OfflineFailedCondition, Status: corev1.ConditionTrue, Reason: v1.OfflineDeadlineExceededReason},
+		}))
+	})
+})
@@ -0,0 +1,262 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OfflineSpec defines the desired state of Offline
+type OfflineSpec struct {
+	// Queue the Offline waits in, the default queue when empty.
+	Queue string `json:"queue,omitempty"`
+	// Priority orders the Offlines of a queue, higher first.
+	Priority int32 `json:"priority,omitempty"`
+	// MinAvailable is how many pods of the gang must run together for the
+	// Offline to run.
+	MinAvailable int32 `json:"minAvailable,omitempty"`
+	// MinResources is what the gang needs all together to run. It is the
+	// sum of the requests of Replicas pods of every task when empty.
+	MinResources corev1.ResourceList `json:"minResources,omitempty"`
+	// Selector matches the pods of the Offline.
+	Selector *metav1.LabelSelector `json:"selector"`
+	// Tasks are the pods of the Offline, grouped by template.
+	Tasks []Task `json:"tasks"`
+	// DependsOn lists the Offlines in the same namespace that must reach
+	// their required phase before this Offline is queued.
+	DependsOn []Dependency `json:"dependsOn,omitempty"`
+	// Topology places the pods of the gang relative to each other. The
+	// pods are placed without regard to each other when it is nil.
+	Topology *TopologyPolicy `json:"topology,omitempty"`
+	// Suspend deletes the Offline's pods and takes it out of its queue
+	// until it is unset. The Offline keeps its place in the queue.
+	Suspend *bool `json:"suspend,omitempty"`
+	// RunPolicy bounds how long the Offline schedules, runs and is kept.
+	RunPolicy RunPolicy `json:"runPolicy,omitempty"`
+}
+
+// Task is a group of pods created from one template
+type Task struct {
+	// Name of the task, unique within the Offline.
+	Name string `json:"name"`
+	// Replicas is the number of pods of the task created with the gang.
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+	// MaxReplicas makes the task elastic: it grows up to MaxReplicas pods
+	// while the cluster has idle capacity and shrinks back to Replicas when
+	// online workloads need room.
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// Template of the pods of the task, which must match Selector.
+	Template corev1.PodTemplateSpec `json:"template"`
+}
+
+// RunPolicy holds the time bounds of an Offline, each unbounded when nil
+type RunPolicy struct {
+	// MaxRuntimeSeconds declares how long the Offline runs at most once its
+	// gang is running. Offlines that declare it may be backfilled ahead of a
+	// blocked head of their queue. It is an estimate only, the Offline is
+	// failed by ActiveDeadlineSeconds.
+	MaxRuntimeSeconds *int64 `json:"maxRuntimeSeconds,omitempty"`
+	// ActiveDeadlineSeconds is how long the Offline may be active once its
+	// pods are created before the whole gang is failed.
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// SchedulingTimeoutSeconds is how long the Offline may take to get
+	// MinAvailable pods running once its pods are created.
+	SchedulingTimeoutSeconds *int64 `json:"schedulingTimeoutSeconds,omitempty"`
+	// TTLSecondsAfterFinished deletes the Offline and its pods this long
+	// after it succeeded or failed.
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+type TopologyMode string
+
+const (
+	//every pod of the gang on one node
+	TopologySameNode TopologyMode = "SameNode"
+	//every pod of the gang in one topology domain
+	TopologyPack TopologyMode = "Pack"
+	//every pod of the gang in a different topology domain
+	TopologySpread TopologyMode = "Spread"
+)
+
+// TopologyPolicy is how the pods of a gang are placed relative to each
+// other
+type TopologyPolicy struct {
+	// +kubebuilder:validation:Enum=SameNode;Pack;Spread
+	Mode TopologyMode `json:"mode"`
+	// TopologyKey is the node label whose values are the domains of Pack
+	// and Spread, the zone label when empty.
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// Required holds the Offline pending while no node layout of the
+	// cluster can satisfy the policy. The policy is a preference otherwise.
+	Required bool `json:"required,omitempty"`
+}
+
+type DependencyPhase string
+
+const (
+	//the dependency must succeed, its failure fails the dependent
+	DependencySucceeded DependencyPhase = "Succeeded"
+	//the dependency must either succeed or fail
+	DependencyCompleted DependencyPhase = "Completed"
+)
+
+// Dependency is an Offline another Offline waits for
+type Dependency struct {
+	Name string `json:"name"`
+	// Phase the dependency must reach, Succeeded when empty.
+	// +kubebuilder:validation:Enum=Succeeded;Completed
+	Phase DependencyPhase `json:"phase,omitempty"`
+}
+
+type OfflinePhase string
+
+const (
+	//dependencies haven't completed yet
+	OfflineWaiting OfflinePhase = "Waiting"
+	//queued, no pod created yet
+	OfflinePending OfflinePhase = "Pending"
+	//pods created, fewer than minAvailable running
+	OfflineScheduling OfflinePhase = "Scheduling"
+	//running+succeeded>=minAvailable
+	OfflineRunning OfflinePhase = "Running"
+	//spec.suspend is set, or its pods are still being deleted after it was unset
+	OfflineSuspended OfflinePhase = "Suspended"
+	//succeeded
+	OfflineSucceeded OfflinePhase = "Succeeded"
+	//failed to schedule or to run, see the Failed condition
+	OfflineFailed OfflinePhase = "Failed"
+	//preempted or evicted, and running+succeeded<minAvailable
+	OfflineUnknown OfflinePhase = "Unknown"
+)
+
+type OfflineConditionType string
+
+const (
+	// OfflineAdmitted is true once the pods of the Offline are created, its
+	// reason is Backfilled when it was admitted ahead of the head of its
+	// queue.
+	OfflineAdmitted OfflineConditionType = "Admitted"
+	// OfflineSuspendedCondition is true while spec.suspend holds the
+	// Offline.
+	OfflineSuspendedCondition OfflineConditionType = "Suspended"
+	// OfflineFailedCondition is true once the Offline failed, its reason
+	// telling why when the controller failed it.
+	OfflineFailedCondition OfflineConditionType = "Failed"
+)
+
+// OfflineCondition is an aspect of the state of an Offline
+type OfflineCondition struct {
+	Type   OfflineConditionType   `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// Reason is a brief CamelCase word telling why the condition holds.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is when the condition last changed status.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// PodCounts counts the pods of an Offline by phase
+type PodCounts struct {
+	Pending   int32 `json:"pending,omitempty"`
+	Running   int32 `json:"running,omitempty"`
+	Succeeded int32 `json:"succeeded,omitempty"`
+	Failed    int32 `json:"failed,omitempty"`
+	Unknown   int32 `json:"unknown,omitempty"`
+	// Evicted counts the pods evicted under memory pressure.
+	Evicted int32 `json:"evicted,omitempty"`
+}
+
+// MemoryPressure is an action taken on a node under memory pressure
+type MemoryPressure struct {
+	Node string `json:"node"`
+	// Watermark is the highest watermark the node's memory usage crossed,
+	// Low, Middle, High or None.
+	Watermark string      `json:"watermark"`
+	Action    string      `json:"action"`
+	Time      metav1.Time `json:"time"`
+}
+
+// Transition is a change of the phase or reason of an Offline
+type Transition struct {
+	Phase OfflinePhase `json:"phase"`
+	// From is the phase the Offline left, empty when it was created.
+	From   OfflinePhase `json:"from,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+	Time   metav1.Time  `json:"time"`
+	// QueuePosition is the place of the Offline in its queue, from 1,
+	// while it waits there, 0 otherwise.
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+	// Attempt counts the times the Offline was unschedulable before.
+	Attempt int32 `json:"attempt,omitempty"`
+}
+
+// OfflineStatus defines the observed state of Offline
+type OfflineStatus struct {
+	Phase OfflinePhase `json:"phase,omitempty"`
+	// Reason is a brief CamelCase message indicating why the controller
+	// failed the Offline regardless of its pods' phases.
+	Reason     string             `json:"reason,omitempty"`
+	Conditions []OfflineCondition `json:"conditions,omitempty"`
+	Pods       PodCounts          `json:"pods,omitempty"`
+	// Replicas is the current number of pods of each elastic task.
+	Replicas map[string]int32 `json:"replicas,omitempty"`
+	// CurrentSize is the current number of pods of the Offline.
+	CurrentSize int32 `json:"currentSize,omitempty"`
+	// StartTime is when the Offline's pods were created by the controller.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the Offline succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// EffectivePriority is the Priority raised by the time the Offline has
+	// been waiting in its queue.
+	EffectivePriority *int32 `json:"effectivePriority,omitempty"`
+	// Backfilled is true when the Offline was admitted ahead of the head
+	// of its queue.
+	Backfilled bool `json:"backfilled,omitempty"`
+	// MemoryPressure is the last action taken against the Offline's pods
+	// because their node crossed a memory watermark.
+	MemoryPressure *MemoryPressure `json:"memoryPressure,omitempty"`
+	// History lists the latest transitions of the Offline, oldest first.
+	History []Transition `json:"history,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Queue",type=string,JSONPath=`.spec.queue`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Offline is the Schema for the offlines API
+type Offline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OfflineSpec   `json:"spec,omitempty"`
+	Status OfflineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OfflineList contains a list of Offline
+type OfflineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Offline `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Offline{}, &OfflineList{})
+}
@@ -0,0 +1,103 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/agent"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = colocationv1.AddToScheme(scheme)
+}
+
+func main() {
+	cfg := agent.DefaultConfig()
+	var nodeName, cgroupRoot, watermarks string
+	var period time.Duration
+	flag.StringVar(&nodeName, "node-name", os.Getenv("NODE_NAME"), "The node whose offline pods are isolated.")
+	flag.StringVar(&cgroupRoot, "cgroup-root", "/sys/fs/cgroup", "Where the cgroup v1 hierarchies are mounted.")
+	flag.StringVar(&cfg.KubepodsCgroup, "kubepods-cgroup", cfg.KubepodsCgroup, "The cgroup kubelet puts pods under.")
+	flag.StringVar(&cfg.OfflineCgroup, "offline-cgroup", cfg.OfflineCgroup, "The cgroup offline pods are moved to, under the kubepods cgroup.")
+	flag.Float64Var(&cfg.SafetyMargin, "safety-margin", cfg.SafetyMargin, "The part of the node never given to offline pods.")
+	flag.BoolVar(&cfg.CPUSetExclusion, "cpuset-exclusion", false, "Keep offline pods off the cpus online pods need.")
+	flag.StringVar(&watermarks, "watermarks", "", "A YAML file of the memory watermarks of each node pool. The defaults apply when unset.")
+	flag.DurationVar(&period, "sync-period", 10*time.Second, "How often cgroups are synced.")
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(func(o *zap.Options) {
+		o.Development = true
+	}))
+
+	if nodeName == "" {
+		setupLog.Info("--node-name or NODE_NAME is required")
+		os.Exit(1)
+	}
+	watermarkConfig := agent.DefaultWatermarkConfig()
+	if watermarks != "" {
+		var err error
+		if watermarkConfig, err = agent.LoadWatermarkConfig(watermarks); err != nil {
+			setupLog.Error(err, "unable to load watermarks", "file", watermarks)
+			os.Exit(1)
+		}
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to create clientset")
+		os.Exit(1)
+	}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+
+	a := &agent.Agent{
+		Client:     c,
+		Evictions:  clientset.CoreV1(),
+		FS:         &agent.HostCgroupFS{Root: cgroupRoot},
+		NodeName:   nodeName,
+		Config:     cfg,
+		Watermarks: watermarkConfig,
+		Recorder:   broadcaster.NewRecorder(scheme, corev1.EventSource{Component: "offline-agent", Host: nodeName}),
+		Log:        ctrl.Log.WithName("agent"),
+	}
+	setupLog.Info("starting agent", "node", nodeName)
+	a.Run(period, ctrl.SetupSignalHandler())
+}
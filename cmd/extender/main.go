@@ -0,0 +1,84 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/extender"
+	"github.com/YunWang/colocation/pkg/reclaim"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = colocationv1.AddToScheme(scheme)
+}
+
+func main() {
+	var addr, prefix, metricsAddr, topologyKey string
+	var safetyMargin float64
+	flag.StringVar(&addr, "bind-address", ":8888", "The address the extender verbs are served on.")
+	flag.StringVar(&prefix, "url-prefix", "/offline", "The urlPrefix kube-scheduler is configured with.")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&topologyKey, "topology-key", corev1.LabelZoneFailureDomain, "The node label gangs are kept together by.")
+	flag.Float64Var(&safetyMargin, "batch-safety-margin", reclaim.DefaultSafetyMargin,
+		"The part of a node's allocatable cpu and memory never given to offline pods.")
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(func(o *zap.Options) {
+		o.Development = true
+	}))
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: metricsAddr,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	e := extender.NewExtender(mgr.GetClient(), safetyMargin, topologyKey, ctrl.Log.WithName("extender"))
+	if err := e.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Offline")
+		os.Exit(1)
+	}
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		return e.Serve(addr, prefix, stop)
+	})); err != nil {
+		setupLog.Error(err, "unable to add extender server")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting extender", "address", addr)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running extender")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,454 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/topology"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func submit(ctx context.Context, o *options, args []string) error {
+	var file string
+	o.flags.StringVar(&file, "f", "", "The job file to submit.")
+	o.parse(args)
+	if file == "" {
+		o.flags.Usage()
+		return fmt.Errorf("-f is required")
+	}
+	job, err := LoadJob(file)
+	if err != nil {
+		return err
+	}
+	off, err := job.Offline(o.namespace)
+	if err != nil {
+		return err
+	}
+	if err := o.Client.Create(ctx, off); err != nil {
+		return err
+	}
+	fmt.Printf("offline/%s created\n", off.Name)
+	return nil
+}
+
+func list(ctx context.Context, o *options, args []string) error {
+	var all bool
+	var queue string
+	o.flags.BoolVar(&all, "all-namespaces", false, "List the Offlines of all namespaces.")
+	o.flags.BoolVar(&all, "A", false, "List the Offlines of all namespaces.")
+	o.flags.StringVar(&queue, "queue", "", "Only list the Offlines of this queue.")
+	o.parse(args)
+
+	offList := &colocationv1.OfflineList{}
+	opts := make([]client.ListOption, 0)
+	if !all {
+		opts = append(opts, client.InNamespace(o.namespace))
+	}
+	if err := o.Client.List(ctx, offList, opts...); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	if all {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tQUEUE\tLEVEL\tPHASE\tREADY\tAGE")
+	for i := range offList.Items {
+		off := &offList.Items[i]
+		if queue != "" && utils.GetOfflineQueueName(off) != queue {
+			continue
+		}
+		if all {
+			fmt.Fprintf(w, "%s\t", off.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d/%d\t%s\n", off.Name, utils.GetOfflineQueueName(off), off.Spec.Level,
+			phase(off), off.Status.PodRunning+off.Status.PodSucceeded, off.Spec.MinGang, age(off.CreationTimestamp))
+	}
+	return w.Flush()
+}
+
+func describe(ctx context.Context, o *options, args []string) error {
+	name, err := o.name(args)
+	if err != nil {
+		return err
+	}
+	off := &colocationv1.Offline{}
+	if err := o.Client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, off); err != nil {
+		return err
+	}
+	pods, err := offlinePods(ctx, o.Client, off)
+	if err != nil {
+		return err
+	}
+	offList := &colocationv1.OfflineList{}
+	if err := o.Client.List(ctx, offList); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", off.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", off.Namespace)
+	fmt.Fprintf(w, "Queue:\t%s\n", utils.GetOfflineQueueName(off))
+	fmt.Fprintf(w, "Queue Position:\t%s\n", queuePosition(off, offList.Items))
+	fmt.Fprintf(w, "Level:\t%d\n", off.Spec.Level)
+	fmt.Fprintf(w, "Effective Priority:\t%d\n", utils.Priority(off))
+	fmt.Fprintf(w, "Min Gang:\t%d\n", off.Spec.MinGang)
+	fmt.Fprintf(w, "Phase:\t%s\n", phase(off))
+	if off.Status.Reason != "" {
+		fmt.Fprintf(w, "Reason:\t%s\n", off.Status.Reason)
+	}
+	if off.Status.StartTime != nil {
+		fmt.Fprintf(w, "Start Time:\t%s\n", off.Status.StartTime.Format(time.RFC3339))
+	}
+	if off.Status.CompletionTime != nil {
+		fmt.Fprintf(w, "Completion Time:\t%s\n", off.Status.CompletionTime.Format(time.RFC3339))
+	}
+	if t := off.Spec.Topology; t != nil {
+		required := "preferred"
+		if t.Required {
+			required = "required"
+		}
+		fmt.Fprintf(w, "Topology:\t%s by %s (%s)\n", t.Mode, topology.Key(t), required)
+	}
+	if len(off.Spec.DependsOn) > 0 {
+		names := make([]string, 0, len(off.Spec.DependsOn))
+		for _, dep := range off.Spec.DependsOn {
+			names = append(names, dep.Name)
+		}
+		fmt.Fprintf(w, "Depends On:\t%s\n", strings.Join(names, ", "))
+	}
+	fmt.Fprintf(w, "Pods Status:\t%d Running / %d Pending / %d Succeeded / %d Failed / %d Unknown\n",
+		off.Status.PodRunning, off.Status.PodPending, off.Status.PodSucceeded, off.Status.PodFailed, off.Status.PodUnknown)
+	for _, task := range off.Spec.Elastic {
+		fmt.Fprintf(w, "Elastic %s:\t%d (%d-%d)\n", task.Name, off.Status.ElasticReplicas[task.Name], task.MinReplicas, task.MaxReplicas)
+	}
+
+	fmt.Fprintln(w, "Conditions:")
+	fmt.Fprintln(w, "  Type\tStatus\tReason")
+	fmt.Fprintf(w, "  Backfilled\t%t\t\n", off.Status.Backfilled)
+	if p := off.Status.MemoryPressure; p != nil {
+		fmt.Fprintf(w, "  MemoryPressure\t%t\t%s on %s above the %s watermark, %s ago\n",
+			p.Action != colocationv1.PressureRelievedAction, p.Action, p.Node, p.Watermark, age(p.Time))
+	}
+	if off.Status.PodEvicted > 0 {
+		fmt.Fprintf(w, "  Evicted\t%d\t\n", off.Status.PodEvicted)
+	}
+
+	if len(off.Status.History) > 0 {
+		fmt.Fprintln(w, "History:")
+		fmt.Fprintln(w, "  Phase\tFrom\tReason\tAge\tQueue Position\tAttempt")
+		for _, t := range off.Status.History {
+			position := "-"
+			if t.QueuePosition > 0 {
+				position = fmt.Sprint(t.QueuePosition)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%d\n", t.Phase, t.From, t.Reason, age(t.Time), position, t.Attempt)
+		}
+	}
+
+	fmt.Fprintln(w, "Pods:")
+	fmt.Fprintln(w, "  Name\tPhase\tNode\tConditions")
+	for _, pod := range pods {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", pod.Name, pod.Status.Phase, pod.Spec.NodeName, podConditions(&pod))
+	}
+
+	events := &corev1.EventList{}
+	if err := o.Client.List(ctx, events, client.InNamespace(off.Namespace)); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Events:")
+	fmt.Fprintln(w, "  Type\tReason\tAge\tMessage")
+	for _, event := range events.Items {
+		if event.InvolvedObject.UID != off.UID {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", event.Type, event.Reason, age(event.LastTimestamp), event.Message)
+	}
+	return w.Flush()
+}
+
+func queues(ctx context.Context, o *options, args []string) error {
+	o.parse(args)
+	offList := &colocationv1.OfflineList{}
+	if err := o.Client.List(ctx, offList); err != nil {
+		return err
+	}
+	type summary struct {
+		admitted                                            []string
+		pending, waiting, unschedulable, running, suspended int
+	}
+	summaries := make(map[string]*summary)
+	for i := range offList.Items {
+		off := &offList.Items[i]
+		name := utils.GetOfflineQueueName(off)
+		s, ok := summaries[name]
+		if !ok {
+			s = &summary{}
+			summaries[name] = s
+		}
+		switch phase(off) {
+		case colocationv1.OfflineSchedulingPhase:
+			s.admitted = append(s.admitted, types.NamespacedName{Namespace: off.Namespace, Name: off.Name}.String())
+		case colocationv1.OfflinePendingPhase:
+			s.pending++
+		case colocationv1.OfflineWaitingPhase:
+			s.waiting++
+		case colocationv1.OfflineRunningPhase:
+			s.running++
+		case colocationv1.OfflineSuspendedPhase:
+			s.suspended++
+		case colocationv1.OfflineFailedPhase:
+			//only failures the controller retries wait in the unschedulable queue
+			if off.Status.Reason == "" {
+				s.unschedulable++
+			}
+		}
+	}
+	names := make([]string, 0, len(summaries))
+	for name := range summaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "QUEUE\tADMITTED\tPENDING\tWAITING\tUNSCHEDULABLE\tRUNNING\tSUSPENDED")
+	for _, name := range names {
+		s := summaries[name]
+		admitted := "<none>"
+		if len(s.admitted) > 0 {
+			admitted = strings.Join(s.admitted, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\n", name, admitted, s.pending, s.waiting, s.unschedulable, s.running, s.suspended)
+	}
+	return w.Flush()
+}
+
+func suspend(ctx context.Context, o *options, args []string) error {
+	return setSuspend(ctx, o, args, true)
+}
+
+func resume(ctx context.Context, o *options, args []string) error {
+	return setSuspend(ctx, o, args, false)
+}
+
+// setSuspend sets spec.suspend of an Offline, the controller deletes the
+// pods of a suspended Offline and queues it again once resumed.
+func setSuspend(ctx context.Context, o *options, args []string, suspend bool) error {
+	name, err := o.name(args)
+	if err != nil {
+		return err
+	}
+	off := &colocationv1.Offline{}
+	if err := o.Client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, off); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(off.DeepCopy())
+	off.Spec.Suspend = &suspend
+	if err := o.Client.Patch(ctx, off, patch); err != nil {
+		return err
+	}
+	if suspend {
+		fmt.Printf("offline/%s suspended\n", name)
+	} else {
+		fmt.Printf("offline/%s resumed\n", name)
+	}
+	return nil
+}
+
+func logs(ctx context.Context, o *options, args []string) error {
+	var follow bool
+	var tail int64
+	var container string
+	o.flags.BoolVar(&follow, "follow", false, "Stream the logs as they are written.")
+	o.flags.BoolVar(&follow, "f", false, "Stream the logs as they are written.")
+	o.flags.Int64Var(&tail, "tail", -1, "The number of lines to show from the end of each log, all when negative.")
+	o.flags.StringVar(&container, "c", "", "The container to show the logs of, the only one when empty.")
+	name, err := o.name(args)
+	if err != nil {
+		return err
+	}
+	off := &colocationv1.Offline{}
+	if err := o.Client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, off); err != nil {
+		return err
+	}
+	pods, err := offlinePods(ctx, o.Client, off)
+	if err != nil {
+		return err
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(chan error, len(pods))
+	for _, pod := range pods {
+		opts := &corev1.PodLogOptions{Follow: follow, Container: container}
+		if tail >= 0 {
+			opts.TailLines = &tail
+		}
+		stream, err := o.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", pod.Name, err)
+			continue
+		}
+		wg.Add(1)
+		go func(pod string, stream io.ReadCloser) {
+			defer wg.Done()
+			defer stream.Close()
+			scanner := bufio.NewScanner(stream)
+			for scanner.Scan() {
+				lock.Lock()
+				fmt.Printf("[%s] %s\n", pod, scanner.Text())
+				lock.Unlock()
+			}
+			if err := scanner.Err(); err != nil {
+				errs <- fmt.Errorf("%s: %v", pod, err)
+			}
+		}(pod.Name, stream)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func remove(ctx context.Context, o *options, args []string) error {
+	var waitDeleted bool
+	var timeout time.Duration
+	o.flags.BoolVar(&waitDeleted, "wait", false, "Wait until the Offline and its pods are gone.")
+	o.flags.DurationVar(&timeout, "timeout", 5*time.Minute, "How long to wait with --wait.")
+	name, err := o.name(args)
+	if err != nil {
+		return err
+	}
+	off := &colocationv1.Offline{}
+	key := types.NamespacedName{Namespace: o.namespace, Name: name}
+	if err := o.Client.Get(ctx, key, off); err != nil {
+		return err
+	}
+	if err := o.Client.Delete(ctx, off); err != nil {
+		return err
+	}
+	fmt.Printf("offline/%s deleted\n", name)
+	if !waitDeleted {
+		return nil
+	}
+	return wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		if err := o.Client.Get(ctx, key, &colocationv1.Offline{}); !errors.IsNotFound(err) {
+			return false, client.IgnoreNotFound(err)
+		}
+		pods, err := offlinePods(ctx, o.Client, off)
+		return len(pods) == 0, err
+	})
+}
+
+// offlinePods returns the pods of an Offline, sorted by name.
+func offlinePods(ctx context.Context, c client.Client, off *colocationv1.Offline) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	selector := labels.Everything()
+	if off.Spec.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(off.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.List(ctx, podList, client.InNamespace(off.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	pods := make([]corev1.Pod, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.UID == off.UID {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// queuePosition returns where a pending Offline is in its queue, ordered
+// the way the controller orders it.
+func queuePosition(off *colocationv1.Offline, offs []colocationv1.Offline) string {
+	switch phase(off) {
+	case colocationv1.OfflinePendingPhase:
+	case colocationv1.OfflineSchedulingPhase:
+		return "admitted"
+	default:
+		return "-"
+	}
+	queue := utils.GetOfflineQueueName(off)
+	pending := make([]*colocationv1.Offline, 0)
+	for i := range offs {
+		if utils.GetOfflineQueueName(&offs[i]) == queue && phase(&offs[i]) == colocationv1.OfflinePendingPhase {
+			pending = append(pending, &offs[i])
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return utils.LessFn(pending[i], pending[j]) })
+	for i, other := range pending {
+		if other.UID == off.UID {
+			return fmt.Sprintf("%d of %d", i+1, len(pending))
+		}
+	}
+	return "-"
+}
+
+// podConditions lists the conditions of a pod that don't hold.
+func podConditions(pod *corev1.Pod) string {
+	unmet := make([]string, 0)
+	for _, condition := range pod.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue {
+			continue
+		}
+		if condition.Reason != "" {
+			unmet = append(unmet, fmt.Sprintf("%s=%s(%s)", condition.Type, condition.Status, condition.Reason))
+		} else {
+			unmet = append(unmet, fmt.Sprintf("%s=%s", condition.Type, condition.Status))
+		}
+	}
+	if len(unmet) == 0 {
+		return "<none>"
+	}
+	return strings.Join(unmet, ",")
+}
+
+func phase(off *colocationv1.Offline) colocationv1.OfflinePhase {
+	if off.Status.Phase == "" {
+		return colocationv1.OfflinePendingPhase
+	}
+	return off.Status.Phase
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
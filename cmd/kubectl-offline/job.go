@@ -0,0 +1,186 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// JobLabel is set on the pods of a submitted job, its value is the
+	// name of the Offline.
+	JobLabel = "colocation.cmyun.io/job"
+	// TaskLabel is set on the pods of a submitted job, its value is the
+	// name of the task.
+	TaskLabel = "colocation.cmyun.io/task"
+)
+
+// Job is the compact form of an Offline submitted from a file.
+type Job struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Queue     string `json:"queue,omitempty"`
+	Level     int32  `json:"level,omitempty"`
+	// MinGang defaults to the replicas of all tasks.
+	MinGang                  int32    `json:"minGang,omitempty"`
+	MaxRuntimeSeconds        *int64   `json:"maxRuntimeSeconds,omitempty"`
+	ActiveDeadlineSeconds    *int64   `json:"activeDeadlineSeconds,omitempty"`
+	SchedulingTimeoutSeconds *int64   `json:"schedulingTimeoutSeconds,omitempty"`
+	TTLSecondsAfterFinished  *int32   `json:"ttlSecondsAfterFinished,omitempty"`
+	DependsOn                []string `json:"dependsOn,omitempty"`
+	// Topology is the spec.topology of the Offline.
+	Topology *colocationv1.TopologyPolicy `json:"topology,omitempty"`
+	Tasks    []Task                       `json:"tasks"`
+}
+
+// Task is a group of identical pods of a Job.
+type Task struct {
+	Name string `json:"name"`
+	// Replicas defaults to 1.
+	Replicas int32 `json:"replicas,omitempty"`
+	// MaxReplicas makes the task elastic, growing from Replicas up to
+	// MaxReplicas pods while the cluster is idle.
+	MaxReplicas int32             `json:"maxReplicas,omitempty"`
+	Image       string            `json:"image"`
+	Command     []string          `json:"command,omitempty"`
+	Args        []string          `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	CPU         string            `json:"cpu,omitempty"`
+	Memory      string            `json:"memory,omitempty"`
+}
+
+// LoadJob reads a Job in YAML or JSON.
+func LoadJob(path string) (*Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := yaml.UnmarshalStrict(data, job); err != nil {
+		return nil, err
+	}
+	if job.Name == "" {
+		return nil, fmt.Errorf("%s: name is required", path)
+	}
+	if len(job.Tasks) == 0 {
+		return nil, fmt.Errorf("%s: at least one task is required", path)
+	}
+	return job, nil
+}
+
+// Offline expands the Job into the Offline the controller runs.
+func (j *Job) Offline(namespace string) (*colocationv1.Offline, error) {
+	if j.Namespace != "" {
+		namespace = j.Namespace
+	}
+	off := &colocationv1.Offline{
+		ObjectMeta: metav1.ObjectMeta{Name: j.Name, Namespace: namespace},
+		Spec: colocationv1.OfflineSpec{
+			Level:                    j.Level,
+			Queue:                    j.Queue,
+			Selector:                 &metav1.LabelSelector{MatchLabels: map[string]string{JobLabel: j.Name}},
+			MaxRuntimeSeconds:        j.MaxRuntimeSeconds,
+			ActiveDeadlineSeconds:    j.ActiveDeadlineSeconds,
+			SchedulingTimeoutSeconds: j.SchedulingTimeoutSeconds,
+			TTLSecondsAfterFinished:  j.TTLSecondsAfterFinished,
+			Topology:                 j.Topology,
+		},
+	}
+	for _, name := range j.DependsOn {
+		off.Spec.DependsOn = append(off.Spec.DependsOn, colocationv1.OfflineDependency{Name: name})
+	}
+	var replicas int32
+	for _, task := range j.Tasks {
+		template, err := j.template(task)
+		if err != nil {
+			return nil, err
+		}
+		n := task.Replicas
+		if n == 0 {
+			n = 1
+		}
+		replicas += n
+		if task.MaxReplicas > 0 {
+			if task.MaxReplicas < n {
+				return nil, fmt.Errorf("task %s: maxReplicas is less than replicas", task.Name)
+			}
+			off.Spec.Elastic = append(off.Spec.Elastic, colocationv1.ElasticTask{
+				Name:        task.Name,
+				Template:    template,
+				MinReplicas: n,
+				MaxReplicas: task.MaxReplicas,
+			})
+			continue
+		}
+		for i := int32(0); i < n; i++ {
+			off.Spec.Tasks = append(off.Spec.Tasks, template.DeepCopy())
+		}
+	}
+	off.Spec.MinGang = j.MinGang
+	if off.Spec.MinGang == 0 {
+		off.Spec.MinGang = replicas
+	}
+	return off, nil
+}
+
+func (j *Job) template(task Task) (*corev1.PodTemplateSpec, error) {
+	if task.Name == "" || task.Image == "" {
+		return nil, fmt.Errorf("every task needs a name and an image")
+	}
+	container := corev1.Container{
+		Name:    task.Name,
+		Image:   task.Image,
+		Command: task.Command,
+		Args:    task.Args,
+	}
+	names := make([]string, 0, len(task.Env))
+	for name := range task.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: task.Env[name]})
+	}
+	resources := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: task.CPU, corev1.ResourceMemory: task.Memory} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %v", task.Name, err)
+		}
+		resources[name] = quantity
+	}
+	if len(resources) > 0 {
+		container.Resources = corev1.ResourceRequirements{Requests: resources, Limits: resources.DeepCopy()}
+	}
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{JobLabel: j.Name, TaskLabel: task.Name}},
+		Spec: corev1.PodSpec{
+			Containers:    []corev1.Container{container},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}, nil
+}
@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+import (
+	"io/ioutil"
+	"os"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("Job", func() {
+	It("expands tasks into one template per replica", func() {
+		job := &Job{Name: "train", Queue: "ml", Level: 3, DependsOn: []string{"prepare"}, Tasks: []Task{
+			{Name: "ps", Image: "ps:1", CPU: "1", Memory: "1Gi"},
+			{Name: "worker", Image: "worker:1", Replicas: 2, Env: map[string]string{"B": "2", "A": "1"}},
+		}}
+		off, err := job.Offline("default")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(off.Namespace).To(Equal("default"))
+		Expect(off.Name).To(Equal("train"))
+		Expect(off.Spec.Queue).To(Equal("ml"))
+		Expect(off.Spec.Level).To(Equal(int32(3)))
+		Expect(off.Spec.Selector.MatchLabels).To(Equal(map[string]string{JobLabel: "train"}))
+		Expect(off.Spec.DependsOn).To(Equal([]colocationv1.OfflineDependency{{Name: "prepare"}}))
+		Expect(off.Spec.Tasks).To(HaveLen(3))
+		Expect(off.Spec.MinGang).To(Equal(int32(3)))
+
+		ps := off.Spec.Tasks[0]
+		Expect(ps.Labels).To(Equal(map[string]string{JobLabel: "train", TaskLabel: "ps"}))
+		Expect(ps.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(ps.Spec.Containers[0].Resources.Requests).To(Equal(corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}))
+		Expect(ps.Spec.Containers[0].Resources.Limits).To(Equal(ps.Spec.Containers[0].Resources.Requests))
+
+		worker := off.Spec.Tasks[1]
+		Expect(worker.Spec.Containers[0].Image).To(Equal("worker:1"))
+		Expect(worker.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}))
+		Expect(worker.Spec.Containers[0].Resources.Requests).To(BeEmpty())
+		Expect(off.Spec.Tasks[2]).NotTo(BeIdenticalTo(worker))
+	})
+
+	It("prefers the namespace of the job and keeps an explicit minGang", func() {
+		job := &Job{Name: "train", Namespace: "ml", MinGang: 1, Tasks: []Task{{Name: "worker", Image: "worker:1", Replicas: 4}}}
+		off, err := job.Offline("default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(off.Namespace).To(Equal("ml"))
+		Expect(off.Spec.MinGang).To(Equal(int32(1)))
+	})
+
+	It("makes tasks with maxReplicas elastic", func() {
+		job := &Job{Name: "train", Tasks: []Task{
+			{Name: "ps", Image: "ps:1"},
+			{Name: "worker", Image: "worker:1", Replicas: 2, MaxReplicas: 5},
+		}}
+		off, err := job.Offline("default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(off.Spec.Tasks).To(HaveLen(1))
+		Expect(off.Spec.Elastic).To(HaveLen(1))
+		Expect(off.Spec.Elastic[0].Name).To(Equal("worker"))
+		Expect(off.Spec.Elastic[0].MinReplicas).To(Equal(int32(2)))
+		Expect(off.Spec.Elastic[0].MaxReplicas).To(Equal(int32(5)))
+		Expect(off.Spec.MinGang).To(Equal(int32(3)))
+	})
+
+	It("rejects invalid tasks", func() {
+		for _, task := range []Task{
+			{Name: "worker"},
+			{Image: "worker:1"},
+			{Name: "worker", Image: "worker:1", CPU: "a lot"},
+			{Name: "worker", Image: "worker:1", Replicas: 3, MaxReplicas: 2},
+		} {
+			_, err := (&Job{Name: "train", Tasks: []Task{task}}).Offline("default")
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("loads a job strictly", func() {
+		file, err := ioutil.TempFile("", "job")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString("name: train\ntasks:\n- name: worker\n  image: worker:1\n  replicas: 2\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		job, err := LoadJob(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Tasks).To(Equal([]Task{{Name: "worker", Image: "worker:1", Replicas: 2}}))
+
+		Expect(ioutil.WriteFile(file.Name(), []byte("name: train\nreplicas: 2\ntasks: []\n"), 0644)).To(Succeed())
+		_, err = LoadJob(file.Name())
+		Expect(err).To(HaveOccurred())
+	})
+})
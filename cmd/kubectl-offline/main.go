@@ -0,0 +1,153 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-offline is a kubectl plugin to submit and inspect Offline jobs
+// and their queues.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = colocationv1.AddToScheme(scheme)
+}
+
+// command is a subcommand run with its own flags.
+type command struct {
+	usage string
+	run   func(ctx context.Context, o *options, args []string) error
+}
+
+var commands = map[string]command{
+	"submit":   {"submit -f FILE", submit},
+	"list":     {"list [--all-namespaces] [--queue QUEUE]", list},
+	"describe": {"describe NAME", describe},
+	"queues":   {"queues", queues},
+	"suspend":  {"suspend NAME", suspend},
+	"resume":   {"resume NAME", resume},
+	"logs":     {"logs NAME [--follow] [--tail N]", logs},
+	"delete":   {"delete NAME [--wait] [--timeout DURATION]", remove},
+}
+
+var order = []string{"submit", "list", "describe", "queues", "suspend", "resume", "logs", "delete"}
+
+// options are the flags shared by all commands and the clients built from
+// them.
+type options struct {
+	flags     *flag.FlagSet
+	namespace string
+
+	Client    client.Client
+	Clientset kubernetes.Interface
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kubectl offline [--kubeconfig FILE] COMMAND [-n NAMESPACE] ...\n\nCommands:\n")
+	for _, name := range order {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	//--kubeconfig is registered by controller-runtime
+	if kubeconfig := flag.Lookup("kubeconfig"); kubeconfig != nil {
+		loadingRules.ExplicitPath = kubeconfig.Value.String()
+	}
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	o := &options{flags: flag.NewFlagSet(flag.Arg(0), flag.ExitOnError)}
+	o.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl offline %s\n", cmd.usage)
+		o.flags.PrintDefaults()
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		namespace = "default"
+	}
+	o.flags.StringVar(&o.namespace, "n", namespace, "The namespace of the Offline.")
+	o.flags.StringVar(&o.namespace, "namespace", namespace, "The namespace of the Offline.")
+
+	if err := o.connect(clientConfig); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := cmd.run(context.Background(), o, flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func (o *options) connect(clientConfig clientcmd.ClientConfig) error {
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if o.Client, err = client.New(restConfig, client.Options{Scheme: scheme}); err != nil {
+		return err
+	}
+	o.Clientset, err = kubernetes.NewForConfig(restConfig)
+	return err
+}
+
+// parse parses the flags of a command wherever they are among its
+// arguments and returns the positional arguments.
+func (o *options) parse(args []string) []string {
+	positional := make([]string, 0)
+	for {
+		_ = o.flags.Parse(args)
+		if o.flags.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, o.flags.Arg(0))
+		args = o.flags.Args()[1:]
+	}
+}
+
+// name parses the flags of a command taking the name of one Offline.
+func (o *options) name(args []string) (string, error) {
+	positional := o.parse(args)
+	if len(positional) != 1 {
+		o.flags.Usage()
+		return "", fmt.Errorf("expected the name of one offline, got %d arguments", len(positional))
+	}
+	return positional[0], nil
+}
@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// simulate replays a trace of Offline submissions against a synthetic node
+// inventory in virtual time and compares the scheduling policies of the
+// controller by wait time, utilisation, starvation and preemptions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/YunWang/colocation/pkg/simulate"
+)
+
+func main() {
+	var nodesPath, tracePath, policiesPath, output string
+	var starvation time.Duration
+	flag.StringVar(&nodesPath, "nodes", "", "The YAML or JSON file listing the node inventory.")
+	flag.StringVar(&tracePath, "trace", "", "The trace of Offline submissions, CSV when the file ends in .csv, else YAML or JSON.")
+	flag.StringVar(&policiesPath, "policies", "", "The YAML or JSON file listing the policies to compare, built-in ones when empty.")
+	flag.DurationVar(&starvation, "starvation-threshold", time.Hour, "The wait after which an Offline counts as starved.")
+	flag.StringVar(&output, "output", "table", "The report format, table or json.")
+	flag.Parse()
+
+	if nodesPath == "" || tracePath == "" {
+		fmt.Fprintln(os.Stderr, "--nodes and --trace are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	inventory, err := simulate.LoadInventory(nodesPath)
+	if err != nil {
+		fail(err)
+	}
+	trace, err := simulate.LoadTrace(tracePath)
+	if err != nil {
+		fail(err)
+	}
+	policies := simulate.DefaultPolicies()
+	if policiesPath != "" {
+		if policies, err = simulate.LoadPolicies(policiesPath); err != nil {
+			fail(err)
+		}
+	}
+
+	reports := make([]*simulate.Report, 0, len(policies))
+	for _, policy := range policies {
+		report, err := simulate.Run(inventory, trace, policy, starvation)
+		if err != nil {
+			fail(err)
+		}
+		reports = append(reports, report)
+	}
+
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			fail(err)
+		}
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "POLICY\tCOMPLETED\tUNFINISHED\tWAIT P50\tWAIT P90\tWAIT P99\tWAIT MAX\tCPU\tMEMORY\tSTARVED\tPREEMPTED\tBACKFILLED\tMAKESPAN")
+		for _, r := range reports {
+			fmt.Fprintf(w, "%s\t%d/%d\t%d\t%v\t%v\t%v\t%v\t%.1f%%\t%.1f%%\t%d\t%d\t%d\t%v\n",
+				r.Policy, r.Completed, r.Submitted, r.Unfinished,
+				r.WaitP50, r.WaitP90, r.WaitP99, r.WaitMax,
+				r.CPUUtilisation*100, r.MemoryUtilisation*100,
+				r.Starved, r.Preemptions, r.Backfilled, r.Makespan)
+		}
+		w.Flush()
+	default:
+		fail(fmt.Errorf("unknown output %q", output))
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
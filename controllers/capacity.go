@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
+
+// freeCapacity returns the allocatable resources of schedulable nodes that
+// aren't requested by pods bound to them. Pods owned by exclude are counted
+// as free so its whole gang can be compared against the result.
+func freeCapacity(ctx context.Context, c client.Client, exclude *colocationv1.Offline) (corev1.ResourceList, error) {
+	_, free, err := clusterCapacity(ctx, c, exclude)
+	return free, err
+}
+
+// clusterCapacity returns the allocatable resources of schedulable nodes and
+// the part of them freeCapacity considers free.
+func clusterCapacity(ctx context.Context, c client.Client, exclude *colocationv1.Offline) (corev1.ResourceList, corev1.ResourceList, error) {
+	nodeList := &corev1.NodeList{}
+	if err := c.List(ctx, nodeList); err != nil {
+		return nil, nil, err
+	}
+	total := corev1.ResourceList{}
+	schedulable := make(map[string]bool)
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		schedulable[node.Name] = true
+		utils.AddResources(total, node.Status.Allocatable)
+	}
+
+	free := total.DeepCopy()
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList, &client.ListOptions{}); err != nil {
+		return nil, nil, err
+	}
+	for _, pod := range podList.Items {
+		if !schedulable[pod.Spec.NodeName] {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if exclude != nil && metav1.IsControlledBy(&pod, exclude) {
+			continue
+		}
+		utils.SubResources(free, utils.PodRequests(&pod.Spec))
+	}
+	return total, free, nil
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkDeadlines returns the reason to fail a started offline past its
+// active deadline or its scheduling timeout, or else how long until the
+// nearest of them.
+func checkDeadlines(off *colocationv1.Offline, now time.Time) (string, time.Duration) {
+	if off.Status.StartTime == nil {
+		return "", 0
+	}
+	var next time.Duration
+	active := off.Status.Phase == colocationv1.OfflineSchedulingPhase || off.Status.Phase == colocationv1.OfflineRunningPhase
+	if seconds := off.Spec.ActiveDeadlineSeconds; seconds != nil && active {
+		remaining := off.Status.StartTime.Add(time.Duration(*seconds) * time.Second).Sub(now)
+		if remaining <= 0 {
+			return colocationv1.OfflineDeadlineExceededReason, 0
+		}
+		next = remaining
+	}
+	if seconds := off.Spec.SchedulingTimeoutSeconds; seconds != nil && off.Status.Phase == colocationv1.OfflineSchedulingPhase {
+		remaining := off.Status.StartTime.Add(time.Duration(*seconds) * time.Second).Sub(now)
+		if remaining <= 0 {
+			return colocationv1.OfflineSchedulingTimeoutReason, 0
+		}
+		if next == 0 || remaining < next {
+			next = remaining
+		}
+	}
+	return "", next
+}
+
+// isFinished tells whether the offline succeeded or failed.
+func isFinished(off *colocationv1.Offline) bool {
+	return isFinishedPhase(off.Status.Phase)
+}
+
+func isFinishedPhase(phase colocationv1.OfflinePhase) bool {
+	return phase == colocationv1.OfflineSucceededPhase || phase == colocationv1.OfflineFailedPhase
+}
+
+// expireAfterFinished records when the offline finished and returns how
+// long until its TTLSecondsAfterFinished expires. It returns false when
+// the offline isn't finished or has no TTL.
+func expireAfterFinished(off *colocationv1.Offline, now time.Time) (time.Duration, bool) {
+	if !isFinished(off) {
+		off.Status.CompletionTime = nil
+		return 0, false
+	}
+	if off.Status.CompletionTime == nil {
+		off.Status.CompletionTime = &v1.Time{Time: now}
+	}
+	if off.Spec.TTLSecondsAfterFinished == nil {
+		return 0, false
+	}
+	return off.Status.CompletionTime.Add(time.Duration(*off.Spec.TTLSecondsAfterFinished) * time.Second).Sub(now), true
+}
+
+// deletePods deletes every pod not being deleted yet.
+func (r *OfflineReconciler) deletePods(ctx context.Context, pods []corev1.Pod) error {
+	for i := range pods {
+		if !pods[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &pods[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteExpired deletes a finished offline whose TTL expired, its pods are
+// garbage collected with it.
+func (r *OfflineReconciler) deleteExpired(ctx context.Context, off *colocationv1.Offline) error {
+	r.Log.V(0).Info("Offline{" + off.Name + "} expired after finishing")
+	err := r.Client.Delete(ctx, off, client.PropagationPolicy(v1.DeletePropagationBackground))
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// requeueAfter makes result requeue after d unless it already requeues
+// sooner.
+func requeueAfter(result *ctrl.Result, d time.Duration) {
+	if result.RequeueAfter == 0 || d < result.RequeueAfter {
+		result.RequeueAfter = d
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Deadlines", func() {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	seconds := func(s int64) *int64 {
+		return &s
+	}
+	started := func(phase colocationv1.OfflinePhase) *colocationv1.Offline {
+		off := &colocationv1.Offline{}
+		off.Status.Phase = phase
+		off.Status.StartTime = &v1.Time{Time: start}
+		return off
+	}
+
+	It("ignores an offline that hasn't started", func() {
+		off := started(colocationv1.OfflineRunningPhase)
+		off.Status.StartTime = nil
+		off.Spec.ActiveDeadlineSeconds = seconds(1)
+		reason, next := checkDeadlines(off, start.Add(time.Hour))
+		Expect(reason).To(BeEmpty())
+		Expect(next).To(BeZero())
+	})
+
+	It("fails an active offline past its active deadline", func() {
+		off := started(colocationv1.OfflineRunningPhase)
+		off.Spec.ActiveDeadlineSeconds = seconds(60)
+		reason, next := checkDeadlines(off, start.Add(45*time.Second))
+		Expect(reason).To(BeEmpty())
+		Expect(next).To(Equal(15 * time.Second))
+
+		reason, _ = checkDeadlines(off, start.Add(time.Minute))
+		Expect(reason).To(Equal(colocationv1.OfflineDeadlineExceededReason))
+	})
+
+	It("doesn't apply the active deadline to a finished offline", func() {
+		off := started(colocationv1.OfflineSucceededPhase)
+		off.Spec.ActiveDeadlineSeconds = seconds(60)
+		reason, next := checkDeadlines(off, start.Add(time.Hour))
+		Expect(reason).To(BeEmpty())
+		Expect(next).To(BeZero())
+	})
+
+	It("fails an offline whose gang doesn't gather in time", func() {
+		off := started(colocationv1.OfflineSchedulingPhase)
+		off.Spec.SchedulingTimeoutSeconds = seconds(30)
+		reason, _ := checkDeadlines(off, start.Add(30*time.Second))
+		Expect(reason).To(Equal(colocationv1.OfflineSchedulingTimeoutReason))
+
+		off.Status.Phase = colocationv1.OfflineRunningPhase
+		reason, _ = checkDeadlines(off, start.Add(time.Hour))
+		Expect(reason).To(BeEmpty())
+	})
+
+	It("returns the nearest deadline", func() {
+		off := started(colocationv1.OfflineSchedulingPhase)
+		off.Spec.ActiveDeadlineSeconds = seconds(60)
+		off.Spec.SchedulingTimeoutSeconds = seconds(30)
+		_, next := checkDeadlines(off, start.Add(10*time.Second))
+		Expect(next).To(Equal(20 * time.Second))
+	})
+})
+
+var _ = Describe("TTL after finished", func() {
+	finished := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ttl := func(s int32) *int32 {
+		return &s
+	}
+
+	It("records when the offline finished and counts down from it", func() {
+		off := &colocationv1.Offline{}
+		off.Status.Phase = colocationv1.OfflineFailedPhase
+		off.Spec.TTLSecondsAfterFinished = ttl(60)
+
+		remaining, ok := expireAfterFinished(off, finished)
+		Expect(ok).To(BeTrue())
+		Expect(remaining).To(Equal(time.Minute))
+		Expect(off.Status.CompletionTime.Time).To(Equal(finished))
+
+		remaining, ok = expireAfterFinished(off, finished.Add(90*time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(remaining).To(Equal(-30 * time.Second))
+		Expect(off.Status.CompletionTime.Time).To(Equal(finished))
+	})
+
+	It("records the completion of an offline without a TTL", func() {
+		off := &colocationv1.Offline{}
+		off.Status.Phase = colocationv1.OfflineSucceededPhase
+		_, ok := expireAfterFinished(off, finished)
+		Expect(ok).To(BeFalse())
+		Expect(off.Status.CompletionTime).NotTo(BeNil())
+	})
+
+	It("forgets the completion of an offline running again", func() {
+		off := &colocationv1.Offline{}
+		off.Status.Phase = colocationv1.OfflinePendingPhase
+		off.Status.CompletionTime = &v1.Time{Time: finished}
+		off.Spec.TTLSecondsAfterFinished = ttl(60)
+		_, ok := expireAfterFinished(off, finished)
+		Expect(ok).To(BeFalse())
+		Expect(off.Status.CompletionTime).To(BeNil())
+	})
+})
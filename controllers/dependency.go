@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// checkDependencies reports whether every dependency of off has reached its
+// required phase. A non empty reason means off can never run: a dependency
+// required to succeed failed or the dependencies form a cycle.
+func (r *OfflineReconciler) checkDependencies(ctx context.Context, off *colocationv1.Offline) (bool, string, error) {
+	cycle, err := r.hasDependencyCycle(ctx, off)
+	if err != nil {
+		return false, "", err
+	}
+	if cycle {
+		return false, colocationv1.OfflineDependencyCycleReason, nil
+	}
+
+	satisfied := true
+	for _, dep := range off.Spec.DependsOn {
+		target := &colocationv1.Offline{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: off.Namespace, Name: dep.Name}, target); err != nil {
+			if errors.IsNotFound(err) {
+				satisfied = false
+				continue
+			}
+			return false, "", err
+		}
+		switch target.Status.Phase {
+		case colocationv1.OfflineSucceededPhase:
+		case colocationv1.OfflineFailedPhase:
+			if dep.Phase != colocationv1.DependencyCompletedPhase {
+				return false, colocationv1.OfflineDependencyFailedReason, nil
+			}
+		default:
+			satisfied = false
+		}
+	}
+	return satisfied, "", nil
+}
+
+// hasDependencyCycle walks the dependencies of off looking for off itself.
+// The webhook rejects cycles when the Offline is created, this catches the
+// ones made by Offlines created concurrently or while it wasn't served.
+func (r *OfflineReconciler) hasDependencyCycle(ctx context.Context, off *colocationv1.Offline) (bool, error) {
+	return colocationv1.HasDependencyCycle(off, func(name string) (*colocationv1.Offline, error) {
+		dep := &colocationv1.Offline{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: off.Namespace, Name: name}, dep); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return dep, nil
+	})
+}
+
+// dependents maps an offline to the offlines in its namespace depending on
+// it, so they are reconciled when it changes.
+func (r *OfflineReconciler) dependents(obj handler.MapObject) []reconcile.Request {
+	offList := &colocationv1.OfflineList{}
+	if err := r.Client.List(context.Background(), offList, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list dependents", "offline", obj.Meta.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, off := range offList.Items {
+		for _, dep := range off.Spec.DependsOn {
+			if dep.Name == obj.Meta.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: off.Namespace, Name: off.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ElasticTaskLabel names the elastic task a pod belongs to.
+	ElasticTaskLabel = "colocation.cmyun.io/elastic-task"
+
+	// elasticResyncPeriod is how often a running elastic offline is
+	// checked for idle capacity or online pressure.
+	elasticResyncPeriod = 30 * time.Second
+)
+
+func newElasticPod(off *colocationv1.Offline, task colocationv1.ElasticTask) *corev1.Pod {
+	pod := newOfflinePod(off, task.Template)
+	pod.GenerateName = fmt.Sprintf("%s-%s-", off.Name, task.Name)
+	pod.Labels[ElasticTaskLabel] = task.Name
+	return pod
+}
+
+// elasticPods groups the live pods of off by elastic task.
+func elasticPods(off *colocationv1.Offline, pods []corev1.Pod) map[string][]*corev1.Pod {
+	grouped := make(map[string][]*corev1.Pod)
+	for i := range pods {
+		pod := &pods[i]
+		name, ok := pod.Labels[ElasticTaskLabel]
+		if !ok || isTerminated(pod) || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		grouped[name] = append(grouped[name], pod)
+	}
+	return grouped
+}
+
+// scaleElastic grows the elastic tasks of a running offline by one pod when
+// it fits the idle capacity and no elastic pod of off is still pending, and
+// shrinks them by one pod when online pods can't be scheduled. It records
+// the current size in the status of off.
+func (r *OfflineReconciler) scaleElastic(ctx context.Context, off *colocationv1.Offline, pods []corev1.Pod) error {
+	grouped := elasticPods(off, pods)
+	recordSize(off, pods, grouped)
+
+	pressure, err := r.onlinePending(ctx)
+	if err != nil {
+		return err
+	}
+	if pressure {
+		_, err := shrinkElastic(ctx, r.Client, r.Log, off, pods, 1)
+		return err
+	}
+
+	// the last pod grown hasn't been placed, the idle capacity it was
+	// counted against may be gone
+	for _, taskPods := range grouped {
+		for _, pod := range taskPods {
+			if pod.Status.Phase == corev1.PodPending {
+				return nil
+			}
+		}
+	}
+
+	free, err := freeCapacity(ctx, r.Client, nil)
+	if err != nil {
+		return err
+	}
+	for _, task := range off.Spec.Elastic {
+		if int32(len(grouped[task.Name])) >= task.MaxReplicas {
+			continue
+		}
+		if !utils.FitsResources(utils.PodRequests(&task.Template.Spec), free) {
+			continue
+		}
+		r.Log.V(1).Info("Offline{"+off.Name+"} grows elastic task", "task", task.Name)
+		if err := r.createPod(ctx, newElasticPod(off, task)); err != nil {
+			return err
+		}
+		off.Status.ElasticReplicas[task.Name]++
+		off.Status.CurrentSize++
+		return nil
+	}
+	return nil
+}
+
+// shrinkElastic deletes up to n elastic pods of off, the newest first,
+// without taking any task below its MinReplicas or the offline below its
+// MinGang. It returns the resources requested by the deleted pods.
+func shrinkElastic(ctx context.Context, c client.Client, log logr.Logger, off *colocationv1.Offline, pods []corev1.Pod, n int32) (corev1.ResourceList, error) {
+	grouped := elasticPods(off, pods)
+	recordSize(off, pods, grouped)
+
+	candidates := make([]*corev1.Pod, 0)
+	for _, task := range off.Spec.Elastic {
+		taskPods := grouped[task.Name]
+		sort.Slice(taskPods, func(i, j int) bool {
+			return taskPods[j].CreationTimestamp.Before(&taskPods[i].CreationTimestamp)
+		})
+		if extra := int32(len(taskPods)) - task.MinReplicas; extra > 0 {
+			candidates = append(candidates, taskPods[:extra]...)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[j].CreationTimestamp.Before(&candidates[i].CreationTimestamp)
+	})
+
+	freed := corev1.ResourceList{}
+	deleted := int32(0)
+	for _, pod := range candidates {
+		if deleted >= n || off.Status.CurrentSize <= off.Spec.MinGang {
+			break
+		}
+		if err := c.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return freed, err
+		}
+		log.V(1).Info("Offline{"+off.Name+"} shrinks elastic task", "pod", pod.Name)
+		now := v1.Now()
+		pod.DeletionTimestamp = &now
+		off.Status.ElasticReplicas[pod.Labels[ElasticTaskLabel]]--
+		off.Status.CurrentSize--
+		utils.AddResources(freed, utils.PodRequests(&pod.Spec))
+		deleted++
+	}
+	return freed, nil
+}
+
+func recordSize(off *colocationv1.Offline, pods []corev1.Pod, grouped map[string][]*corev1.Pod) {
+	off.Status.ElasticReplicas = make(map[string]int32)
+	for _, task := range off.Spec.Elastic {
+		off.Status.ElasticReplicas[task.Name] = int32(len(grouped[task.Name]))
+	}
+	size := int32(0)
+	for i := range pods {
+		if !isTerminated(&pods[i]) && pods[i].DeletionTimestamp.IsZero() {
+			size++
+		}
+	}
+	off.Status.CurrentSize = size
+}
+
+// onlinePending reports whether a pod not owned by an offline is waiting
+// because no node has room for it.
+func (r *OfflineReconciler) onlinePending(ctx context.Context) (bool, error) {
+	podList := &corev1.PodList{}
+	if err := r.Client.List(ctx, podList); err != nil {
+		return false, err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase != corev1.PodPending || isOfflinePod(pod) {
+			continue
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func isOfflinePod(pod *corev1.Pod) bool {
+	owner := v1.GetControllerOf(pod)
+	return owner != nil && owner.APIVersion == OfflineAPIVersion && owner.Kind == OfflineKind
+}
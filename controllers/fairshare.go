@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reclaim preempts offlines of queues borrowing capacity beyond their
+// deserved share when the blocked head of queue is within its own share.
+func (r *OfflineReconciler) reclaim(ctx context.Context, queue *cache.Queue) error {
+	head := queue.Head()
+	if head == nil {
+		return nil
+	}
+	total, free, err := clusterCapacity(ctx, r.Client, head)
+	if err != nil {
+		return err
+	}
+	headReqs := utils.OfflineRequests(head)
+	if utils.FitsResources(headReqs, free) {
+		return nil
+	}
+
+	offList := &colocationv1.OfflineList{}
+	if err := r.Client.List(ctx, offList); err != nil {
+		return err
+	}
+	admitted := make(map[string][]*colocationv1.Offline)
+	demand := make(map[string]corev1.ResourceList)
+	for i := range offList.Items {
+		off := &offList.Items[i]
+		if Key(off) == Key(head) || !isAdmitted(off) {
+			continue
+		}
+		name := utils.GetOfflineQueueName(off)
+		admitted[name] = append(admitted[name], off)
+		addDemand(demand, name, utils.OfflineRequests(off))
+	}
+	addDemand(demand, queue.GetName(), headReqs)
+	for _, name := range r.Cache.List() {
+		for _, off := range r.Cache.Get(name).List() {
+			addDemand(demand, name, utils.OfflineRequests(off))
+		}
+	}
+
+	deserved := r.Cache.FairShare(total, demand)
+	for _, victim := range cache.Reclaim(queue.GetName(), headReqs, free, admitted, deserved) {
+		r.Log.V(0).Info("Offline{" + victim.Name + "} preempted to reclaim resources for Offline{" + head.Name + "}")
+		if err := r.preemptOffline(ctx, victim); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preemptOffline deletes the pods of off so it goes back to pending and is
+// queued again.
+func (r *OfflineReconciler) preemptOffline(ctx context.Context, off *colocationv1.Offline) error {
+	podList := &corev1.PodList{}
+	selector := labels.SelectorFromSet(off.Spec.Selector.MatchLabels)
+	if err := r.Client.List(ctx, podList, client.InNamespace(off.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		if err := r.Client.Delete(ctx, &podList.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	r.Cache.Get(utils.GetOfflineQueueName(off)).Finish(off)
+	return r.updateOfflineStatus(ctx, off, func(status *colocationv1.OfflineStatus) {
+		status.StartTime = nil
+		status.Backfilled = false
+	})
+}
+
+// isAdmitted reports whether the pods of off have been created and still
+// hold resources.
+func isAdmitted(off *colocationv1.Offline) bool {
+	if off.Status.StartTime == nil {
+		return false
+	}
+	return off.Status.Phase != colocationv1.OfflineSucceededPhase && off.Status.Phase != colocationv1.OfflineFailedPhase
+}
+
+func addDemand(demand map[string]corev1.ResourceList, name string, reqs corev1.ResourceList) {
+	if _, exist := demand[name]; !exist {
+		demand[name] = corev1.ResourceList{}
+	}
+	utils.AddResources(demand[name], reqs)
+}
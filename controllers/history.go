@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/history"
+	"github.com/YunWang/colocation/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordTransition appends a transition to the history of off when its
+// phase or reason differs from the ones it had before this reconcile, and
+// returns it, or nil when nothing changed.
+func (r *OfflineReconciler) recordTransition(off *colocationv1.Offline, queue *cache.Queue, from colocationv1.OfflinePhase, fromReason string, now time.Time) *colocationv1.OfflineTransition {
+	if off.Status.Phase == from && off.Status.Reason == fromReason {
+		return nil
+	}
+	transition := colocationv1.OfflineTransition{
+		Phase:         off.Status.Phase,
+		From:          from,
+		Reason:        off.Status.Reason,
+		Time:          metav1.NewTime(now),
+		QueuePosition: queue.Position(off),
+		Attempt:       r.Cache.GetUnSchedulableQ().Attempts(off),
+	}
+	limit := r.HistoryLimit
+	if limit <= 0 {
+		limit = history.DefaultLimit
+	}
+	off.Status.History = append(off.Status.History, transition)
+	if len(off.Status.History) > limit {
+		off.Status.History = append([]colocationv1.OfflineTransition(nil), off.Status.History[len(off.Status.History)-limit:]...)
+	}
+	return &transition
+}
+
+// sendTransition streams a transition recorded in the cluster to the
+// history sink, if there is one.
+func (r *OfflineReconciler) sendTransition(off *colocationv1.Offline, transition *colocationv1.OfflineTransition) {
+	if r.History == nil || transition == nil {
+		return
+	}
+	record := history.Record{
+		Namespace:         off.Namespace,
+		Name:              off.Name,
+		Queue:             utils.GetOfflineQueueName(off),
+		OfflineTransition: *transition,
+	}
+	if err := r.History.Send(record); err != nil {
+		r.Log.Error(err, "unable to send transition", "offline", Key(off))
+	}
+}
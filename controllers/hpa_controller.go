@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/predict"
+	"github.com/YunWang/colocation/pkg/utils"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hpaResyncPeriod is how often the online reservation is predicted again
+// when no autoscaler changes.
+const hpaResyncPeriod = 30 * time.Second
+
+// HPAReconciler predicts the replicas online Deployments scaled by
+// HorizontalPodAutoscalers are about to need. It reserves their resources in
+// Cache, so offlines aren't admitted into them, and shrinks elastic offlines
+// in advance when the idle capacity doesn't cover them.
+type HPAReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Cache     *cache.Cache
+	Predictor *predict.HPAPredictor
+}
+
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+
+func (r *HPAReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("hpa", req.NamespacedName)
+
+	hpa := &autoscalingv1.HorizontalPodAutoscaler{}
+	if err := r.Get(ctx, req.NamespacedName, hpa); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		r.Predictor.Forget(req.NamespacedName.String())
+	} else {
+		r.Predictor.Observe(req.NamespacedName.String(), hpa, time.Now())
+	}
+
+	reservation, err := r.predictReservation(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Cache.SetOnlineReservation(reservation)
+	if len(reservation) > 0 {
+		log.V(1).Info("online reservation predicted", "reservation", reservation)
+	}
+
+	if err := r.shrinkForReservation(ctx, reservation); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: hpaResyncPeriod}, nil
+}
+
+// predictReservation sums the requests of the replicas every autoscaled
+// Deployment is predicted to add.
+func (r *HPAReconciler) predictReservation(ctx context.Context) (corev1.ResourceList, error) {
+	hpaList := &autoscalingv1.HorizontalPodAutoscalerList{}
+	if err := r.List(ctx, hpaList); err != nil {
+		return nil, err
+	}
+	reservation := corev1.ResourceList{}
+	for i := range hpaList.Items {
+		hpa := &hpaList.Items[i]
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind != "Deployment" {
+			continue
+		}
+		deploy := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: hpa.Namespace, Name: ref.Name}, deploy); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		key := types.NamespacedName{Namespace: hpa.Namespace, Name: hpa.Name}.String()
+		extra := r.Predictor.Predict(key, hpa) - hpa.Status.CurrentReplicas
+		podReqs := utils.PodRequests(&deploy.Spec.Template.Spec)
+		for ; extra > 0; extra-- {
+			utils.AddResources(reservation, podReqs)
+		}
+	}
+	return reservation, nil
+}
+
+// shrinkForReservation shrinks elastic offlines, lowest priority first,
+// until the free capacity covers the reservation.
+func (r *HPAReconciler) shrinkForReservation(ctx context.Context, reservation corev1.ResourceList) error {
+	if len(reservation) == 0 {
+		return nil
+	}
+	free, err := freeCapacity(ctx, r.Client, nil)
+	if err != nil {
+		return err
+	}
+	reservation = withBatchEquivalent(reservation, free)
+	if utils.FitsResources(reservation, free) {
+		return nil
+	}
+
+	offList := &colocationv1.OfflineList{}
+	if err := r.List(ctx, offList); err != nil {
+		return err
+	}
+	elastic := make([]*colocationv1.Offline, 0)
+	for i := range offList.Items {
+		off := &offList.Items[i]
+		if off.Status.Phase == colocationv1.OfflineRunningPhase && len(off.Spec.Elastic) > 0 {
+			elastic = append(elastic, off)
+		}
+	}
+	cache.SortVictims(elastic)
+
+	for _, off := range elastic {
+		podList := &corev1.PodList{}
+		selector := labels.SelectorFromSet(off.Spec.Selector.MatchLabels)
+		if err := r.List(ctx, podList, client.InNamespace(off.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return err
+		}
+		for !utils.FitsResources(reservation, free) {
+			freed, err := shrinkElastic(ctx, r.Client, r.Log, off, podList.Items, 1)
+			if err != nil {
+				return err
+			}
+			if len(freed) == 0 {
+				break
+			}
+			utils.AddResources(free, freed)
+		}
+		if utils.FitsResources(reservation, free) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *HPAReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&autoscalingv1.HorizontalPodAutoscaler{}).
+		Complete(r)
+}
+
+// withBatchEquivalent adds to reservation the batch resources its cpu and
+// memory take away from nodes, for the batch resources free holds. Online
+// replicas request native cpu and memory, but they shrink the reclaimable
+// capacity the nodes advertise to defaulted offlines as much.
+func withBatchEquivalent(reservation, free corev1.ResourceList) corev1.ResourceList {
+	reserved := reservation.DeepCopy()
+	for name, quantity := range colocationv1.BatchEquivalent(reservation) {
+		if _, advertised := free[name]; advertised {
+			reserved[name] = quantity
+		}
+	}
+	return reserved
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Online reservation", func() {
+	cpuAndMemory := func(cpu, memory string) corev1.ResourceList {
+		return corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}
+	}
+	defaulted := func(name, cpu string) *colocationv1.Offline {
+		off := &colocationv1.Offline{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		template := &corev1.PodTemplateSpec{}
+		template.Spec.Containers = []corev1.Container{{
+			Name:      "main",
+			Resources: corev1.ResourceRequirements{Requests: cpuAndMemory(cpu, "1Gi")},
+		}}
+		off.Spec.Tasks = []*corev1.PodTemplateSpec{template}
+		off.Default()
+		return off
+	}
+
+	It("holds a defaulted offline back from the batch resources online replicas will take", func() {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		node.Status.Allocatable = cpuAndMemory("8", "16Gi")
+		node.Status.Allocatable[colocationv1.BatchCPUResource] = resource.MustParse("4000")
+		node.Status.Allocatable[colocationv1.BatchMemoryResource] = resource.MustParse("8Gi")
+		r := &OfflineReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, node),
+			Log:    ctrl.Log.WithName("controllers").WithName("Offline"),
+			Cache:  cache.NewCache(),
+		}
+		r.Cache.SetOnlineReservation(cpuAndMemory("3", "2Gi"))
+
+		held, err := r.admissionHeld(context.Background(), defaulted("large", "2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(held).To(BeTrue())
+
+		held, err = r.admissionHeld(context.Background(), defaulted("small", "500m"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(held).To(BeFalse())
+	})
+})
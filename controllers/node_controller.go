@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/YunWang/colocation/pkg/reclaim"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// nodeResyncPeriod is how often the batch resources of a node are computed
+// again from the measured usage of its online pods.
+const nodeResyncPeriod = 30 * time.Second
+
+// NodeReconciler advertises the reclaimable cpu and memory of every node as
+// the batch extended resources offlines request.
+type NodeReconciler struct {
+	client.Client
+	Log          logr.Logger
+	Scheme       *runtime.Scheme
+	SafetyMargin float64
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=list
+
+func (r *NodeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("node", req.NamespacedName)
+
+	node := &corev1.Node{}
+	if err := r.Get(ctx, req.NamespacedName, node); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList); err != nil {
+		return ctrl.Result{}, err
+	}
+	online := make([]corev1.Pod, 0)
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName != node.Name || isTerminated(&pod) || isOfflinePod(&pod) {
+			continue
+		}
+		online = append(online, pod)
+	}
+
+	usage, err := reclaim.MeasuredUsage(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "unable to read pod metrics, online pods are counted by their requests")
+	}
+	result := ctrl.Result{}
+	if usage != nil {
+		// usage changes without any event
+		result.RequeueAfter = nodeResyncPeriod
+	}
+
+	batch := reclaim.Reclaimable(node, online, usage, r.SafetyMargin)
+	if reclaim.Equal(node, batch) {
+		return result, nil
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Status.Capacity == nil {
+		node.Status.Capacity = corev1.ResourceList{}
+	}
+	if node.Status.Allocatable == nil {
+		node.Status.Allocatable = corev1.ResourceList{}
+	}
+	for name, quantity := range batch {
+		node.Status.Capacity[name] = quantity
+		node.Status.Allocatable[name] = quantity
+	}
+	log.V(1).Info("advertise batch resources", "batch", batch)
+	if err := r.Status().Patch(ctx, node, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// podNode maps a pod to the node it runs on.
+func podNode(obj handler.MapObject) []reconcile.Request {
+	pod, ok := obj.Object.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: pod.Spec.NodeName}}}
+}
+
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}).
+		Watches(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(podNode)}).
+		Complete(r)
+}
@@ -20,6 +20,9 @@ import (
 	"fmt"
 	colocationv1 "github.com/YunWang/colocation/api/v1"
 	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/health"
+	"github.com/YunWang/colocation/pkg/history"
+	"github.com/YunWang/colocation/pkg/topology"
 	"github.com/YunWang/colocation/pkg/utils"
 	"github.com/go-logr/logr"
 	v12 "k8s.io/api/core/v1"
@@ -31,6 +34,10 @@ import (
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 )
 
@@ -38,6 +45,13 @@ const (
 	OfflineFinalizer  = "offline.colocation.cmyun.io"
 	OfflineAPIVersion = "colocation.cmyun.io/v1"
 	OfflineKind = "Offline"
+
+	// admissionHoldPeriod is how often an offline held for online
+	// workloads is checked again.
+	admissionHoldPeriod = 10 * time.Second
+	// topologyRecheckPeriod is how often an offline whose required topology
+	// no node layout satisfies is checked again against the nodes.
+	topologyRecheckPeriod = time.Minute
 )
 
 
@@ -47,6 +61,18 @@ type OfflineReconciler struct {
 	Log    logr.Logger
 	Scheme *runtime.Scheme
 	Cache *cache.Cache
+	// History receives every transition of every Offline, nil discards
+	// them.
+	History history.Sink
+	// HistoryLimit bounds the transitions kept in the status of an
+	// Offline, history.DefaultLimit when unset.
+	HistoryLimit int
+	// Watchdog tracks the reconciles in flight for the liveness probe, nil
+	// disables it.
+	Watchdog *health.Watchdog
+
+	retryEvents chan event.GenericEvent
+	standby     *Standby
 }
 
 // +kubebuilder:rbac:groups=colocation.cmyun.io,resources=offlines,verbs=get;list;watch;create;update;patch;delete
@@ -55,6 +81,14 @@ type OfflineReconciler struct {
 func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("offline", req.NamespacedName)
+	if r.Watchdog!=nil{
+		defer r.Watchdog.Begin()()
+	}
+
+	//a new leader takes the mirrored queues over before anything
+	if err:=r.takeOver();err!=nil{
+		return ctrl.Result{},err
+	}
 
 	off:=&colocationv1.Offline{}
 	if err:=r.Client.Get(ctx,req.NamespacedName,off);err!=nil{
@@ -81,6 +115,7 @@ func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		off.Finalizers=append(off.ObjectMeta.Finalizers, OfflineFinalizer)
 		//offline pending
 		off.Status.Phase=colocationv1.OfflinePendingPhase
+		transition:=r.recordTransition(off,queue,"","",time.Now())
 
 		//update to cluster
 		oldOff:=&colocationv1.Offline{}
@@ -94,44 +129,54 @@ func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 				return ctrl.Result{},err
 			}
 		}
+		r.sendTransition(off,transition)
 		return ctrl.Result{},nil
 	}
 	//Get all pod owned by this offline
 	podList:=&v12.PodList{}
-	if err:=r.Client.List(ctx,podList,client.InNamespace(req.Namespace),client.MatchingLabelsSelector{labels.SelectorFromSet(off.Spec.Selector.MatchLabels)});err!=nil{
+	if err:=r.Client.List(ctx,podList,client.InNamespace(req.Namespace),client.MatchingLabelsSelector{Selector:labels.SelectorFromSet(off.Spec.Selector.MatchLabels)});err!=nil{
 		return ctrl.Result{},err
 	}
 
 	//delete
 	if !off.DeletionTimestamp.IsZero() {
 		log.V(0).Info("Offline{"+off.Name+"} Deleted!")
-		//set deletetimestamp
-		for _,pod := range podList.Items {
-			pod.ObjectMeta.DeletionTimestamp=&v1.Time{Time:time.Now()}
-			go r.updatePod(ctx,&pod)
+		if err:=r.deletePods(ctx,podList.Items);err!=nil{
+			return ctrl.Result{},err
 		}
 		//delete offline from queue
-		//queueName:=utils.GetOfflineQueueName(off)
-		//queue:=r.Cache.Get(queueName)
-		if err:=queue.Delete(off);err!=nil{
-			return ctrl.Result{},err
+		queue.Finish(off)
+		if queue.IsAdmitted(off) {
+			queue.Release(off)
+			r.admitNext(ctx,off,queue)
+		}else if _,exist:=queue.Get(Key(off));exist{
+			if err:=queue.Delete(off);err!=nil{
+				return ctrl.Result{},err
+			}
 		}
+		r.Cache.GetUnSchedulableQ().Forget(off)
 		//delete finalizer
 		for index,finalizer := range off.Finalizers {
 			if finalizer==OfflineFinalizer {
 				off.Finalizers= append(off.Finalizers[:index], off.Finalizers[index+1:]...)
+				return ctrl.Result{},r.Update(ctx,off)
 			}
 		}
+		return ctrl.Result{},nil
 	}
 
 	//update
+	lastPhase:=off.Status.Phase
+	lastReason:=off.Status.Reason
 	minGang:=off.Spec.MinGang
 	succeedNum:=off.Status.PodSucceeded
 	pendingNum:=off.Status.PodPending
 	runningNum:=off.Status.PodRunning
 	failedNum:=off.Status.PodFailed
 	unknownNum:=off.Status.PodUnknown
-	if succeedNum==0&&pendingNum==0&&runningNum==0&&failedNum==0&&unknownNum==0{
+	if off.Status.Reason!=""{
+		off.Status.Phase=colocationv1.OfflineFailedPhase
+	}else if succeedNum==0&&pendingNum==0&&runningNum==0&&failedNum==0&&unknownNum==0{
 		off.Status.Phase=colocationv1.OfflinePendingPhase
 	}else{
 		if failedNum !=0 || unknownNum!=0{
@@ -149,29 +194,139 @@ func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
+	//suspended offline gives up its pods and its place in queue until resumed
+	result:=ctrl.Result{}
+	if off.DeletionTimestamp.IsZero() {
+		if isSuspended(off,lastPhase) {
+			held,err:=r.suspend(ctx,off,podList.Items,queue)
+			if err!=nil {
+				return ctrl.Result{},err
+			}
+			if held {
+				requeueAfter(&result,admissionHoldPeriod)
+			}
+			off.Status.Phase=colocationv1.OfflineSuspendedPhase
+		}else if lastPhase==colocationv1.OfflineSuspendedPhase && len(podList.Items)>0 {
+			//resumed, queue it again once the pods it was suspended with are gone
+			off.Status.Phase=colocationv1.OfflineSuspendedPhase
+			requeueAfter(&result,suspendResyncPeriod)
+		}
+	}
+
+	//hold offline until its dependencies complete
+	if off.Status.Phase==colocationv1.OfflinePendingPhase && len(off.Spec.DependsOn)>0 {
+		satisfied,reason,err:=r.checkDependencies(ctx,off)
+		if err!=nil {
+			return ctrl.Result{},err
+		}
+		if reason!="" {
+			log.V(0).Info("Offline{"+off.Name+"} can't run: "+reason)
+			off.Status.Reason=reason
+			off.Status.Phase=colocationv1.OfflineFailedPhase
+		}else if !satisfied {
+			off.Status.Phase=colocationv1.OfflineWaitingPhase
+		}
+	}
+
+	//hold offline whose required topology no node layout satisfies, nodes
+	//may be added or made schedulable later
+	unplaceable:=false
+	if off.Status.Phase==colocationv1.OfflinePendingPhase && off.Status.StartTime==nil && off.Spec.Topology!=nil && off.Spec.Topology.Required {
+		nodeList:=&v12.NodeList{}
+		if err:=r.Client.List(ctx,nodeList);err!=nil{
+			return ctrl.Result{},err
+		}
+		unplaceable=!topology.Feasible(off,nodeList.Items)
+		if unplaceable {
+			if off.Status.Reason!=colocationv1.OfflineTopologyUnsatisfiableReason {
+				log.V(0).Info("Offline{"+off.Name+"} can't be placed as its topology requires yet")
+			}
+			off.Status.Reason=colocationv1.OfflineTopologyUnsatisfiableReason
+			requeueAfter(&result,topologyRecheckPeriod)
+		}else if off.Status.Reason==colocationv1.OfflineTopologyUnsatisfiableReason {
+			off.Status.Reason=""
+		}
+	}
 
+	//fail started offline past its deadlines, releasing the pods it has
+	if reason,remaining:=checkDeadlines(off,time.Now());reason!=""{
+		log.V(0).Info("Offline{"+off.Name+"} failed: "+reason)
+		off.Status.Reason=reason
+		off.Status.Phase=colocationv1.OfflineFailedPhase
+		if err:=r.deletePods(ctx,podList.Items);err!=nil{
+			return ctrl.Result{},err
+		}
+	}else if remaining>0 {
+		requeueAfter(&result,remaining)
+	}
+
+	//garbage collect finished offline after its ttl, unless it is to be retried
+	if off.DeletionTimestamp.IsZero() && !r.awaitingRetry(off,podList.Items) {
+		if remaining,ok:=expireAfterFinished(off,time.Now());ok{
+			if remaining<=0 {
+				return ctrl.Result{},r.deleteExpired(ctx,off)
+			}
+			requeueAfter(&result,remaining)
+		}
+	}
+
+	//grow or shrink elastic tasks of running offline
+	if off.Status.Phase==colocationv1.OfflineRunningPhase && len(off.Spec.Elastic)>0 {
+		if err:=r.scaleElastic(ctx,off,podList.Items);err!=nil{
+			return ctrl.Result{},err
+		}
+		requeueAfter(&result,elasticResyncPeriod)
+	}
+
+	//raise the priority of offline waiting in queue
+	waiting:=off.Status.Phase==colocationv1.OfflinePendingPhase||off.Status.Phase==colocationv1.OfflineSchedulingPhase
+	if aging:=queue.GetPolicy().Aging;aging!=nil && waiting && !queue.IsAdmitted(off) && !queue.IsBackfilled(off) {
+		now:=time.Now()
+		priority:=aging.EffectivePriority(off,now)
+		off.Status.EffectivePriority=&priority
+		if next,ok:=aging.NextAging(off,now);ok{
+			requeueAfter(&result,next)
+		}
+	}else if aging==nil && waiting {
+		//aging was turned off for its queue, order it by level again
+		off.Status.EffectivePriority=nil
+	}
 
 	//handle queue according to offline phase
 	if off.Status.Phase==colocationv1.OfflineFailedPhase {
-		//delete whole offline to release resource besides failed pod,because user may want to check failed pod
-		for _,pod := range podList.Items {
-			if pod.Status.Phase==v12.PodFailed {
-				continue
+		//only gangs that couldn't be placed are retried, pods that ran and
+		//failed are kept for inspection
+		unschedulable:=off.Status.Reason=="" && !failedAfterRunning(podList.Items)
+		if !unschedulable {
+			r.Cache.GetUnSchedulableQ().Forget(off)
+		}
+		if unschedulable && r.Cache.GetUnSchedulableQ().IsRetrying(off) {
+			//backoff expired, delete failed pods as well to schedule it again
+			if err:=r.retryOffline(ctx,off,podList.Items);err!=nil{
+				return ctrl.Result{},err
 			}
-			pod.ObjectMeta.DeletionTimestamp=&v1.Time{Time:time.Now()}
-			go r.updatePod(ctx,&pod)
-		}
-		_=r.Cache.AddToUnSchedulableQ(off)
-		current:=queue.GetCurrent()
-		if Key(current) == Key(off) {
-			if err:=queue.UpdateCurrent();err!=nil{
-				return ctrl.Result{},nil
+		}else{
+			//delete whole offline to release resource besides failed pod,because user may want to check failed pod
+			release:=make([]v12.Pod,0,len(podList.Items))
+			for _,pod := range podList.Items {
+				if pod.Status.Phase!=v12.PodFailed {
+					release=append(release, pod)
+				}
+			}
+			if err:=r.deletePods(ctx,release);err!=nil{
+				return ctrl.Result{},err
 			}
-			current=queue.GetCurrent()
-			if current!=nil {
-				_=r.startOffline(ctx,current)
-			}else{
-				_=r.Cache.Delete(queueName)
+			if unschedulable && r.Cache.AddToUnSchedulableQ(off)==cache.ErrMaxAttempts {
+				log.V(0).Info("Offline{"+off.Name+"} gave up, it failed to schedule too many times")
+				off.Status.Reason=colocationv1.OfflineUnschedulableReason
+				r.Cache.GetUnSchedulableQ().Forget(off)
+			}
+		}
+		queue.Finish(off)
+		if queue.IsAdmitted(off) {
+			queue.Release(off)
+			if r.admitNext(ctx,off,queue) {
+				requeueAfter(&result,admissionHoldPeriod)
 			}
 		}else{
 			_,exist:=queue.Get(Key(off))
@@ -180,16 +335,12 @@ func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			}
 		}
 	}else if off.Status.Phase==colocationv1.OfflineRunningPhase{
-		currentSchedulingOffline:=queue.GetCurrent()
-		if Key(currentSchedulingOffline)== Key(off){
-			//scheduling next offline
-			_=queue.UpdateCurrent()
-			next:=queue.GetCurrent()
-			if next==nil{
-				_=r.Cache.Delete(queueName)
-			}else{
-				//start offline
-				_=r.startOffline(ctx,next)
+		r.Cache.GetUnSchedulableQ().Forget(off)
+		if queue.IsAdmitted(off){
+			//its gang has gathered, make room for the next offline
+			queue.Release(off)
+			if r.admitNext(ctx,off,queue) {
+				requeueAfter(&result,admissionHoldPeriod)
 			}
 		}else{
 			target,exist:=queue.Get(Key(off))
@@ -200,39 +351,90 @@ func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			}
 		}
 	}else if off.Status.Phase == colocationv1.OfflinePendingPhase{
-		//add to schedulingQ
-		_=queue.AddSchedulingQ(off)
-		if queue.GetCurrent()==nil {
-			//start offline
-			_=queue.UpdateCurrent()
-			current:=queue.GetCurrent()
-			if current == nil{
-				_=r.Cache.Delete(queueName)
-			}else{
-				_=r.startOffline(ctx,queue.GetCurrent())
+		r.Cache.GetUnSchedulableQ().Retried(off)
+		//add to schedulingQ unless it has been started already or no node
+		//layout satisfies its topology
+		if unplaceable {
+			if _,exist:=queue.Get(Key(off));exist{
+				_=queue.Delete(off)
 			}
+		}else if !queue.IsAdmitted(off) && !queue.IsBackfilled(off) {
+			_=queue.AddSchedulingQ(off)
+		}
+		//start offlines while the window has room
+		if r.admitNext(ctx,off,queue) {
+			requeueAfter(&result,admissionHoldPeriod)
 		}
 	}else if off.Status.Phase==colocationv1.OfflineSchedulingPhase {
-		_,exist:=queue.Get(Key(off))
-		if !exist {
-			_=queue.AddSchedulingQ(off)
+		//its pods are created already, keep it in the window instead of
+		//queueing it to be admitted twice
+		if !queue.IsAdmitted(off) && !queue.IsBackfilled(off) {
+			queue.Admit(off)
 		}
-		exist=r.Cache.IsExistInUnSchedulableQ(off)
+		exist:=r.Cache.IsExistInUnSchedulableQ(off)
 		if exist {
 			_=r.Cache.DeleteFromUnSchedulableQ(off)
 		}
+	}else if off.Status.Phase==colocationv1.OfflineWaitingPhase{
+		//dependencies may have been added after it was queued
+		if _,exist:=queue.Get(Key(off));exist{
+			_=queue.Delete(off)
+		}
 	}else if off.Status.Phase==colocationv1.OfflineSucceededPhase{
-		_,exist:=queue.Get(Key(off))
-		if !exist {
-			_=queue.AddSchedulingQ(off)
+		queue.Finish(off)
+		r.Cache.GetUnSchedulableQ().Forget(off)
+		//a gang may succeed before it was seen running
+		if queue.IsAdmitted(off) {
+			queue.Release(off)
+			if r.admitNext(ctx,off,queue) {
+				requeueAfter(&result,admissionHoldPeriod)
+			}
+		}else if _,exist:=queue.Get(Key(off));exist{
+			_=queue.Delete(off)
+		}
+	}
+
+	//let smaller offlines run while the head of queue is blocked
+	if queue.GetPolicy().Backfill {
+		if err:=r.backfill(ctx,off,queue);err!=nil{
+			log.Error(err,"backfill failed")
+		}
+	}
+
+	//take back resources lent to other queues for the head of queue
+	if r.Cache.FairShareEnabled() {
+		if err:=r.reclaim(ctx,queue);err!=nil{
+			log.Error(err,"reclaim failed")
 		}
 	}
 
+	//finalizer for delete, offlines created through the API server already
+	//have their creation timestamp when first reconciled
+	if _,exist:=utils.ContainsString(off.Finalizers,OfflineFinalizer);!exist{
+		off.Finalizers=append(off.Finalizers, OfflineFinalizer)
+	}
+
+	//keep the podgroup of offline in step with its spec and status
+	if off.DeletionTimestamp.IsZero() {
+		if err:=r.syncPodGroup(ctx,off);err!=nil{
+			log.Error(err,"podgroup sync failed")
+		}
+	}
+
+	//keep the transition in the history of offline
+	transition:=r.recordTransition(off,queue,lastPhase,lastReason,time.Now())
+
 	//update to cluster
 	oldOff:=&colocationv1.Offline{}
 	if err:=r.Get(ctx,types.NamespacedName{Namespace:off.Namespace,Name:off.Name},oldOff);err!=nil{
 		return ctrl.Result{},err
 	}
+	//pod counters belong to PodReconciler, keep what it wrote meanwhile
+	off.Status.PodPending=oldOff.Status.PodPending
+	off.Status.PodRunning=oldOff.Status.PodRunning
+	off.Status.PodSucceeded=oldOff.Status.PodSucceeded
+	off.Status.PodFailed=oldOff.Status.PodFailed
+	off.Status.PodUnknown=oldOff.Status.PodUnknown
 	if !reflect.DeepEqual(oldOff.Status,off.Status) || !reflect.DeepEqual(oldOff.ObjectMeta,off.ObjectMeta){
 		oldOff.ObjectMeta=off.ObjectMeta
 		oldOff.Status=off.Status
@@ -240,51 +442,241 @@ func (r *OfflineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return ctrl.Result{},err
 		}
 	}
+	r.sendTransition(off,transition)
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
-func(r *OfflineReconciler) updatePod(ctx context.Context,pod *v12.Pod)error{
-	if err:=r.Client.Update(ctx,pod,&client.UpdateOptions{});err!=nil {
+// backfill starts the offlines that can finish before the blocked head of
+// queue is expected to get enough resources for its gang.
+func(r *OfflineReconciler) backfill(ctx context.Context,reconciled *colocationv1.Offline,queue *cache.Queue)error{
+	head:=queue.Head()
+	if head==nil {
+		return nil
+	}
+	//the head's own pods count as free only for its wait, the
+	//candidates must fit around them
+	headFree,err:=freeCapacity(ctx,r.Client,head)
+	if err!=nil {
+		return err
+	}
+	free,err:=freeCapacity(ctx,r.Client,nil)
+	if err!=nil {
+		return err
+	}
+	offList:=&colocationv1.OfflineList{}
+	if err:=r.Client.List(ctx,offList);err!=nil{
 		return err
 	}
+	running:=make([]*colocationv1.Offline,0)
+	for i := range offList.Items {
+		if offList.Items[i].Status.Phase==colocationv1.OfflineRunningPhase {
+			running=append(running, &offList.Items[i])
+		}
+	}
+	wait:=cache.EstimateWait(head,headFree,running,time.Now())
+	if wait==0 {
+		return nil
+	}
+	for _,off := range queue.Backfill(free,wait) {
+		r.Log.V(0).Info("Offline{"+off.Name+"} backfilled ahead of Offline{"+head.Name+"}")
+		off.Status.Backfilled=true
+		if Key(off)==Key(reconciled) {
+			reconciled.Status.Backfilled=true
+		}
+		if err:=r.admit(ctx,reconciled,off);err!=nil{
+			queue.Finish(off)
+			_=queue.AddSchedulingQ(off)
+			off.Status.Backfilled=false
+			if Key(off)==Key(reconciled) {
+				reconciled.Status.Backfilled=false
+			}
+			return err
+		}
+	}
 	return nil
 }
 
+// admitNext admits the offlines at the head of schedulingQ into the
+// admission window of queue and starts them, until the window is full. It
+// returns true when the head is held back because the resources online
+// workloads are about to need leave no room for it.
+func(r *OfflineReconciler) admitNext(ctx context.Context,reconciled *colocationv1.Offline,queue *cache.Queue)bool{
+	for queue.CanAdmit() {
+		head:=queue.Peek()
+		held,err:=r.admissionHeld(ctx,head)
+		if err!=nil {
+			r.Log.Error(err,"unable to check online reservation")
+		}
+		if held {
+			r.Log.V(1).Info("Offline{"+head.Name+"} held for online workloads")
+			return true
+		}
+		next,err:=queue.AdmitNext()
+		if err!=nil || next==nil {
+			break
+		}
+		if err:=r.admit(ctx,reconciled,next);err!=nil{
+			//give its place back, it is admitted again on a later reconcile
+			r.Log.Error(err,"unable to start offline","offline",next.Name)
+			queue.Release(next)
+			_=queue.AddSchedulingQ(next)
+			break
+		}
+	}
+	if queue.IsEmpty() {
+		_=r.Cache.Delete(queue.GetName())
+	}
+	return false
+}
+
+// admissionHeld reports whether starting head would take resources the
+// online reservation in Cache sets aside.
+func(r *OfflineReconciler) admissionHeld(ctx context.Context,head *colocationv1.Offline)(bool,error){
+	reservation:=r.Cache.GetOnlineReservation()
+	if len(reservation)==0 {
+		return false,nil
+	}
+	free,err:=freeCapacity(ctx,r.Client,nil)
+	if err!=nil {
+		return false,err
+	}
+	utils.SubResources(free,withBatchEquivalent(reservation,free))
+	return !utils.FitsResources(utils.OfflineRequests(head),free),nil
+}
+
+// admit creates the pods of target and records its start time. target is
+// written to the cluster right away unless it is the offline being
+// reconciled, which is written back at the end of Reconcile.
+func(r *OfflineReconciler) admit(ctx context.Context,reconciled,target *colocationv1.Offline)error{
+	if err:=r.startOffline(ctx,target);err!=nil{
+		return err
+	}
+	now:=v1.Now()
+	target.Status.StartTime=&now
+	if Key(target)==Key(reconciled) {
+		reconciled.Status.StartTime=&now
+		return nil
+	}
+	return r.updateOfflineStatus(ctx,target,func(status *colocationv1.OfflineStatus) {
+		status.StartTime=target.Status.StartTime
+		status.Backfilled=target.Status.Backfilled
+	})
+}
+
+// updateOfflineStatus applies mutate to the latest status of off in the cluster.
+func(r *OfflineReconciler) updateOfflineStatus(ctx context.Context,off *colocationv1.Offline,mutate func(status *colocationv1.OfflineStatus))error{
+	latest:=&colocationv1.Offline{}
+	if err:=r.Get(ctx,types.NamespacedName{Namespace:off.Namespace,Name:off.Name},latest);err!=nil{
+		return err
+	}
+	mutate(&latest.Status)
+	return r.Update(ctx,latest)
+}
+
 func (r *OfflineReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.retryEvents=make(chan event.GenericEvent)
+	if err:=mgr.Add(manager.RunnableFunc(r.flushUnschedulableQ));err!=nil{
+		return err
+	}
+	r.standby=&Standby{Cache:r.Cache,Informers:mgr.GetCache(),Log:r.Log.WithName("standby")}
+	if err:=mgr.Add(r.standby);err!=nil{
+		return err
+	}
+	if err:=mgr.Add(manager.RunnableFunc(r.lead));err!=nil{
+		return err
+	}
+	builder:=ctrl.NewControllerManagedBy(mgr).
 		For(&colocationv1.Offline{}).
-		Complete(r)
+		Owns(&colocationv1.PodGroup{}).
+		Watches(&source.Kind{Type:&colocationv1.Offline{}},&handler.EnqueueRequestsFromMapFunc{ToRequests:handler.ToRequestsFunc(r.dependents)}).
+		Watches(&source.Channel{Source:r.retryEvents},&handler.EnqueueRequestForObject{})
+	for obj,h:=range r.unschedulableFlushHandlers(){
+		builder=builder.Watches(&source.Kind{Type:obj},h)
+	}
+	return builder.Complete(r)
 }
 
+// startOffline creates the pods of off. When a pod can't be created the
+// ones created before it are deleted again, so off is started whole or not
+// at all.
 func(r *OfflineReconciler) startOffline(ctx context.Context,off *colocationv1.Offline)error{
+	pods:=make([]*v12.Pod,0,len(off.Spec.Tasks))
 	for _,podTemplateSpec := range off.Spec.Tasks {
-		pod:=&v12.Pod{
-			ObjectMeta:v1.ObjectMeta{
-				Labels:getPodsLabelSet(podTemplateSpec),
-				Annotations:getPodsAnnotationSet(podTemplateSpec),
-				Finalizers:getPodsFinalizers(podTemplateSpec),
-				GenerateName:fmt.Sprintf("%s-", off.Name),
-			},
-		}
-		flag:=true
-		pod.OwnerReferences = append(pod.OwnerReferences, v1.OwnerReference{
-			APIVersion:off.APIVersion,
-			Kind:off.Kind,
-			Name:off.Name,
-			UID:off.UID,
-			Controller:&flag,
-		})
-		pod.Spec=*podTemplateSpec.Spec.DeepCopy()
-		go func(pod *v12.Pod) {
-			if err:=r.Client.Create(ctx,pod,&client.CreateOptions{});err!=nil{
-				return
+		pods=append(pods, newOfflinePod(off,podTemplateSpec))
+	}
+	for _,task := range off.Spec.Elastic {
+		for i:=int32(0);i<task.MinReplicas;i++{
+			pods=append(pods, newElasticPod(off,task))
+		}
+	}
+	created:=make([]v12.Pod,0,len(pods))
+	for _,pod := range pods {
+		if err:=r.createPod(ctx,pod);err!=nil{
+			if err:=r.deletePods(ctx,created);err!=nil{
+				r.Log.Error(err,"unable to delete the pods of a partly started offline","offline",off.Name)
 			}
-		}(pod)
+			return err
+		}
+		created=append(created, *pod)
 	}
 	return nil
 }
 
+func(r *OfflineReconciler) createPod(ctx context.Context,pod *v12.Pod)error{
+	return r.Client.Create(ctx,pod,&client.CreateOptions{})
+}
+
+func newOfflinePod(off *colocationv1.Offline,podTemplateSpec *v12.PodTemplateSpec)*v12.Pod{
+	pod:=&v12.Pod{
+		ObjectMeta:v1.ObjectMeta{
+			Namespace:off.Namespace,
+			Labels:getPodsLabelSet(podTemplateSpec),
+			Annotations:getPodsAnnotationSet(podTemplateSpec),
+			Finalizers:getPodsFinalizers(podTemplateSpec),
+			GenerateName:fmt.Sprintf("%s-", off.Name),
+		},
+	}
+	pod.Labels[colocationv1.PodGroupLabel]=off.Name
+	flag:=true
+	pod.OwnerReferences = append(pod.OwnerReferences, v1.OwnerReference{
+		APIVersion:OfflineAPIVersion,
+		Kind:OfflineKind,
+		Name:off.Name,
+		UID:off.UID,
+		Controller:&flag,
+	})
+	pod.Spec=*podTemplateSpec.Spec.DeepCopy()
+	topology.Apply(off,&pod.Spec)
+	avoidMemoryPressure(&pod.Spec)
+	return pod
+}
+
+// avoidMemoryPressure keeps the pod off nodes the agent labelled for memory
+// pressure, whatever other nodes its template requires.
+func avoidMemoryPressure(spec *v12.PodSpec){
+	requirement:=v12.NodeSelectorRequirement{Key:colocationv1.MemoryPressureLabel,Operator:v12.NodeSelectorOpDoesNotExist}
+	if spec.Affinity==nil {
+		spec.Affinity=&v12.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity==nil {
+		spec.Affinity.NodeAffinity=&v12.NodeAffinity{}
+	}
+	na:=spec.Affinity.NodeAffinity
+	if na.RequiredDuringSchedulingIgnoredDuringExecution==nil {
+		na.RequiredDuringSchedulingIgnoredDuringExecution=&v12.NodeSelector{}
+	}
+	selector:=na.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(selector.NodeSelectorTerms)==0 {
+		selector.NodeSelectorTerms=[]v12.NodeSelectorTerm{{}}
+	}
+	//terms are ORed, every one of them must exclude the label
+	for i := range selector.NodeSelectorTerms {
+		term:=&selector.NodeSelectorTerms[i]
+		term.MatchExpressions=append(term.MatchExpressions, requirement)
+	}
+}
+
 func getPodsLabelSet(template *v12.PodTemplateSpec)labels.Set{
 	desiredLabels := make(labels.Set)
 	for k, v := range template.Labels {
@@ -0,0 +1,294 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	timeout  = 20 * time.Second
+	interval = 250 * time.Millisecond
+)
+
+func newTestOffline(name, queue string, level int32, tasks int32) *colocationv1.Offline {
+	labels := map[string]string{"offline": name}
+	off := &colocationv1.Offline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: colocationv1.OfflineSpec{
+			MinGang:  tasks,
+			Level:    level,
+			Queue:    queue,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	for i := int32(0); i < tasks; i++ {
+		off.Spec.Tasks = append(off.Spec.Tasks, &corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Spec: corev1.PodSpec{
+				Containers:    []corev1.Container{{Name: "task", Image: "busybox"}},
+				RestartPolicy: corev1.RestartPolicyNever,
+			},
+		})
+	}
+	return off
+}
+
+func getOffline(name string) func() *colocationv1.Offline {
+	return func() *colocationv1.Offline {
+		off := &colocationv1.Offline{}
+		if err := k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, off); err != nil {
+			return nil
+		}
+		return off
+	}
+}
+
+func offlinePhase(name string) func() colocationv1.OfflinePhase {
+	return func() colocationv1.OfflinePhase {
+		if off := getOffline(name)(); off != nil {
+			return off.Status.Phase
+		}
+		return ""
+	}
+}
+
+// listPods returns the pods of an offline that aren't being deleted.
+func listPods(name string) func() []corev1.Pod {
+	return func() []corev1.Pod {
+		podList := &corev1.PodList{}
+		err := k8sClient.List(context.Background(), podList, client.InNamespace("default"), client.MatchingLabels{"offline": name})
+		Expect(err).NotTo(HaveOccurred())
+		pods := make([]corev1.Pod, 0)
+		for _, pod := range podList.Items {
+			if pod.DeletionTimestamp.IsZero() {
+				pods = append(pods, pod)
+			}
+		}
+		return pods
+	}
+}
+
+// setPodPhase plays kubelet, moving a pod to phase.
+func setPodPhase(pod *corev1.Pod, phase corev1.PodPhase) {
+	Eventually(func() error {
+		latest := &corev1.Pod{}
+		if err := k8sClient.Get(context.Background(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, latest); err != nil {
+			return err
+		}
+		latest.Status.Phase = phase
+		return k8sClient.Status().Update(context.Background(), latest)
+	}, timeout, interval).Should(Succeed())
+}
+
+// setPodPhases moves every pod of an offline to phase.
+func setPodPhases(name string, phase corev1.PodPhase) {
+	for _, pod := range listPods(name)() {
+		setPodPhase(&pod, phase)
+	}
+}
+
+func deleteOffline(name string) {
+	off := getOffline(name)()
+	if off == nil {
+		return
+	}
+	Expect(client.IgnoreNotFound(k8sClient.Delete(context.Background(), off))).To(Succeed())
+	Eventually(func() bool {
+		err := k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, &colocationv1.Offline{})
+		return errors.IsNotFound(err)
+	}, timeout, interval).Should(BeTrue())
+}
+
+var _ = Describe("Offline lifecycle", func() {
+	ctx := context.Background()
+
+	It("goes through Pending, Scheduling, Running and Succeeded with its gang", func() {
+		off := newTestOffline("lifecycle", "lifecycle", 0, 2)
+		Expect(k8sClient.Create(ctx, off)).To(Succeed())
+		defer deleteOffline("lifecycle")
+
+		Eventually(func() int { return len(listPods("lifecycle")()) }, timeout, interval).Should(Equal(2))
+		Eventually(offlinePhase("lifecycle"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineSchedulingPhase)))
+		Expect(getOffline("lifecycle")().Finalizers).To(ContainElement(OfflineFinalizer))
+		Expect(getOffline("lifecycle")().Status.StartTime).NotTo(BeNil())
+
+		setPodPhases("lifecycle", corev1.PodRunning)
+		Eventually(offlinePhase("lifecycle"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineRunningPhase)))
+		Expect(getOffline("lifecycle")().Status.PodRunning).To(Equal(int32(2)))
+
+		setPodPhases("lifecycle", corev1.PodSucceeded)
+		Eventually(offlinePhase("lifecycle"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineSucceededPhase)))
+
+		phases := make([]colocationv1.OfflinePhase, 0)
+		for _, transition := range getOffline("lifecycle")().Status.History {
+			phases = append(phases, transition.Phase)
+		}
+		Expect(phases).To(Equal([]colocationv1.OfflinePhase{
+			colocationv1.OfflinePendingPhase, colocationv1.OfflineSchedulingPhase,
+			colocationv1.OfflineRunningPhase, colocationv1.OfflineSucceededPhase,
+		}))
+	})
+
+	It("admits the queue in Level order once the admitted offline runs", func() {
+		Expect(k8sClient.Create(ctx, newTestOffline("head", "ordering", 0, 1))).To(Succeed())
+		defer deleteOffline("head")
+		Eventually(func() int { return len(listPods("head")()) }, timeout, interval).Should(Equal(1))
+
+		Expect(k8sClient.Create(ctx, newTestOffline("low", "ordering", 1, 1))).To(Succeed())
+		defer deleteOffline("low")
+		Expect(k8sClient.Create(ctx, newTestOffline("high", "ordering", 5, 1))).To(Succeed())
+		defer deleteOffline("high")
+		Eventually(offlinePhase("low"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+		Eventually(offlinePhase("high"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+		Consistently(func() int { return len(listPods("high")()) }, time.Second, interval).Should(Equal(0))
+
+		setPodPhases("head", corev1.PodRunning)
+		Eventually(func() int { return len(listPods("high")()) }, timeout, interval).Should(Equal(1))
+		Expect(listPods("low")()).To(BeEmpty())
+
+		setPodPhases("high", corev1.PodRunning)
+		Eventually(func() int { return len(listPods("low")()) }, timeout, interval).Should(Equal(1))
+	})
+
+	It("admits as many offlines as the admission window of the queue takes", func() {
+		testCache.SetPolicy("window", cache.Policy{MaxAdmitted: 2})
+		// each is created once the one before it is admitted, so the window
+		// fills in creation order
+		for _, name := range []string{"window-a", "window-b"} {
+			Expect(k8sClient.Create(ctx, newTestOffline(name, "window", 0, 1))).To(Succeed())
+			defer deleteOffline(name)
+			Eventually(func() int { return len(listPods(name)()) }, timeout, interval).Should(Equal(1))
+		}
+		Expect(k8sClient.Create(ctx, newTestOffline("window-c", "window", 0, 1))).To(Succeed())
+		defer deleteOffline("window-c")
+		Consistently(func() int { return len(listPods("window-c")()) }, time.Second, interval).Should(Equal(0))
+
+		setPodPhases("window-b", corev1.PodRunning)
+		Eventually(func() int { return len(listPods("window-c")()) }, timeout, interval).Should(Equal(1))
+	})
+
+	It("fails the gang when a pod fails and releases the others", func() {
+		Expect(k8sClient.Create(ctx, newTestOffline("failing", "failing", 0, 2))).To(Succeed())
+		defer deleteOffline("failing")
+		Eventually(func() int { return len(listPods("failing")()) }, timeout, interval).Should(Equal(2))
+
+		pod := listPods("failing")()[0]
+		setPodPhase(&pod, corev1.PodFailed)
+
+		Eventually(offlinePhase("failing"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineFailedPhase)))
+		Eventually(func() []string {
+			names := make([]string, 0)
+			for _, pod := range listPods("failing")() {
+				names = append(names, pod.Name)
+			}
+			return names
+		}, timeout, interval).Should(ConsistOf(pod.Name))
+	})
+
+	It("fails a gang that outlives its active deadline", func() {
+		off := newTestOffline("deadline", "deadline", 0, 1)
+		deadline := int64(1)
+		off.Spec.ActiveDeadlineSeconds = &deadline
+		Expect(k8sClient.Create(ctx, off)).To(Succeed())
+		defer deleteOffline("deadline")
+
+		Eventually(offlinePhase("deadline"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineFailedPhase)))
+		Expect(getOffline("deadline")().Status.Reason).To(Equal(colocationv1.OfflineDeadlineExceededReason))
+		Eventually(listPods("deadline"), timeout, interval).Should(BeEmpty())
+	})
+
+	It("places the gang with pod affinity for its topology", func() {
+		off := newTestOffline("packed", "topology", 0, 2)
+		off.Spec.Topology = &colocationv1.TopologyPolicy{Mode: colocationv1.TopologyPack}
+		Expect(k8sClient.Create(ctx, off)).To(Succeed())
+		defer deleteOffline("packed")
+
+		Eventually(func() int { return len(listPods("packed")()) }, timeout, interval).Should(Equal(2))
+		for _, pod := range listPods("packed")() {
+			Expect(pod.Spec.Affinity).NotTo(BeNil())
+			Expect(pod.Spec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+			term := pod.Spec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm
+			Expect(term.TopologyKey).To(Equal(corev1.LabelZoneFailureDomain))
+			Expect(term.LabelSelector.MatchLabels).To(HaveKeyWithValue(colocationv1.PodGroupLabel, "packed"))
+		}
+	})
+
+	It("holds an offline whose required topology no node satisfies", func() {
+		off := newTestOffline("unplaceable", "topology", 0, 2)
+		off.Spec.Topology = &colocationv1.TopologyPolicy{Mode: colocationv1.TopologySpread, Required: true}
+		Expect(k8sClient.Create(ctx, off)).To(Succeed())
+		defer deleteOffline("unplaceable")
+
+		Eventually(func() string {
+			if off := getOffline("unplaceable")(); off != nil {
+				return off.Status.Reason
+			}
+			return ""
+		}, timeout, interval).Should(Equal(colocationv1.OfflineTopologyUnsatisfiableReason))
+		Expect(offlinePhase("unplaceable")()).To(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+		Consistently(func() int { return len(listPods("unplaceable")()) }, time.Second, interval).Should(Equal(0))
+	})
+
+	It("deletes the pods of a deleted offline and admits the next one", func() {
+		Expect(k8sClient.Create(ctx, newTestOffline("deleted", "deletion", 0, 2))).To(Succeed())
+		Eventually(func() int { return len(listPods("deleted")()) }, timeout, interval).Should(Equal(2))
+		Expect(k8sClient.Create(ctx, newTestOffline("next", "deletion", 0, 1))).To(Succeed())
+		defer deleteOffline("next")
+		Eventually(offlinePhase("next"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+
+		deleteOffline("deleted")
+		Eventually(func() int {
+			podList := &corev1.PodList{}
+			Expect(k8sClient.List(ctx, podList, client.MatchingLabels{"offline": "deleted"})).To(Succeed())
+			return len(podList.Items)
+		}, timeout, interval).Should(Equal(0))
+		Eventually(func() int { return len(listPods("next")()) }, timeout, interval).Should(Equal(1))
+	})
+
+	It("rebuilds its cache after a restart without admitting an offline twice", func() {
+		Expect(k8sClient.Create(ctx, newTestOffline("before", "restart", 0, 2))).To(Succeed())
+		defer deleteOffline("before")
+		Eventually(func() int { return len(listPods("before")()) }, timeout, interval).Should(Equal(2))
+		Eventually(offlinePhase("before"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineSchedulingPhase)))
+
+		By("restarting the manager")
+		close(stopManager)
+		Expect(k8sClient.Create(ctx, newTestOffline("during", "restart", 9, 1))).To(Succeed())
+		defer deleteOffline("during")
+		startManager()
+
+		Eventually(offlinePhase("during"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+		Consistently(func() int { return len(listPods("before")()) }, 2*time.Second, interval).Should(Equal(2))
+		Expect(listPods("during")()).To(BeEmpty())
+
+		setPodPhases("before", corev1.PodRunning)
+		Eventually(offlinePhase("before"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflineRunningPhase)))
+		Eventually(func() int { return len(listPods("during")()) }, timeout, interval).Should(Equal(1))
+		Expect(listPods("before")()).To(HaveLen(2))
+	})
+})
@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"reflect"
@@ -22,6 +23,8 @@ type PodReconciler struct {
 	lastSeenPhase map[string]corev1.PodPhase
 }
 
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
+
 func (pr *PodReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := pr.Log.WithValues("pod", req.NamespacedName)
@@ -53,15 +56,44 @@ func (pr *PodReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	//3.get offline
 	off:=&v1.Offline{}
 	if err:=pr.Get(ctx,types.NamespacedName{Name:offlineName,Namespace:pod.Namespace},off);err!=nil{
-		//TODO should delete this pod and return nil
-		return ctrl.Result{},err
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{},err
+		}
+		//the offline is gone, don't keep its pods around
+		delete(pr.lastSeenPhase,req.NamespacedName.String())
+		if index,ok:=utils.ContainsString(pod.Finalizers,OfflineFinalizer);ok{
+			if err:=pr.removePodFinalizer(ctx,pod,index);err!=nil{
+				return ctrl.Result{},err
+			}
+		}
+		if pod.DeletionTimestamp.IsZero() {
+			return ctrl.Result{},client.IgnoreNotFound(pr.Delete(ctx,pod))
+		}
+		return ctrl.Result{},nil
 	}
 	//4.get lastPhase
 	lastPhase,exist:=pr.lastSeenPhase[req.NamespacedName.String()]
 
-	//pod creation
+	//5.deleteTimestamp changed, that means pod would be deleted
+	if !pod.DeletionTimestamp.IsZero() {
+		//deletePodEvent
+		if index,ok:=utils.ContainsString(pod.ObjectMeta.Finalizers,OfflineFinalizer);ok{
+			//update gang status without this pod
+			if err:=pr.syncOfflineStatus(ctx,off);err!=nil{
+				return ctrl.Result{},err
+			}
+			//delete pod.NamespacedName from lastSeenPhase
+			delete(pr.lastSeenPhase,req.NamespacedName.String())
+			//delete finalizer from pod.Finalizers
+			if err:=pr.removePodFinalizer(ctx,pod,index);err!=nil{
+				return ctrl.Result{},err
+			}
+		}
+		return ctrl.Result{},nil
+	}
+
+	//pod creation, or first seen since the controller started
 	if !exist{
-		pr.lastSeenPhase[req.NamespacedName.String()]=pod.Status.Phase
 		//add finalizer
 		if _,exist:=utils.ContainsString(pod.Finalizers,OfflineFinalizer);!exist{
 			pod.Finalizers=append(pod.Finalizers, OfflineFinalizer)
@@ -80,32 +112,12 @@ func (pr *PodReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 				return ctrl.Result{},err
 			}
 		}
+	}else if lastPhase==pod.Status.Phase{
 		return ctrl.Result{},nil
 	}
 
-	//we only care aboud two events. First, deleteTimestamp changed.Second
-	//pod.status.phase changed
-	//5.deleteTimestamp changed, that means pod would be deleted
-	if !pod.DeletionTimestamp.IsZero() {
-		//deletePodEvent
-		if index,ok:=utils.ContainsString(pod.ObjectMeta.Finalizers,OfflineFinalizer);ok{
-			//update gang status
-			if err:=pr.syncOfflineStatus(ctx,off,lastPhase,"");err!=nil{
-				return ctrl.Result{},err
-			}
-			//delete pod.NamespacedName from lastSeenPhase
-			delete(pr.lastSeenPhase,req.NamespacedName.String())
-			//delete finalizer from pod.Finalizers
-			if err:=pr.removePodFinalizer(ctx,pod,index);err!=nil{
-				return ctrl.Result{},err
-			}
-		}else{
-			//??????
-		}
-		return ctrl.Result{},nil
-	}
 	//6.pod status.phase changed,that means pod's phase changed
-	if err:=pr.syncOfflineStatus(ctx,off,lastPhase,pod.Status.Phase);err!=nil{
+	if err:=pr.syncOfflineStatus(ctx,off);err!=nil{
 		return ctrl.Result{},err
 	}
 	//7.update lastSeenPhase
@@ -113,16 +125,36 @@ func (pr *PodReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
-func (pr *PodReconciler) syncOfflineStatus(ctx context.Context, offline *v1.Offline,lastPhase,currentPhase corev1.PodPhase) error {
-	pr.calculateOfflineStatus(offline,lastPhase,currentPhase)
-
+// syncOfflineStatus counts the pods of offline by phase. Pods being deleted
+// aren't counted. Counting them all again rather than adding up phase
+// changes keeps the counters right across controller restarts.
+func (pr *PodReconciler) syncOfflineStatus(ctx context.Context, offline *v1.Offline) error {
+	podList:=&corev1.PodList{}
+	if err:=pr.List(ctx,podList,client.InNamespace(offline.Namespace));err!=nil{
+		return err
+	}
 	oldOff:=&v1.Offline{}
 	err := pr.Get(ctx, types.NamespacedName{Name: offline.Name, Namespace: offline.Namespace}, oldOff)
 	if err != nil {
 		return err
 	}
-	if !reflect.DeepEqual(oldOff.Status, offline.Status) {
-		oldOff.Status = offline.Status
+	//only the counters are written, the rest of the status belongs to OfflineReconciler
+	counted:=oldOff.DeepCopy()
+	counted.Status.PodPending=0
+	counted.Status.PodRunning=0
+	counted.Status.PodSucceeded=0
+	counted.Status.PodFailed=0
+	counted.Status.PodUnknown=0
+	for i := range podList.Items {
+		pod:=&podList.Items[i]
+		owner:=metav1.GetControllerOf(pod)
+		if owner==nil || owner.UID!=oldOff.UID || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		pr.calculatePodNumber(counted,pod.Status.Phase,1)
+	}
+	if !reflect.DeepEqual(oldOff.Status, counted.Status) {
+		oldOff.Status = counted.Status
 		if err = pr.Update(ctx, oldOff); err != nil {
 			pr.Log.Info("Update Offline failed")
 			return err
@@ -131,20 +163,6 @@ func (pr *PodReconciler) syncOfflineStatus(ctx context.Context, offline *v1.Offl
 	return nil
 }
 
-func (pr *PodReconciler)calculateOfflineStatus(offline *v1.Offline,lastPhase,currentPhase corev1.PodPhase){
-	if lastPhase ==currentPhase{
-		return
-	}
-	//update lastPhase
-	if lastPhase!=""{
-		pr.calculatePodNumber(offline,lastPhase,-1)
-	}
-	//update currentPhase
-	if currentPhase!=""{
-		pr.calculatePodNumber(offline,currentPhase,1)
-	}
-}
-
 func (pr *PodReconciler)calculatePodNumber(offline *v1.Offline,phase corev1.PodPhase,offset int32){
 	switch phase {
 	case corev1.PodRunning:
@@ -184,4 +202,13 @@ func NewPodController(client client.Client, log logr.Logger, scheme *runtime.Sch
 		Scheme:        scheme,
 		lastSeenPhase: make(map[string]corev1.PodPhase),
 	}
-}
\ No newline at end of file
+}
+
+func (pr *PodReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if pr.lastSeenPhase==nil {
+		pr.lastSeenPhase=make(map[string]corev1.PodPhase)
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Pod{}).
+		Complete(pr)
+}
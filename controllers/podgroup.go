@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// +kubebuilder:rbac:groups=colocation.cmyun.io,resources=podgroups,verbs=get;list;watch;create;update;patch;delete
+
+// podGroupPhase maps the phase of an offline to the phase of its PodGroup.
+func podGroupPhase(phase colocationv1.OfflinePhase) colocationv1.PodGroupPhase {
+	switch phase {
+	case colocationv1.OfflineSchedulingPhase:
+		return colocationv1.PodGroupScheduling
+	case colocationv1.OfflineRunningPhase:
+		return colocationv1.PodGroupRunning
+	case colocationv1.OfflineSucceededPhase:
+		return colocationv1.PodGroupFinished
+	case colocationv1.OfflineFailedPhase, colocationv1.OfflineUnknownPhase:
+		return colocationv1.PodGroupFailed
+	default:
+		return colocationv1.PodGroupPending
+	}
+}
+
+// newPodGroup returns the PodGroup an offline should have, named after it
+// and owned by it.
+func newPodGroup(off *colocationv1.Offline) *colocationv1.PodGroup {
+	minResources := off.Spec.MinResources
+	if len(minResources) == 0 {
+		minResources = utils.OfflineRequests(off)
+	}
+	flag := true
+	return &colocationv1.PodGroup{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: off.Namespace,
+			Name:      off.Name,
+			OwnerReferences: []v1.OwnerReference{{
+				APIVersion: OfflineAPIVersion,
+				Kind:       OfflineKind,
+				Name:       off.Name,
+				UID:        off.UID,
+				Controller: &flag,
+			}},
+		},
+		Spec: colocationv1.PodGroupSpec{
+			MinMember:    off.Spec.MinGang,
+			MinResources: &minResources,
+			Queue:        utils.GetOfflineQueueName(off),
+		},
+		Status: colocationv1.PodGroupStatus{
+			Phase:     podGroupPhase(off.Status.Phase),
+			Running:   off.Status.PodRunning,
+			Succeeded: off.Status.PodSucceeded,
+			Failed:    off.Status.PodFailed,
+		},
+	}
+}
+
+// syncPodGroup creates the PodGroup of an offline, or updates it to match
+// the spec and status of the offline.
+func (r *OfflineReconciler) syncPodGroup(ctx context.Context, off *colocationv1.Offline) error {
+	desired := newPodGroup(off)
+	pg := &colocationv1.PodGroup{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: off.Namespace, Name: off.Name}, pg); err != nil {
+		if errors.IsNotFound(err) {
+			return r.Create(ctx, desired)
+		}
+		return err
+	}
+	if reflect.DeepEqual(pg.Spec, desired.Spec) && reflect.DeepEqual(pg.Status, desired.Status) {
+		return nil
+	}
+	pg.Spec = desired.Spec
+	pg.Status = desired.Status
+	return r.Update(ctx, pg)
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// Standby mirrors every Offline into Cache from the informers of the
+// manager on every replica, leader or not, so a replica that becomes
+// leader starts with warm queues. Mirroring stops once the replica's
+// reconciler takes over the cache, from then on it alone changes it.
+type Standby struct {
+	Cache *cache.Cache
+	// Informers is the cache of the manager, the Offlines are mirrored
+	// from its informer and read from its store.
+	Informers ctrlcache.Cache
+	Log       logr.Logger
+
+	lock     sync.Mutex
+	synced   bool
+	leading  bool
+	promoted bool
+}
+
+// NeedLeaderElection lets standby replicas run the mirror too.
+func (s *Standby) NeedLeaderElection() bool {
+	return false
+}
+
+// Start registers the mirror on the Offline informer and waits for stop.
+func (s *Standby) Start(stop <-chan struct{}) error {
+	informer, err := s.Informers.GetInformer(&colocationv1.Offline{})
+	if err != nil {
+		return err
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    s.mirror,
+		UpdateFunc: func(_, obj interface{}) { s.mirror(obj) },
+		DeleteFunc: s.forget,
+	})
+	// The Offlines the informer listed before the handler was added reach
+	// the handler asynchronously, so the mirror is warmed from the store
+	// of the synced informer before it reports it has synced.
+	if !toolscache.WaitForCacheSync(stop, informer.HasSynced) {
+		return nil
+	}
+	wait.Until(func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.synced {
+			return
+		}
+		if err := s.catchUp(); err != nil {
+			s.Log.Error(err, "unable to read the offlines in the informer")
+			return
+		}
+		s.synced = true
+	}, time.Second, stop)
+	return nil
+}
+
+// catchUp mirrors the Offlines in the store of the informer.
+func (s *Standby) catchUp() error {
+	offList := &colocationv1.OfflineList{}
+	if err := s.Informers.List(context.Background(), offList); err != nil {
+		return err
+	}
+	s.Cache.MirrorAll(offList.Items, time.Now())
+	return nil
+}
+
+// Lead tells the standby its replica was elected, the reconciler is about
+// to take the cache over.
+func (s *Standby) Lead() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.leading = true
+}
+
+// Ready fails until the cache is rebuilt from the cluster: mirrored from
+// the synced informer on a standby replica, and taken over by the
+// reconciler as well on the leader.
+func (s *Standby) Ready(_ *http.Request) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case !s.synced:
+		return fmt.Errorf("offline informer not synced")
+	case s.leading && !s.promoted:
+		return fmt.Errorf("queues not taken over since this replica was elected")
+	}
+	return nil
+}
+
+func (s *Standby) mirror(obj interface{}) {
+	off, ok := obj.(*colocationv1.Offline)
+	if !ok {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.promoted {
+		s.Cache.Mirror(off, time.Now())
+	}
+}
+
+func (s *Standby) forget(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	off, ok := obj.(*colocationv1.Offline)
+	if !ok {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.promoted {
+		s.Cache.Forget(off)
+	}
+}
+
+// Promote stops mirroring and hands the mirrored cache over as it is,
+// once it has caught up with the store of the informer for the events
+// still on their way to the mirror. It is false until the mirror is warm.
+func (s *Standby) Promote() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.promoted {
+		return true
+	}
+	if !s.synced {
+		return false
+	}
+	if err := s.catchUp(); err != nil {
+		s.Log.Error(err, "unable to read the offlines in the informer")
+		return false
+	}
+	s.promoted = true
+	s.Log.V(0).Info("promoted, taking over the mirrored queues")
+	return true
+}
+
+// Promoted reports whether the reconciler has taken the cache over.
+func (s *Standby) Promoted() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.promoted
+}
+
+// takeOver promotes the standby mirror before the first reconcile of this
+// replica as leader, so that the queues it starts from hold every Offline
+// admitted by the former leader and none is admitted twice after a
+// failover.
+func (r *OfflineReconciler) takeOver() error {
+	if !r.standby.Promote() {
+		return fmt.Errorf("offline informer not synced")
+	}
+	return nil
+}
+
+// lead takes the cache over as soon as this replica is elected, so that a
+// leader without any Offline to reconcile becomes ready too. It is a
+// manager.Runnable started on the leader only.
+func (r *OfflineReconciler) lead(stop <-chan struct{}) error {
+	r.standby.Lead()
+	wait.Until(func() {
+		if r.standby.Promoted() {
+			return
+		}
+		if err := r.takeOver(); err != nil {
+			r.Log.Error(err, "unable to restore the queues")
+		}
+	}, time.Second, stop)
+	return nil
+}
+
+// Ready is the readiness check of the reconciler, see Standby.Ready.
+func (r *OfflineReconciler) Ready(req *http.Request) error {
+	return r.standby.Ready(req)
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// electedReplica starts a replica competing for leadership with short
+// leases, so a killed leader is replaced within seconds.
+func electedReplica() (*cache.Cache, chan struct{}) {
+	lease, renew, retry := 2*time.Second, time.Second, 200*time.Millisecond
+	return startReplica(ctrl.Options{
+		LeaderElection:          true,
+		LeaderElectionID:        "colocation-test-leader",
+		LeaderElectionNamespace: "default",
+		LeaseDuration:           &lease,
+		RenewDeadline:           &renew,
+		RetryPeriod:             &retry,
+	})
+}
+
+var _ = Describe("Leader election", func() {
+	ctx := context.Background()
+
+	BeforeEach(func() {
+		close(stopManager)
+	})
+
+	AfterEach(func() {
+		startManager()
+	})
+
+	It("fails over to a warm standby mid-admission without admitting an offline twice", func() {
+		_, stopLeader := electedReplica()
+		leading := true
+		var stopStandby chan struct{}
+		// a running replica removes the finalizers of the offlines, stop
+		// the replicas only once the offlines are deleted
+		defer func() {
+			for _, name := range []string{"third", "second", "gathering"} {
+				deleteOffline(name)
+			}
+			if leading {
+				close(stopLeader)
+			}
+			if stopStandby != nil {
+				close(stopStandby)
+			}
+		}()
+		Expect(k8sClient.Create(ctx, newTestOffline("gathering", "failover", 5, 2))).To(Succeed())
+		Eventually(func() int { return len(listPods("gathering")()) }, timeout, interval).Should(Equal(2))
+
+		var standbyCache *cache.Cache
+		standbyCache, stopStandby = electedReplica()
+		Expect(k8sClient.Create(ctx, newTestOffline("second", "failover", 1, 1))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newTestOffline("third", "failover", 0, 1))).To(Succeed())
+		Eventually(offlinePhase("second"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+		Eventually(offlinePhase("third"), timeout, interval).Should(Equal(colocationv1.OfflinePhase(colocationv1.OfflinePendingPhase)))
+
+		By("mirroring the queue on the standby")
+		Eventually(func() int32 { return standbyCache.Get("failover").Len() }, timeout, interval).Should(Equal(int32(2)))
+		Expect(standbyCache.Get("failover").IsAdmitted(getOffline("gathering")())).To(BeTrue())
+
+		By("killing the leader while the gang gathers")
+		close(stopLeader)
+		leading = false
+		Consistently(func() int { return len(listPods("second")()) }, 5*time.Second, interval).Should(Equal(0))
+		Expect(listPods("gathering")()).To(HaveLen(2))
+
+		By("letting the new leader admit the rest of the queue")
+		setPodPhases("gathering", corev1.PodRunning)
+		Eventually(func() int { return len(listPods("second")()) }, timeout, interval).Should(Equal(1))
+		Consistently(func() int { return len(listPods("third")()) }, time.Second, interval).Should(Equal(0))
+		setPodPhases("second", corev1.PodRunning)
+		Eventually(func() int { return len(listPods("third")()) }, timeout, interval).Should(Equal(1))
+
+		Consistently(func() []int {
+			return []int{len(listPods("gathering")()), len(listPods("second")()), len(listPods("third")())}
+		}, 2*time.Second, interval).Should(Equal([]int{2, 1, 1}))
+	})
+})
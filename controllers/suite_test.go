@@ -18,13 +18,16 @@ package controllers
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -39,6 +42,46 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// testCache is the cache of the manager started by startManager.
+var testCache *cache.Cache
+var stopManager chan struct{}
+
+// startManager starts a manager running the Offline and Pod reconcilers
+// with an empty cache, as a freshly started controller would.
+func startManager() {
+	testCache, stopManager = startReplica(ctrl.Options{})
+}
+
+// startReplica starts a manager with options running the Offline and Pod
+// reconcilers with an empty cache and returns the cache and the channel
+// stopping the manager.
+func startReplica(options ctrl.Options) (*cache.Cache, chan struct{}) {
+	options.Scheme = scheme.Scheme
+	options.MetricsBindAddress = "0"
+	mgr, err := ctrl.NewManager(cfg, options)
+	Expect(err).ToNot(HaveOccurred())
+
+	c := cache.NewCache()
+	c.GetUnSchedulableQ().SetBackoff(time.Hour, time.Hour)
+	err = (&OfflineReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("Offline"),
+		Scheme: mgr.GetScheme(),
+		Cache:  c,
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+	err = NewPodController(mgr.GetClient(), ctrl.Log.WithName("controllers").WithName("Pod"), mgr.GetScheme()).
+		SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	stop := make(chan struct{})
+	go func() {
+		defer GinkgoRecover()
+		Expect(mgr.Start(stop)).To(Succeed())
+	}()
+	return c, stop
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -69,11 +112,14 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
 
+	startManager()
+
 	close(done)
 }, 60)
 
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	close(stopManager)
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
 })
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// suspendResyncPeriod is how often a resumed offline checks whether the
+// pods deleted when it was suspended are gone.
+const suspendResyncPeriod = 5 * time.Second
+
+// isSuspended reports whether off must give up its pods. spec.suspend is
+// ignored once off succeeded or failed, lastPhase being the phase off had
+// before this reconcile: the pods of an offline already suspended may fail
+// while they are deleted, which doesn't finish it.
+func isSuspended(off *colocationv1.Offline, lastPhase colocationv1.OfflinePhase) bool {
+	if off.Spec.Suspend == nil || !*off.Spec.Suspend {
+		return false
+	}
+	if lastPhase == colocationv1.OfflineSuspendedPhase {
+		return true
+	}
+	return !isFinishedPhase(lastPhase) && !isFinished(off)
+}
+
+// suspend deletes the pods of an offline and takes it out of its queue,
+// admitting the next offline if it was in the admission window. It is queued
+// again once resumed, in the order of its creation timestamp. It returns
+// true when the next offline is held back for online workloads.
+func (r *OfflineReconciler) suspend(ctx context.Context, off *colocationv1.Offline, pods []corev1.Pod, queue *cache.Queue) (bool, error) {
+	for i := range pods {
+		if !pods[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &pods[i]); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	if off.Status.Phase != colocationv1.OfflineSuspendedPhase {
+		r.Log.V(0).Info("Offline{" + off.Name + "} suspended")
+	}
+
+	off.Status.StartTime = nil
+	off.Status.Backfilled = false
+	off.Status.ElasticReplicas = nil
+	off.Status.CurrentSize = 0
+
+	queue.Finish(off)
+	r.Cache.GetUnSchedulableQ().Forget(off)
+	if queue.IsAdmitted(off) {
+		queue.Release(off)
+		return r.admitNext(ctx, off, queue), nil
+	}
+	if _, exist := queue.Get(Key(off)); exist {
+		return false, queue.Delete(off)
+	}
+	return false, nil
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	colocationv1 "github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch
+
+// unschedulableFlushPeriod is how often offlines whose backoff expired are
+// moved out of the unschedulable queue.
+const unschedulableFlushPeriod = time.Second
+
+// flushUnschedulableQ periodically retries the offlines that are ready to
+// leave the unschedulable queue by sending them to Reconcile.
+func (r *OfflineReconciler) flushUnschedulableQ(stop <-chan struct{}) error {
+	wait.Until(func() {
+		for _, name := range r.Cache.GetUnSchedulableQ().Pop(time.Now()) {
+			r.Log.V(1).Info("retry unschedulable offline", "offline", name)
+			r.retryEvents <- event.GenericEvent{
+				Meta:   &v1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+				Object: &colocationv1.Offline{},
+			}
+		}
+	}, unschedulableFlushPeriod, stop)
+	return nil
+}
+
+// retryOffline deletes every pod of an offline popped from the unschedulable
+// queue and forgets when it started, so it goes back to pending and its
+// scheduling queue like an offline never admitted.
+func (r *OfflineReconciler) retryOffline(ctx context.Context, off *colocationv1.Offline, pods []corev1.Pod) error {
+	for i := range pods {
+		if !pods[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &pods[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	off.Status.StartTime = nil
+	off.Status.Backfilled = false
+	off.Status.ElasticReplicas = nil
+	off.Status.CurrentSize = 0
+	return nil
+}
+
+// awaitingRetry reports whether off failed to schedule and is to be
+// scheduled again, which keeps its TTL from deleting it meanwhile.
+func (r *OfflineReconciler) awaitingRetry(off *colocationv1.Offline, pods []corev1.Pod) bool {
+	if r.Cache.IsExistInUnSchedulableQ(off) || r.Cache.GetUnSchedulableQ().IsRetrying(off) {
+		return true
+	}
+	return off.Status.Phase == colocationv1.OfflineFailedPhase && off.Status.Reason == "" && !failedAfterRunning(pods)
+}
+
+// failedAfterRunning reports whether one of the failed pods of an offline
+// was bound to a node and not found unschedulable, so the offline failed
+// because its pods ran rather than because its gang couldn't be placed.
+func failedAfterRunning(pods []corev1.Pod) bool {
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodFailed && pod.Status.Phase != corev1.PodUnknown {
+			continue
+		}
+		if pod.Spec.NodeName != "" && !isUnschedulable(pod) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnschedulable(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			return true
+		}
+	}
+	return false
+}
+
+// unschedulableFlushHandlers returns the watches that may make unschedulable
+// offlines schedulable: nodes being added, pods freeing their resources and
+// resource quotas changing.
+func (r *OfflineReconciler) unschedulableFlushHandlers() map[runtime.Object]handler.EventHandler {
+	move := func(ev string) {
+		r.Cache.MoveAllToActiveQueue(ev)
+	}
+	return map[runtime.Object]handler.EventHandler{
+		&corev1.Node{}: handler.Funcs{
+			CreateFunc: func(event.CreateEvent, workqueue.RateLimitingInterface) {
+				move(cache.NodeAdd)
+			},
+		},
+		&corev1.Pod{}: handler.Funcs{
+			UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+				oldPod, ok := e.ObjectOld.(*corev1.Pod)
+				if !ok {
+					return
+				}
+				newPod, ok := e.ObjectNew.(*corev1.Pod)
+				if !ok {
+					return
+				}
+				if !isTerminated(oldPod) && isTerminated(newPod) {
+					move(cache.PodFreed)
+				}
+			},
+			DeleteFunc: func(event.DeleteEvent, workqueue.RateLimitingInterface) {
+				move(cache.PodFreed)
+			},
+		},
+		&corev1.ResourceQuota{}: handler.Funcs{
+			CreateFunc: func(event.CreateEvent, workqueue.RateLimitingInterface) {
+				move(cache.QuotaChange)
+			},
+			UpdateFunc: func(event.UpdateEvent, workqueue.RateLimitingInterface) {
+				move(cache.QuotaChange)
+			},
+			DeleteFunc: func(event.DeleteEvent, workqueue.RateLimitingInterface) {
+				move(cache.QuotaChange)
+			},
+		},
+	}
+}
+
+func isTerminated(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
@@ -0,0 +1,33 @@
+#!/usr/bin/env bash
+
+# Regenerates the typed clientset, informers and listers of pkg/client from
+# the +genclient types of api/v1. The types live in api/v1 rather than
+# api/colocation/v1, so the group is passed as api:v1 and named by the
+# +groupName of the package.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+MODULE=github.com/YunWang/colocation
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+CODEGEN_VERSION=${CODEGEN_VERSION:-kubernetes-1.16.0}
+
+CODEGEN_PKG=$(go env GOPATH)/pkg/mod/k8s.io/code-generator@${CODEGEN_VERSION}
+if [ ! -d "${CODEGEN_PKG}" ]; then
+	(cd "$(mktemp -d)" && go mod init tmp >/dev/null 2>&1 && go get -d "k8s.io/code-generator@${CODEGEN_VERSION}")
+	CODEGEN_PKG=$(ls -d "$(go env GOPATH)"/pkg/mod/k8s.io/code-generator@* | tail -1)
+fi
+
+# the generators write under a GOPATH layout, the result is copied back
+OUTPUT=$(mktemp -d)
+trap 'rm -rf "${OUTPUT}"' EXIT
+
+bash "${CODEGEN_PKG}/generate-groups.sh" client,informer,lister \
+	${MODULE}/pkg/client ${MODULE} \
+	api:v1 \
+	--output-base "${OUTPUT}" \
+	--go-header-file "${ROOT}/hack/boilerplate.go.txt"
+
+rm -rf "${ROOT}/pkg/client"
+cp -r "${OUTPUT}/${MODULE}/pkg/client" "${ROOT}/pkg/client"
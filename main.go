@@ -17,12 +17,28 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/config"
+	"github.com/YunWang/colocation/pkg/health"
+	"github.com/YunWang/colocation/pkg/history"
+	"github.com/YunWang/colocation/pkg/multicache"
+	"github.com/YunWang/colocation/pkg/predict"
+	"github.com/YunWang/colocation/pkg/reclaim"
+	"github.com/YunWang/colocation/pkg/utils"
+	"github.com/go-logr/logr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	colocationv1 "github.com/YunWang/colocation/api/v1"
+	colocationv1beta2 "github.com/YunWang/colocation/api/v1beta2"
 	"github.com/YunWang/colocation/controllers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -40,41 +56,216 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = colocationv1.AddToScheme(scheme)
+	_ = colocationv1beta2.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
+	// Flags registered before main, such as --kubeconfig, aren't part of
+	// the configuration and may be combined with --config.
+	external := map[string]bool{}
+	flag.VisitAll(func(f *flag.Flag) {
+		external[f.Name] = true
+	})
+
+	cfg := config.Default()
+	var configFile string
+	var agingStep, agingMax, maxAdmitted, unschedulableMaxAttempts int
+	var queueWeights string
+	var enableHPATrading bool
+	var enableBatchResources bool
+	var enableConversionWebhook bool
+	var maxAdmittedResources string
+	flag.StringVar(&configFile, "config", "",
+		"A "+config.Kind+" file the manager is configured by, the scheduling options, queues and timeouts of which are reloaded when it changes. The other configuration flags can't be combined with it.")
+	flag.StringVar(&cfg.Metrics.BindAddress, "metrics-addr", cfg.Metrics.BindAddress, "The address the metric endpoint binds to.")
+	flag.StringVar(&cfg.Health.BindAddress, "health-addr", cfg.Health.BindAddress, "The address the liveness and readiness probes bind to.")
+	flag.DurationVar(&cfg.Health.ReconcileTimeout.Duration, "reconcile-timeout", health.DefaultReconcileTimeout,
+		"How long a reconcile may run before the liveness probe fails.")
+	flag.BoolVar(&cfg.LeaderElection.Enabled, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&cfg.Scheduling.Backfill, "enable-backfill", false,
+		"Let smaller Offlines with a declared max runtime run ahead of a blocked head of their queue.")
+	flag.DurationVar(&cfg.Scheduling.Aging.Interval.Duration, "aging-interval", 0,
+		"How long an Offline waits in its queue for each aging step. 0 disables priority aging.")
+	flag.IntVar(&agingStep, "aging-step", int(cfg.Scheduling.Aging.Step), "The priority an Offline gains every aging interval.")
+	flag.IntVar(&agingMax, "aging-max", int(cfg.Scheduling.Aging.Max), "The maximum priority an Offline can gain by aging.")
+	flag.DurationVar(&cfg.Timeouts.UnschedulableInitialBackoff.Duration, "unschedulable-initial-backoff", cache.DefaultInitialBackoff,
+		"How long an Offline waits before it is retried the first time it fails to schedule.")
+	flag.DurationVar(&cfg.Timeouts.UnschedulableMaxBackoff.Duration, "unschedulable-max-backoff", cache.DefaultMaxBackoff,
+		"The maximum time an Offline waits before it is retried after failing to schedule.")
+	flag.IntVar(&unschedulableMaxAttempts, "unschedulable-max-attempts", cache.DefaultMaxAttempts,
+		"How many times an Offline may fail to schedule before it is failed for good. 0 retries it forever.")
+	flag.BoolVar(&cfg.Scheduling.FairShare, "enable-fair-share", false,
+		"Preempt Offlines of queues borrowing beyond their fair share when another queue needs its share back.")
+	flag.StringVar(&queueWeights, "queue-weights", "",
+		"Comma separated fair share weights of queues as name=weight or name=weight/parent.")
+	flag.BoolVar(&enableHPATrading, "enable-hpa-trading", false,
+		"Reserve capacity for the replicas HorizontalPodAutoscalers are about to add and shrink elastic Offlines ahead of them.")
+	flag.BoolVar(&enableBatchResources, "enable-batch-resources", false,
+		"Advertise reclaimable node capacity as batch extended resources and rewrite Offline tasks to request them.")
+	flag.BoolVar(&enableConversionWebhook, "enable-conversion-webhook", false,
+		"Serve the v1beta2 Offline API through a conversion webhook to and from the stored v1.")
+	flag.Float64Var(&cfg.BatchResources.SafetyMargin, "batch-safety-margin", reclaim.DefaultSafetyMargin,
+		"The part of a node's allocatable cpu and memory never advertised as batch resources.")
+	flag.IntVar(&maxAdmitted, "max-admitted", cache.DefaultMaxAdmitted,
+		"How many Offlines of a queue may gather their gangs at the same time.")
+	flag.StringVar(&maxAdmittedResources, "max-admitted-resources", "",
+		"Comma separated name=quantity bound on the resources requested by the Offlines of a queue gathering their gangs at the same time.")
+	flag.StringVar(&cfg.History.Sink, "history-sink", "",
+		"A file, or an http(s) URL, every Offline transition is streamed to as JSON lines. Empty disables the stream.")
+	flag.IntVar(&cfg.History.Limit, "history-limit", history.DefaultLimit,
+		"How many transitions an Offline keeps in its status.")
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(func(o *zap.Options) {
-		o.Development = true
-	}))
+	// The logger isn't set until the configuration is known, so errors in
+	// it go to stderr.
+	if configFile != "" {
+		var combined []string
+		flag.Visit(func(f *flag.Flag) {
+			if !external[f.Name] && f.Name != "config" {
+				combined = append(combined, "--"+f.Name)
+			}
+		})
+		if len(combined) > 0 {
+			fmt.Fprintf(os.Stderr, "%s can't be combined with --config, set them in %s\n", strings.Join(combined, ", "), configFile)
+			os.Exit(1)
+		}
+		loaded, err := config.Load(configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration %s: %v\n", configFile, err)
+			os.Exit(1)
+		}
+		cfg = loaded
+	} else {
+		cfg.Scheduling.Aging.Step = int32(agingStep)
+		cfg.Scheduling.Aging.Max = int32(agingMax)
+		cfg.Timeouts.UnschedulableMaxAttempts = int32(unschedulableMaxAttempts)
+		cfg.Scheduling.AdmissionWindow.MaxAdmitted = int32(maxAdmitted)
+		var err error
+		if cfg.Scheduling.AdmissionWindow.MaxResources, err = parseResourceList(maxAdmittedResources); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --max-admitted-resources: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.Queues, err = parseQueueWeights(queueWeights); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --queue-weights: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.FeatureGates = map[string]bool{
+			config.HPATrading:        enableHPATrading,
+			config.BatchResources:    enableBatchResources,
+			config.ConversionWebhook: enableConversionWebhook,
+		}
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid flags: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		LeaderElection:     enableLeaderElection,
-		Port:               9443,
-	})
+	ctrl.SetLogger(newLogger(cfg.Logging))
+
+	options := ctrl.Options{
+		Scheme:                  scheme,
+		MetricsBindAddress:      cfg.Metrics.BindAddress,
+		LeaderElection:          cfg.LeaderElection.Enabled,
+		LeaderElectionID:        cfg.LeaderElection.ID,
+		LeaderElectionNamespace: cfg.LeaderElection.Namespace,
+		Port:                    cfg.Webhook.Port,
+		CertDir:                 cfg.Webhook.CertDir,
+	}
+	switch len(cfg.Namespaces) {
+	case 0:
+	case 1:
+		options.Namespace = cfg.Namespaces[0]
+	default:
+		options.NewCache = multicache.New(cfg.Namespaces)
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.OfflineReconciler{
+	utils.DefaultQueue = cfg.DefaultQueue
+	offlineCache := cache.NewCache()
+	cfg.Apply(offlineCache)
+	if configFile != "" {
+		if err := mgr.Add(config.NewReloader(configFile, cfg, offlineCache, ctrl.Log.WithName("config"))); err != nil {
+			setupLog.Error(err, "unable to reload configuration")
+			os.Exit(1)
+		}
+	}
+
+	probes := health.NewServer(cfg.Health.BindAddress, ctrl.Log.WithName("health"))
+	if err := mgr.Add(probes); err != nil {
+		setupLog.Error(err, "unable to serve probes")
+		os.Exit(1)
+	}
+	watchdog := health.NewWatchdog(cfg.Health.ReconcileTimeout.Duration)
+	probes.AddLivenessCheck("reconcile", watchdog.Check)
+
+	var transitions history.Sink
+	if cfg.History.Sink != "" {
+		stream := history.NewStream(history.NewSink(cfg.History.Sink), history.DefaultBufferSize, ctrl.Log.WithName("history"))
+		if err := mgr.Add(stream); err != nil {
+			setupLog.Error(err, "unable to stream history")
+			os.Exit(1)
+		}
+		transitions = stream
+	}
+
+	offlineReconciler := &controllers.OfflineReconciler{
 		Client: mgr.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("Offline"),
 		Scheme: mgr.GetScheme(),
-		Cache:cache.NewCache(),
-	}).SetupWithManager(mgr); err != nil {
+		Cache:offlineCache,
+		History: transitions,
+		HistoryLimit: cfg.History.Limit,
+		Watchdog: watchdog,
+	}
+	if err = offlineReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Offline")
 		os.Exit(1)
 	}
+	probes.AddReadinessCheck("offline-cache", offlineReconciler.Ready)
+	if err = controllers.NewPodController(mgr.GetClient(),
+		ctrl.Log.WithName("controllers").WithName("Pod"), mgr.GetScheme()).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Pod")
+		os.Exit(1)
+	}
+	if cfg.Enabled(config.HPATrading) {
+		if err = (&controllers.HPAReconciler{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("controllers").WithName("HPA"),
+			Scheme:    mgr.GetScheme(),
+			Cache:     offlineCache,
+			Predictor: predict.NewHPAPredictor(predict.DefaultWindow),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "HPA")
+			os.Exit(1)
+		}
+	}
+	if cfg.Enabled(config.BatchResources) {
+		if err = (&controllers.NodeReconciler{
+			Client:       mgr.GetClient(),
+			Log:          ctrl.Log.WithName("controllers").WithName("Node"),
+			Scheme:       mgr.GetScheme(),
+			SafetyMargin: cfg.BatchResources.SafetyMargin,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Node")
+			os.Exit(1)
+		}
+	}
+	if err = (&colocationv1.Offline{}).SetupWebhookWithManager(mgr, cfg.Enabled(config.BatchResources)); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Offline")
+		os.Exit(1)
+	}
+	if cfg.Enabled(config.ConversionWebhook) {
+		if err = (&colocationv1beta2.Offline{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Offline", "version", "v1beta2")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -83,3 +274,73 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newLogger returns a zap logger at the level and in the format of logging.
+func newLogger(logging config.Logging) logr.Logger {
+	return zap.New(func(o *zap.Options) {
+		level := zapcore.InfoLevel
+		switch logging.Level {
+		case config.LogLevelDebug:
+			o.Development = true
+			level = zapcore.DebugLevel
+		case config.LogLevelError:
+			level = zapcore.ErrorLevel
+		}
+		atomicLevel := uberzap.NewAtomicLevelAt(level)
+		o.Level = &atomicLevel
+		if logging.Format == config.LogFormatJSON {
+			o.Encoder = zapcore.NewJSONEncoder(uberzap.NewProductionEncoderConfig())
+		} else {
+			o.Encoder = zapcore.NewConsoleEncoder(uberzap.NewDevelopmentEncoderConfig())
+		}
+	})
+}
+
+// parseQueueWeights parses weights as name=weight[/parent],... into the
+// queues of a configuration.
+func parseQueueWeights(weights string) (map[string]config.QueueConfig, error) {
+	if weights == "" {
+		return nil, nil
+	}
+	queues := map[string]config.QueueConfig{}
+	for _, item := range strings.Split(weights, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%q isn't name=weight", item)
+		}
+		queue := config.QueueConfig{}
+		value := parts[1]
+		if index := strings.Index(value, "/"); index >= 0 {
+			queue.Parent = value[index+1:]
+			value = value[:index]
+		}
+		weight, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("weight of queue %q must be a positive integer", parts[0])
+		}
+		queue.Weight = int32(weight)
+		queues[parts[0]] = queue
+	}
+	return queues, nil
+}
+
+// parseResourceList parses resources as name=quantity,... and returns nil
+// when it is empty.
+func parseResourceList(resources string) (corev1.ResourceList, error) {
+	if resources == "" {
+		return nil, nil
+	}
+	list := corev1.ResourceList{}
+	for _, item := range strings.Split(resources, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%q isn't name=quantity", item)
+		}
+		quantity, err := resource.ParseQuantity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("quantity of %q: %v", parts[0], err)
+		}
+		list[corev1.ResourceName(parts[0])] = quantity
+	}
+	return list, nil
+}
@@ -0,0 +1,352 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/reclaim"
+	"github.com/YunWang/colocation/pkg/utils"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultKubepodsCgroup is the cgroup kubelet puts pods under with
+	// the cgroupfs driver.
+	DefaultKubepodsCgroup = "kubepods"
+	// DefaultOfflineCgroup is where offline pods are moved, under the
+	// kubepods cgroup.
+	DefaultOfflineCgroup = "offline"
+	// DefaultCFSPeriod is the cfs period of the offline cgroups in
+	// microseconds.
+	DefaultCFSPeriod = 100000
+
+	// offlineCPUShares gives offline pods the smallest cpu weight, so
+	// online pods win any contention.
+	offlineCPUShares = 2
+	// minCFSQuota keeps offline pods making some progress when nothing is
+	// reclaimable.
+	minCFSQuota = 1000
+)
+
+type Config struct {
+	KubepodsCgroup string
+	OfflineCgroup  string
+	CFSPeriod      int64
+	// SafetyMargin is the part of the node never given to offline pods.
+	SafetyMargin float64
+	// CPUSetExclusion keeps offline pods off the cpus online pods need.
+	CPUSetExclusion bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		KubepodsCgroup: DefaultKubepodsCgroup,
+		OfflineCgroup:  DefaultOfflineCgroup,
+		CFSPeriod:      DefaultCFSPeriod,
+		SafetyMargin:   reclaim.DefaultSafetyMargin,
+	}
+}
+
+// Agent isolates the offline pods of one node: they are moved into a
+// dedicated cgroup hierarchy whose cpu and memory are limited to what online
+// pods leave reclaimable. Offline pods are evicted through Evictions, so
+// their PodDisruptionBudgets are respected.
+type Agent struct {
+	Client     client.Client
+	Evictions  typedcorev1.PodsGetter
+	FS         CgroupFS
+	NodeName   string
+	Config     Config
+	Watermarks *WatermarkConfig
+	Recorder   record.EventRecorder
+	Log        logr.Logger
+
+	lastLevel Watermark
+}
+
+// Run syncs the node every period until stop is closed.
+func (a *Agent) Run(period time.Duration, stop <-chan struct{}) {
+	wait.Until(func() {
+		if err := a.Sync(context.Background()); err != nil {
+			a.Log.Error(err, "sync failed", "node", a.NodeName)
+		}
+	}, period, stop)
+}
+
+// Sync applies the isolation of the offline pods running on the node now.
+func (a *Agent) Sync(ctx context.Context) error {
+	node := &corev1.Node{}
+	if err := a.Client.Get(ctx, types.NamespacedName{Name: a.NodeName}, node); err != nil {
+		return err
+	}
+	podList := &corev1.PodList{}
+	if err := a.Client.List(ctx, podList, client.MatchingFields{"spec.nodeName": a.NodeName}); err != nil {
+		return err
+	}
+	usage, err := reclaim.MeasuredUsage(ctx, a.Client)
+	if err != nil {
+		a.Log.Error(err, "unable to read pod metrics, online pods are counted by their requests")
+	}
+	if err := Apply(a.FS, a.Config, node, podList.Items, usage); err != nil {
+		return err
+	}
+	if a.Watermarks == nil {
+		return nil
+	}
+	return a.handlePressure(ctx, node, podList.Items)
+}
+
+// Apply sizes the offline cgroup from the usage of the online pods and
+// moves every offline pod into its own cgroup under it.
+func Apply(fs CgroupFS, cfg Config, node *corev1.Node, pods []corev1.Pod, usage reclaim.Usage) error {
+	online := make([]corev1.Pod, 0)
+	offline := make([]*corev1.Pod, 0)
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if IsOfflinePod(pod) {
+			offline = append(offline, pod)
+		} else {
+			online = append(online, *pod)
+		}
+	}
+
+	root := path.Join(cfg.KubepodsCgroup, cfg.OfflineCgroup)
+	budget := reclaim.Reclaimable(node, online, usage, cfg.SafetyMargin)
+	if err := applyRoot(fs, cfg, root, budget, online); err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	keep := make(map[string]bool)
+	for _, pod := range offline {
+		name := "pod" + string(pod.UID)
+		keep[name] = true
+		if err := applyPod(fs, cfg, root, name, pod); err != nil {
+			errs = append(errs, fmt.Errorf("pod %s/%s: %v", pod.Namespace, pod.Name, err))
+		}
+	}
+	for _, subsystem := range []string{CPU, Memory, Cpuset} {
+		children, err := fs.Children(subsystem, root)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, child := range children {
+			if !keep[child] {
+				// busy cgroups are removed once their processes exit
+				_ = fs.Remove(subsystem, path.Join(root, child))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// applyRoot limits the whole offline hierarchy to the reclaimable budget.
+func applyRoot(fs CgroupFS, cfg Config, root string, budget corev1.ResourceList, online []corev1.Pod) error {
+	for _, subsystem := range []string{CPU, Memory, Cpuset} {
+		if err := fs.Create(subsystem, root); err != nil {
+			return err
+		}
+	}
+	cpu := budget[v1.BatchCPUResource]
+	memory := budget[v1.BatchMemoryResource]
+	if err := setCPU(fs, cfg, root, cpu.Value()); err != nil {
+		return err
+	}
+	if err := fs.Write(Memory, root, "memory.limit_in_bytes", strconv.FormatInt(memory.Value(), 10)); err != nil {
+		return err
+	}
+
+	cpus, err := fs.Read(Cpuset, "/", "cpuset.cpus")
+	if err != nil {
+		return err
+	}
+	if cfg.CPUSetExclusion {
+		usage := corev1.ResourceList{}
+		for i := range online {
+			utils.AddResources(usage, utils.PodRequests(&online[i].Spec))
+		}
+		onlineCPU := usage[corev1.ResourceCPU]
+		cpus = ExcludeCPUs(cpus, int((onlineCPU.MilliValue()+999)/1000))
+	}
+	return copyCpuset(fs, "/", root, cpus)
+}
+
+// applyPod limits an offline pod to its own requests and moves its
+// processes from the cgroup kubelet made for it.
+func applyPod(fs CgroupFS, cfg Config, root, name string, pod *corev1.Pod) error {
+	dir := path.Join(root, name)
+	for _, subsystem := range []string{CPU, Memory, Cpuset} {
+		if err := fs.Create(subsystem, dir); err != nil {
+			return err
+		}
+	}
+	reqs := utils.PodRequests(&pod.Spec)
+	if cpu, ok := reqs[v1.BatchCPUResource]; ok {
+		if err := setCPU(fs, cfg, dir, cpu.Value()); err != nil {
+			return err
+		}
+	} else if cpu, ok := reqs[corev1.ResourceCPU]; ok {
+		if err := setCPU(fs, cfg, dir, cpu.MilliValue()); err != nil {
+			return err
+		}
+	}
+	memory, ok := reqs[v1.BatchMemoryResource]
+	if !ok {
+		memory, ok = reqs[corev1.ResourceMemory]
+	}
+	if ok && !memory.IsZero() {
+		if err := fs.Write(Memory, dir, "memory.limit_in_bytes", strconv.FormatInt(memory.Value(), 10)); err != nil {
+			return err
+		}
+	}
+	cpus, err := fs.Read(Cpuset, root, "cpuset.cpus")
+	if err != nil {
+		return err
+	}
+	if err := copyCpuset(fs, root, dir, cpus); err != nil {
+		return err
+	}
+
+	source := KubeletPodCgroup(cfg, pod)
+	for _, subsystem := range []string{CPU, Memory, Cpuset} {
+		if !fs.Exists(subsystem, source) {
+			continue
+		}
+		pids, err := procs(fs, subsystem, source)
+		if err != nil {
+			return err
+		}
+		for _, pid := range pids {
+			if err := fs.Write(subsystem, dir, ProcsFile, pid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setCPU gives a cgroup the lowest shares and a quota of milliCPU.
+func setCPU(fs CgroupFS, cfg Config, dir string, milliCPU int64) error {
+	quota := milliCPU * cfg.CFSPeriod / 1000
+	if quota < minCFSQuota {
+		quota = minCFSQuota
+	}
+	for file, value := range map[string]int64{
+		"cpu.shares":        offlineCPUShares,
+		"cpu.cfs_period_us": cfg.CFSPeriod,
+		"cpu.cfs_quota_us":  quota,
+	} {
+		if err := fs.Write(CPU, dir, file, strconv.FormatInt(value, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyCpuset sets the cpus of dir and the memory nodes of its parent, which
+// must be set before processes can join it.
+func copyCpuset(fs CgroupFS, parent, dir, cpus string) error {
+	mems, err := fs.Read(Cpuset, parent, "cpuset.mems")
+	if err != nil {
+		return err
+	}
+	if err := fs.Write(Cpuset, dir, "cpuset.cpus", cpus); err != nil {
+		return err
+	}
+	return fs.Write(Cpuset, dir, "cpuset.mems", mems)
+}
+
+// procs returns the processes of a cgroup and all its descendants.
+func procs(fs CgroupFS, subsystem, dir string) ([]string, error) {
+	content, err := fs.Read(subsystem, dir, ProcsFile)
+	if err != nil {
+		return nil, err
+	}
+	pids := strings.Fields(content)
+	children, err := fs.Children(subsystem, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		childPids, err := procs(fs, subsystem, path.Join(dir, child))
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, childPids...)
+	}
+	return pids, nil
+}
+
+// KubeletPodCgroup returns the cgroup kubelet made for pod, which depends on
+// its QoS class.
+func KubeletPodCgroup(cfg Config, pod *corev1.Pod) string {
+	name := "pod" + string(pod.UID)
+	switch pod.Status.QOSClass {
+	case corev1.PodQOSGuaranteed:
+		return path.Join(cfg.KubepodsCgroup, name)
+	case corev1.PodQOSBestEffort:
+		return path.Join(cfg.KubepodsCgroup, "besteffort", name)
+	default:
+		return path.Join(cfg.KubepodsCgroup, "burstable", name)
+	}
+}
+
+// ExcludeCPUs removes the first n cpus of a cpuset list such as "0-3,6",
+// always leaving at least one.
+func ExcludeCPUs(cpus string, n int) string {
+	list := make([]int, 0)
+	for _, part := range strings.Split(cpus, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return cpus
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return cpus
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			list = append(list, cpu)
+		}
+	}
+	if n >= len(list) {
+		n = len(list) - 1
+	}
+	if n <= 0 {
+		return cpus
+	}
+	kept := make([]string, 0, len(list)-n)
+	for _, cpu := range list[n:] {
+		kept = append(kept, strconv.Itoa(cpu))
+	}
+	return strings.Join(kept, ",")
+}
+
+// IsOfflinePod reports whether pod is controlled by an Offline.
+func IsOfflinePod(pod *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(pod)
+	return owner != nil && owner.APIVersion == v1.GroupVersion.String() && owner.Kind == "Offline"
+}
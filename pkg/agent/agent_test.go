@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newPod(uid string, cpu string, offline bool) corev1.Pod {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: uid, Namespace: "default", UID: types.UID(uid)},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse(cpu),
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, QOSClass: corev1.PodQOSBurstable},
+	}
+	if offline {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: v1.GroupVersion.String(),
+			Kind:       "Offline",
+			Name:       "offline",
+			Controller: &controller,
+		}}
+	}
+	return pod
+}
+
+var _ = Describe("Agent", func() {
+	var fs *FakeCgroupFS
+	var cfg Config
+	var node *corev1.Node
+
+	BeforeEach(func() {
+		fs = NewFakeCgroupFS()
+		cfg = DefaultConfig()
+		cfg.SafetyMargin = 0
+		for _, subsystem := range []string{CPU, Memory, Cpuset} {
+			Expect(fs.Create(subsystem, "/")).To(Succeed())
+		}
+		Expect(fs.Write(Cpuset, "/", "cpuset.cpus", "0-7")).To(Succeed())
+		Expect(fs.Write(Cpuset, "/", "cpuset.mems", "0")).To(Succeed())
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("8"),
+				corev1.ResourceMemory: resource.MustParse("16Gi"),
+			}},
+		}
+	})
+
+	It("limits the offline cgroup to what online pods leave", func() {
+		pods := []corev1.Pod{newPod("online", "3", false), newPod("offline", "1", true)}
+		Expect(Apply(fs, cfg, node, pods, nil)).To(Succeed())
+
+		Expect(fs.Read(CPU, "kubepods/offline", "cpu.cfs_quota_us")).To(Equal("500000"))
+		Expect(fs.Read(CPU, "kubepods/offline", "cpu.shares")).To(Equal("2"))
+		Expect(fs.Read(Memory, "kubepods/offline", "memory.limit_in_bytes")).To(Equal("16106127360"))
+		Expect(fs.Read(Cpuset, "kubepods/offline", "cpuset.cpus")).To(Equal("0-7"))
+	})
+
+	It("moves the processes of offline pods into their own cgroup", func() {
+		pods := []corev1.Pod{newPod("online", "1", false), newPod("offline", "1", true)}
+		for _, pod := range pods {
+			dir := KubeletPodCgroup(cfg, &pod) + "/container"
+			for _, subsystem := range []string{CPU, Memory, Cpuset} {
+				Expect(fs.Create(subsystem, dir)).To(Succeed())
+				Expect(fs.Write(subsystem, dir, ProcsFile, "pid-"+string(pod.UID))).To(Succeed())
+			}
+		}
+		Expect(Apply(fs, cfg, node, pods, nil)).To(Succeed())
+
+		for _, subsystem := range []string{CPU, Memory, Cpuset} {
+			Expect(fs.Read(subsystem, "kubepods/offline/podoffline", ProcsFile)).To(Equal("pid-offline"))
+			Expect(fs.Read(subsystem, "kubepods/burstable/podoffline/container", ProcsFile)).To(BeEmpty())
+			Expect(fs.Read(subsystem, "kubepods/burstable/podonline/container", ProcsFile)).To(Equal("pid-online"))
+		}
+		Expect(fs.Exists(CPU, "kubepods/offline/podonline")).To(BeFalse())
+		Expect(fs.Read(CPU, "kubepods/offline/podoffline", "cpu.cfs_quota_us")).To(Equal("100000"))
+		Expect(fs.Read(Memory, "kubepods/offline/podoffline", "memory.limit_in_bytes")).To(Equal("1073741824"))
+	})
+
+	It("removes the cgroups of offline pods that are gone", func() {
+		Expect(Apply(fs, cfg, node, []corev1.Pod{newPod("offline", "1", true)}, nil)).To(Succeed())
+		Expect(fs.Exists(Memory, "kubepods/offline/podoffline")).To(BeTrue())
+
+		Expect(Apply(fs, cfg, node, nil, nil)).To(Succeed())
+		Expect(fs.Exists(Memory, "kubepods/offline/podoffline")).To(BeFalse())
+	})
+
+	It("keeps offline pods off the cpus online pods need", func() {
+		cfg.CPUSetExclusion = true
+		pods := []corev1.Pod{newPod("online", "2500m", false), newPod("offline", "1", true)}
+		Expect(Apply(fs, cfg, node, pods, nil)).To(Succeed())
+
+		Expect(fs.Read(Cpuset, "kubepods/offline", "cpuset.cpus")).To(Equal("3,4,5,6,7"))
+		Expect(fs.Read(Cpuset, "kubepods/offline/podoffline", "cpuset.cpus")).To(Equal("3,4,5,6,7"))
+		Expect(fs.Read(Cpuset, "kubepods/offline/podoffline", "cpuset.mems")).To(Equal("0"))
+	})
+
+	It("always leaves one cpu", func() {
+		Expect(ExcludeCPUs("0-3", 8)).To(Equal("3"))
+		Expect(ExcludeCPUs("0-1,4", 0)).To(Equal("0-1,4"))
+	})
+})
@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Subsystems of the cgroup v1 hierarchies the agent manages.
+const (
+	CPU    = "cpu"
+	Memory = "memory"
+	Cpuset = "cpuset"
+)
+
+// ProcsFile lists the processes of a cgroup, writing a pid to it moves the
+// process into the cgroup.
+const ProcsFile = "cgroup.procs"
+
+// CgroupFS is the part of the cgroup filesystem the agent uses. Paths are
+// relative to the root of a subsystem's hierarchy.
+type CgroupFS interface {
+	// Create makes the cgroup at path, with its parents.
+	Create(subsystem, path string) error
+	Exists(subsystem, path string) bool
+	Read(subsystem, path, file string) (string, error)
+	Write(subsystem, path, file, value string) error
+	// Children returns the names of the cgroups right under path.
+	Children(subsystem, path string) ([]string, error)
+	// Remove deletes the empty cgroup at path.
+	Remove(subsystem, path string) error
+}
+
+// HostCgroupFS is the cgroup v1 filesystem mounted at Root, with one
+// directory per subsystem.
+type HostCgroupFS struct {
+	Root string
+}
+
+var _ CgroupFS = &HostCgroupFS{}
+
+func (h *HostCgroupFS) dir(subsystem, path string) string {
+	return filepath.Join(h.Root, subsystem, path)
+}
+
+func (h *HostCgroupFS) Create(subsystem, path string) error {
+	return os.MkdirAll(h.dir(subsystem, path), 0755)
+}
+
+func (h *HostCgroupFS) Exists(subsystem, path string) bool {
+	_, err := os.Stat(h.dir(subsystem, path))
+	return err == nil
+}
+
+func (h *HostCgroupFS) Read(subsystem, path, file string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(h.dir(subsystem, path), file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (h *HostCgroupFS) Write(subsystem, path, file, value string) error {
+	return ioutil.WriteFile(filepath.Join(h.dir(subsystem, path), file), []byte(value), 0644)
+}
+
+func (h *HostCgroupFS) Children(subsystem, path string) ([]string, error) {
+	infos, err := ioutil.ReadDir(h.dir(subsystem, path))
+	if err != nil {
+		return nil, err
+	}
+	children := make([]string, 0)
+	for _, info := range infos {
+		if info.IsDir() {
+			children = append(children, info.Name())
+		}
+	}
+	return children, nil
+}
+
+func (h *HostCgroupFS) Remove(subsystem, path string) error {
+	return os.Remove(h.dir(subsystem, path))
+}
+
+// FakeCgroupFS keeps cgroups in memory for tests. Like the kernel, writing
+// a pid to the ProcsFile of a cgroup removes it from every other cgroup of
+// the subsystem.
+type FakeCgroupFS struct {
+	lock    sync.Mutex
+	cgroups map[string]map[string]string
+}
+
+var _ CgroupFS = &FakeCgroupFS{}
+
+func NewFakeCgroupFS() *FakeCgroupFS {
+	return &FakeCgroupFS{cgroups: make(map[string]map[string]string)}
+}
+
+func fakeKey(subsystem, path string) string {
+	return subsystem + ":" + filepath.Clean("/"+path)
+}
+
+func (f *FakeCgroupFS) Create(subsystem, path string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for p := filepath.Clean("/" + path); ; p = filepath.Dir(p) {
+		if _, exist := f.cgroups[fakeKey(subsystem, p)]; !exist {
+			f.cgroups[fakeKey(subsystem, p)] = make(map[string]string)
+		}
+		if p == "/" {
+			return nil
+		}
+	}
+}
+
+func (f *FakeCgroupFS) Exists(subsystem, path string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	_, exist := f.cgroups[fakeKey(subsystem, path)]
+	return exist
+}
+
+func (f *FakeCgroupFS) Read(subsystem, path, file string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	files, exist := f.cgroups[fakeKey(subsystem, path)]
+	if !exist {
+		return "", fmt.Errorf("cgroup %s doesn't exist", fakeKey(subsystem, path))
+	}
+	return files[file], nil
+}
+
+func (f *FakeCgroupFS) Write(subsystem, path, file, value string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	files, exist := f.cgroups[fakeKey(subsystem, path)]
+	if !exist {
+		return fmt.Errorf("cgroup %s doesn't exist", fakeKey(subsystem, path))
+	}
+	if file != ProcsFile {
+		files[file] = value
+		return nil
+	}
+	prefix := subsystem + ":"
+	for key, other := range f.cgroups {
+		if strings.HasPrefix(key, prefix) {
+			other[ProcsFile] = removePid(other[ProcsFile], value)
+		}
+	}
+	files[ProcsFile] = strings.TrimSpace(files[ProcsFile] + "\n" + value)
+	return nil
+}
+
+func removePid(procs, pid string) string {
+	kept := make([]string, 0)
+	for _, p := range strings.Fields(procs) {
+		if p != pid {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func (f *FakeCgroupFS) Children(subsystem, path string) ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	parent := filepath.Clean("/" + path)
+	children := make([]string, 0)
+	for key := range f.cgroups {
+		if !strings.HasPrefix(key, subsystem+":") {
+			continue
+		}
+		p := strings.TrimPrefix(key, subsystem+":")
+		if p != "/" && filepath.Dir(p) == parent {
+			children = append(children, filepath.Base(p))
+		}
+	}
+	sort.Strings(children)
+	return children, nil
+}
+
+func (f *FakeCgroupFS) Remove(subsystem, path string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	key := fakeKey(subsystem, path)
+	files, exist := f.cgroups[key]
+	if !exist {
+		return fmt.Errorf("cgroup %s doesn't exist", key)
+	}
+	if strings.TrimSpace(files[ProcsFile]) != "" {
+		return fmt.Errorf("cgroup %s is busy", key)
+	}
+	delete(f.cgroups, key)
+	return nil
+}
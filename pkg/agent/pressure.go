@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/YunWang/colocation/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// MemoryUsage returns the memory used by the pods of the node.
+func MemoryUsage(fs CgroupFS, cfg Config) (int64, error) {
+	usage, err := fs.Read(Memory, cfg.KubepodsCgroup, "memory.usage_in_bytes")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(usage, 10, 64)
+}
+
+// SuppressCPU cuts the cpu quota of the offline cgroups to the minimum.
+func SuppressCPU(fs CgroupFS, cfg Config) error {
+	root := path.Join(cfg.KubepodsCgroup, cfg.OfflineCgroup)
+	children, err := fs.Children(CPU, root)
+	if err != nil {
+		return err
+	}
+	for _, dir := range append([]string{root}, prefixAll(root, children)...) {
+		if err := fs.Write(CPU, dir, "cpu.cfs_quota_us", strconv.Itoa(minCFSQuota)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func prefixAll(root string, names []string) []string {
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, path.Join(root, name))
+	}
+	return paths
+}
+
+// handlePressure grades the response to the memory usage of the node and
+// records every action in the status and Events of the Offlines affected.
+func (a *Agent) handlePressure(ctx context.Context, node *corev1.Node, pods []corev1.Pod) error {
+	usage, err := MemoryUsage(a.FS, a.Config)
+	if err != nil {
+		return err
+	}
+	allocatable := node.Status.Allocatable[corev1.ResourceMemory]
+	level := a.Watermarks.For(node).Level(usage, allocatable.Value())
+
+	offline := make([]*corev1.Pod, 0)
+	for i := range pods {
+		if IsOfflinePod(&pods[i]) && pods[i].DeletionTimestamp.IsZero() &&
+			pods[i].Status.Phase != corev1.PodSucceeded && pods[i].Status.Phase != corev1.PodFailed {
+			offline = append(offline, &pods[i])
+		}
+	}
+
+	if level >= LowWatermark {
+		if err := SuppressCPU(a.FS, a.Config); err != nil {
+			return err
+		}
+	}
+	if err := a.setFence(ctx, node, level >= MiddleWatermark); err != nil {
+		return err
+	}
+
+	if level != a.lastLevel {
+		action := v1.PressureRelievedAction
+		switch level {
+		case LowWatermark:
+			action = v1.PressureThrottledAction
+		case MiddleWatermark, HighWatermark:
+			action = v1.PressureFencedAction
+		}
+		a.Log.Info("memory pressure changed", "node", node.Name, "watermark", level.String(), "usage", usage)
+		for _, owner := range owners(offline) {
+			a.recordPressure(ctx, owner, node.Name, level, action, false)
+		}
+		a.lastLevel = level
+	}
+
+	if level == HighWatermark {
+		return a.evictLowest(ctx, node.Name, offline)
+	}
+	return nil
+}
+
+// setFence sets or removes the MemoryPressureLabel of the node, which
+// keeps new offline pods off it while online pods are still placed there.
+func (a *Agent) setFence(ctx context.Context, node *corev1.Node, fenced bool) error {
+	_, labelled := node.Labels[v1.MemoryPressureLabel]
+	if fenced == labelled {
+		return nil
+	}
+	if fenced {
+		if node.Labels == nil {
+			node.Labels = make(map[string]string)
+		}
+		node.Labels[v1.MemoryPressureLabel] = "true"
+	} else {
+		delete(node.Labels, v1.MemoryPressureLabel)
+	}
+	return a.Client.Update(ctx, node)
+}
+
+// evictLowest evicts the offline pod whose Offline has the lowest Level,
+// the most recently created among equals. Pods are evicted through the
+// Eviction API, a pod whose PodDisruptionBudget doesn't allow it is passed
+// over for the next one.
+func (a *Agent) evictLowest(ctx context.Context, nodeName string, pods []*corev1.Pod) error {
+	if len(pods) == 0 {
+		return nil
+	}
+	levels := make(map[types.NamespacedName]int32)
+	for _, owner := range owners(pods) {
+		off := &v1.Offline{}
+		if err := a.Client.Get(ctx, owner, off); err == nil {
+			levels[owner] = off.Spec.Level
+		}
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		li, lj := levels[ownerOf(pods[i])], levels[ownerOf(pods[j])]
+		if li != lj {
+			return li < lj
+		}
+		return pods[j].CreationTimestamp.Before(&pods[i].CreationTimestamp)
+	})
+	for _, victim := range pods {
+		eviction := &policyv1beta1.Eviction{ObjectMeta: metav1.ObjectMeta{Namespace: victim.Namespace, Name: victim.Name}}
+		err := a.Evictions.Pods(victim.Namespace).Evict(eviction)
+		if errors.IsTooManyRequests(err) {
+			a.Log.V(1).Info("eviction refused by disruption budget", "node", nodeName, "pod", victim.Name)
+			continue
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		a.Log.Info("evicted offline pod under memory pressure", "node", nodeName, "pod", victim.Name)
+		a.recordPressure(ctx, ownerOf(victim), nodeName, HighWatermark, v1.PressureEvictedAction, true)
+		return nil
+	}
+	a.Log.Info("no offline pod may be evicted under memory pressure", "node", nodeName)
+	return nil
+}
+
+// recordPressure sets the MemoryPressure status of an Offline and records
+// the action as an Event on it.
+func (a *Agent) recordPressure(ctx context.Context, name types.NamespacedName, nodeName string, level Watermark, action string, evicted bool) {
+	off := &v1.Offline{}
+	if err := a.Client.Get(ctx, name, off); err != nil {
+		a.Log.Error(err, "unable to get offline", "offline", name)
+		return
+	}
+	off.Status.MemoryPressure = &v1.OfflinePressure{
+		Node:      nodeName,
+		Watermark: level.String(),
+		Action:    action,
+		Time:      metav1.Now(),
+	}
+	if evicted {
+		off.Status.PodEvicted++
+	}
+	if err := a.Client.Update(ctx, off); err != nil {
+		a.Log.Error(err, "unable to update offline", "offline", name)
+	}
+	if a.Recorder != nil {
+		eventType := corev1.EventTypeNormal
+		if level != NoWatermark {
+			eventType = corev1.EventTypeWarning
+		}
+		a.Recorder.Eventf(off, eventType, "MemoryPressure"+action,
+			"node %s is above the %s memory watermark", nodeName, level.String())
+	}
+}
+
+func ownerOf(pod *corev1.Pod) types.NamespacedName {
+	owner := metav1.GetControllerOf(pod)
+	return types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}
+}
+
+func owners(pods []*corev1.Pod) []types.NamespacedName {
+	seen := make(map[types.NamespacedName]bool)
+	names := make([]types.NamespacedName, 0)
+	for _, pod := range pods {
+		name := ownerOf(pod)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
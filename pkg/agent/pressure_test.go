@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Watermarks", func() {
+	It("grades usage against allocatable memory", func() {
+		w := DefaultWatermarks
+		Expect(w.Level(60, 100)).To(Equal(NoWatermark))
+		Expect(w.Level(70, 100)).To(Equal(LowWatermark))
+		Expect(w.Level(85, 100)).To(Equal(MiddleWatermark))
+		Expect(w.Level(95, 100)).To(Equal(HighWatermark))
+	})
+
+	It("loads per pool watermarks", func() {
+		file, err := ioutil.TempFile("", "watermarks")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(file.Name())
+		_, err = file.WriteString("poolLabel: pool\ndefault: {low: 0.5, middle: 0.6, high: 0.7}\npools:\n  batch: {low: 0.8, middle: 0.9, high: 0.95}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		cfg, err := LoadWatermarkConfig(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		batch := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"pool": "batch"}}}
+		Expect(cfg.For(batch).High).To(Equal(0.95))
+		Expect(cfg.For(&corev1.Node{}).High).To(Equal(0.7))
+	})
+
+	It("rejects watermarks out of order", func() {
+		Expect(Watermarks{Low: 0.8, Middle: 0.7, High: 0.9}.Validate()).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Memory pressure", func() {
+	var (
+		ctx      context.Context
+		fs       *FakeCgroupFS
+		c        client.Client
+		recorder *record.FakeRecorder
+		a        *Agent
+		// guarded names the pods whose disruption budget refuses eviction
+		guarded map[string]bool
+	)
+
+	newOffline := func(name string, level int32) *v1.Offline {
+		return &v1.Offline{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1.OfflineSpec{Level: level},
+		}
+	}
+	ownedPod := func(name, owner string, created time.Time) *corev1.Pod {
+		pod := newPod(name, "1", true)
+		pod.Spec.NodeName = "node"
+		pod.OwnerReferences[0].Name = owner
+		pod.CreationTimestamp = metav1.NewTime(created)
+		return &pod
+	}
+	setUsage := func(usage string) {
+		Expect(fs.Write(Memory, a.Config.KubepodsCgroup, "memory.usage_in_bytes", usage)).To(Succeed())
+		Expect(a.Sync(ctx)).To(Succeed())
+	}
+	getNode := func() *corev1.Node {
+		node := &corev1.Node{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "node"}, node)).To(Succeed())
+		return node
+	}
+	getOffline := func(name string) *v1.Offline {
+		off := &v1.Offline{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, off)).To(Succeed())
+		return off
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fs = NewFakeCgroupFS()
+		for _, subsystem := range []string{CPU, Memory, Cpuset} {
+			Expect(fs.Create(subsystem, "/")).To(Succeed())
+		}
+		Expect(fs.Create(Memory, "kubepods")).To(Succeed())
+		Expect(fs.Write(Cpuset, "/", "cpuset.cpus", "0-7")).To(Succeed())
+		Expect(fs.Write(Cpuset, "/", "cpuset.mems", "0")).To(Succeed())
+
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1.AddToScheme(scheme)).To(Succeed())
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("8"),
+				corev1.ResourceMemory: resource.MustParse("1000"),
+			}},
+		}
+		now := time.Now()
+		c = fake.NewFakeClientWithScheme(scheme, node,
+			newOffline("low", 1), newOffline("high", 5),
+			ownedPod("low-old", "low", now.Add(-time.Hour)),
+			ownedPod("low-new", "low", now),
+			ownedPod("high", "high", now))
+		recorder = record.NewFakeRecorder(10)
+		guarded = make(map[string]bool)
+		clientset := kubefake.NewSimpleClientset()
+		clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.Eviction)
+			if guarded[eviction.Name] {
+				return true, nil, errors.NewTooManyRequests("disruption budget", 0)
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: eviction.Namespace, Name: eviction.Name}}
+			return true, nil, c.Delete(ctx, pod)
+		})
+		a = &Agent{
+			Client:     c,
+			Evictions:  clientset.CoreV1(),
+			FS:         fs,
+			NodeName:   "node",
+			Config:     DefaultConfig(),
+			Watermarks: DefaultWatermarkConfig(),
+			Recorder:   recorder,
+			Log:        log.Log,
+		}
+	})
+
+	It("throttles offline pods above the low watermark", func() {
+		setUsage("750")
+		Expect(fs.Read(CPU, "kubepods/offline", "cpu.cfs_quota_us")).To(Equal("1000"))
+		Expect(fs.Read(CPU, "kubepods/offline/podlow-new", "cpu.cfs_quota_us")).To(Equal("1000"))
+		Expect(getNode().Labels).NotTo(HaveKey(v1.MemoryPressureLabel))
+		Expect(getOffline("low").Status.MemoryPressure.Action).To(Equal(v1.PressureThrottledAction))
+		Expect(recorder.Events).To(Receive(ContainSubstring("MemoryPressureThrottled")))
+	})
+
+	It("fences the node off above the middle watermark without tainting it", func() {
+		setUsage("850")
+		Expect(getNode().Labels).To(HaveKeyWithValue(v1.MemoryPressureLabel, "true"))
+		Expect(getNode().Spec.Taints).To(BeEmpty())
+		Expect(getOffline("high").Status.MemoryPressure.Action).To(Equal(v1.PressureFencedAction))
+
+		setUsage("100")
+		Expect(getNode().Labels).NotTo(HaveKey(v1.MemoryPressureLabel))
+		Expect(getOffline("high").Status.MemoryPressure.Action).To(Equal(v1.PressureRelievedAction))
+	})
+
+	It("evicts the newest pod of the lowest level offline above the high watermark", func() {
+		setUsage("950")
+		pod := &corev1.Pod{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "low-new"}, pod)
+		Expect(err).To(HaveOccurred())
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "low-old"}, pod)).To(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "high"}, pod)).To(Succeed())
+
+		off := getOffline("low")
+		Expect(off.Status.PodEvicted).To(Equal(int32(1)))
+		Expect(off.Status.MemoryPressure.Action).To(Equal(v1.PressureEvictedAction))
+	})
+
+	It("passes over a pod its disruption budget protects", func() {
+		guarded["low-new"] = true
+		setUsage("950")
+		pod := &corev1.Pod{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "low-new"}, pod)).To(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "low-old"}, pod)).NotTo(Succeed())
+		Expect(getOffline("low").Status.PodEvicted).To(Equal(int32(1)))
+	})
+})
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Watermark is a level of memory pressure on a node.
+type Watermark int
+
+const (
+	NoWatermark Watermark = iota
+	LowWatermark
+	MiddleWatermark
+	HighWatermark
+)
+
+func (w Watermark) String() string {
+	switch w {
+	case LowWatermark:
+		return "Low"
+	case MiddleWatermark:
+		return "Middle"
+	case HighWatermark:
+		return "High"
+	default:
+		return "None"
+	}
+}
+
+// Watermarks are fractions of a node's allocatable memory. Above Low the
+// cpu quota of offline pods is suppressed, above Middle no new offline pod
+// is admitted to the node and above High offline pods are evicted, lowest
+// Level first.
+type Watermarks struct {
+	Low    float64 `json:"low"`
+	Middle float64 `json:"middle"`
+	High   float64 `json:"high"`
+}
+
+// DefaultWatermarks are used for nodes whose pool has none configured.
+var DefaultWatermarks = Watermarks{Low: 0.7, Middle: 0.8, High: 0.9}
+
+func (w Watermarks) Validate() error {
+	if !(0 < w.Low && w.Low < w.Middle && w.Middle < w.High && w.High <= 1) {
+		return fmt.Errorf("watermarks must satisfy 0 < low < middle < high <= 1, got %v/%v/%v", w.Low, w.Middle, w.High)
+	}
+	return nil
+}
+
+// Level returns the highest watermark usage of allocatable has crossed.
+func (w Watermarks) Level(usage, allocatable int64) Watermark {
+	if allocatable <= 0 {
+		return NoWatermark
+	}
+	ratio := float64(usage) / float64(allocatable)
+	switch {
+	case ratio >= w.High:
+		return HighWatermark
+	case ratio >= w.Middle:
+		return MiddleWatermark
+	case ratio >= w.Low:
+		return LowWatermark
+	default:
+		return NoWatermark
+	}
+}
+
+// WatermarkConfig sets the watermarks of each node pool, a pool being the
+// nodes sharing the value of the PoolLabel.
+type WatermarkConfig struct {
+	PoolLabel string                `json:"poolLabel,omitempty"`
+	Default   Watermarks            `json:"default"`
+	Pools     map[string]Watermarks `json:"pools,omitempty"`
+}
+
+func DefaultWatermarkConfig() *WatermarkConfig {
+	return &WatermarkConfig{Default: DefaultWatermarks}
+}
+
+// LoadWatermarkConfig reads and validates a WatermarkConfig in YAML.
+func LoadWatermarkConfig(path string) (*WatermarkConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := DefaultWatermarkConfig()
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Default.Validate(); err != nil {
+		return nil, fmt.Errorf("default: %v", err)
+	}
+	for pool, w := range cfg.Pools {
+		if err := w.Validate(); err != nil {
+			return nil, fmt.Errorf("pool %q: %v", pool, err)
+		}
+	}
+	return cfg, nil
+}
+
+// For returns the watermarks of the pool node belongs to.
+func (c *WatermarkConfig) For(node *corev1.Node) Watermarks {
+	if c.PoolLabel != "" {
+		if w, ok := c.Pools[node.Labels[c.PoolLabel]]; ok {
+			return w
+		}
+	}
+	return c.Default
+}
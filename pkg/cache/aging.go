@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"sort"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+)
+
+// AgingPolicy raises the priority of an Offline with the time it waits in
+// its queue, so a steady stream of high Level Offlines can't starve the
+// low Level ones.
+type AgingPolicy struct {
+	// Interval is how long an Offline waits for each Step.
+	Interval time.Duration
+	// Step is the priority gained every Interval.
+	Step int32
+	// Max caps the priority gained by aging.
+	Max int32
+}
+
+// EffectivePriority returns the Level of off raised by the time it has been
+// waiting since its creation.
+func (p *AgingPolicy) EffectivePriority(off *v1.Offline, now time.Time) int32 {
+	level := off.Spec.Level
+	if p == nil || p.Interval <= 0 || p.Step <= 0 {
+		return level
+	}
+	waited := now.Sub(off.CreationTimestamp.Time)
+	if waited <= 0 {
+		return level
+	}
+	gained := int64(waited/p.Interval) * int64(p.Step)
+	if gained > int64(p.Max) {
+		gained = int64(p.Max)
+	}
+	return level + int32(gained)
+}
+
+// NextAging returns how long until the effective priority of off is raised
+// again, or false once it has reached the cap.
+func (p *AgingPolicy) NextAging(off *v1.Offline, now time.Time) (time.Duration, bool) {
+	if p == nil || p.Interval <= 0 || p.Step <= 0 {
+		return 0, false
+	}
+	if p.EffectivePriority(off, now)-off.Spec.Level >= p.Max {
+		return 0, false
+	}
+	waited := now.Sub(off.CreationTimestamp.Time)
+	if waited < 0 {
+		return -waited + p.Interval, true
+	}
+	return p.Interval - waited%p.Interval, true
+}
+
+// SortVictims orders Offlines the way they should be preempted: lowest
+// effective priority first and, among equals, the most recently created.
+func SortVictims(offs []*v1.Offline) {
+	sort.SliceStable(offs, func(i, j int) bool {
+		return utils.LessFn(offs[j], offs[i])
+	})
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func withLevel(off *v1.Offline, level int32) *v1.Offline {
+	off.Spec.Level = level
+	return off
+}
+
+var _ = Describe("AgingPolicy", func() {
+	aging := &AgingPolicy{Interval: time.Minute, Step: 2, Max: 5}
+
+	It("raises the level by a step every interval waited, up to the cap", func() {
+		off := withLevel(newOffline("off", "1", 0), -3)
+		Expect(aging.EffectivePriority(off, epoch.Add(30*time.Second))).To(BeEquivalentTo(-3))
+		Expect(aging.EffectivePriority(off, epoch.Add(time.Minute))).To(BeEquivalentTo(-1))
+		Expect(aging.EffectivePriority(off, epoch.Add(2*time.Minute))).To(BeEquivalentTo(1))
+		Expect(aging.EffectivePriority(off, epoch.Add(time.Hour))).To(BeEquivalentTo(2))
+	})
+
+	It("tells when the priority is raised next, until the cap", func() {
+		off := newOffline("off", "1", 0)
+		next, ok := aging.NextAging(off, epoch.Add(90*time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(next).To(Equal(30 * time.Second))
+
+		_, ok = aging.NextAging(off, epoch.Add(3*time.Minute))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("keeps the level when disabled", func() {
+		off := withLevel(newOffline("off", "1", 0), 4)
+		Expect((*AgingPolicy)(nil).EffectivePriority(off, epoch.Add(time.Hour))).To(BeEquivalentTo(4))
+		Expect((&AgingPolicy{Interval: time.Minute}).EffectivePriority(off, epoch.Add(time.Hour))).To(BeEquivalentTo(4))
+	})
+})
+
+var _ = Describe("Priority", func() {
+	It("is the level until aging sets the effective priority", func() {
+		off := withLevel(newOffline("off", "1", 0), -2)
+		Expect(utils.Priority(off)).To(BeEquivalentTo(-2))
+
+		priority := int32(1)
+		off.Status.EffectivePriority = &priority
+		Expect(utils.Priority(off)).To(BeEquivalentTo(1))
+	})
+
+	It("orders the queue with negative levels last", func() {
+		queue := newQueueWithPolicy("default", Policy{})
+		Expect(queue.AddSchedulingQ(withLevel(newOffline("negative", "1", 0), -1))).To(Succeed())
+		Expect(queue.AddSchedulingQ(newOffline("zero", "1", time.Second))).To(Succeed())
+		Expect(queue.Peek().Name).To(Equal("zero"))
+	})
+})
+
+var _ = Describe("SortVictims", func() {
+	It("puts the lowest priority first and the newest first among equals", func() {
+		offs := []*v1.Offline{
+			withLevel(newOffline("high", "1", 0), 5),
+			newOffline("old", "1", 0),
+			newOffline("new", "1", time.Minute),
+			withLevel(newOffline("low", "1", 2*time.Minute), -1),
+		}
+		SortVictims(offs)
+		names := make([]string, 0, len(offs))
+		for _, off := range offs {
+			names = append(names, off.Name)
+		}
+		Expect(names).To(Equal([]string{"low", "new", "old", "high"}))
+	})
+})
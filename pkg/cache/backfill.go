@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Forever is the wait estimated for a head whose demand can't be met by
+// Offlines that are known to finish.
+const Forever = time.Duration(math.MaxInt64)
+
+// EstimateWait returns how long head must wait until enough resources are
+// free for its whole gang, assuming running Offlines release their resources
+// when their MaxRuntimeSeconds expires.
+func EstimateWait(head *v1.Offline, free corev1.ResourceList, running []*v1.Offline, now time.Time) time.Duration {
+	demand := utils.OfflineRequests(head)
+	available := free.DeepCopy()
+	if utils.FitsResources(demand, available) {
+		return 0
+	}
+
+	type release struct {
+		at   time.Time
+		reqs corev1.ResourceList
+	}
+	releases := make([]release, 0, len(running))
+	for _, off := range running {
+		deadline, ok := RuntimeDeadline(off)
+		if !ok {
+			continue
+		}
+		releases = append(releases, release{at: deadline, reqs: utils.OfflineRequests(off)})
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].at.Before(releases[j].at)
+	})
+	for _, r := range releases {
+		utils.AddResources(available, r.reqs)
+		if utils.FitsResources(demand, available) {
+			if r.at.Before(now) {
+				return 0
+			}
+			return r.at.Sub(now)
+		}
+	}
+	return Forever
+}
+
+// RuntimeDeadline returns when a started Offline is expected to have
+// finished according to its MaxRuntimeSeconds.
+func RuntimeDeadline(off *v1.Offline) (time.Time, bool) {
+	if off.Spec.MaxRuntimeSeconds == nil || off.Status.StartTime == nil {
+		return time.Time{}, false
+	}
+	return off.Status.StartTime.Add(time.Duration(*off.Spec.MaxRuntimeSeconds) * time.Second), true
+}
+
+// Backfill pops the Offlines that can run ahead of the blocked head without
+// delaying it: they must fit in free now and declare a MaxRuntimeSeconds
+// shorter than wait. Candidates are taken in queue order and the returned
+// Offlines are remembered as backfilled until Finish is called.
+func (q *Queue) Backfill(free corev1.ResourceList, wait time.Duration) []*v1.Offline {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.policy.Backfill || len(q.admitted) == 0 {
+		return nil
+	}
+
+	candidates := q.List()
+	sort.Slice(candidates, func(i, j int) bool {
+		return utils.LessFn(candidates[i], candidates[j])
+	})
+
+	available := free.DeepCopy()
+	admitted := make([]*v1.Offline, 0)
+	for _, off := range candidates {
+		if off.Spec.MaxRuntimeSeconds == nil {
+			continue
+		}
+		if wait != Forever && time.Duration(*off.Spec.MaxRuntimeSeconds)*time.Second >= wait {
+			continue
+		}
+		reqs := utils.OfflineRequests(off)
+		if !utils.FitsResources(reqs, available) {
+			continue
+		}
+		if err := q.schedulingQ.Delete(off); err != nil {
+			continue
+		}
+		utils.SubResources(available, reqs)
+		q.backfilled[key(off)] = off
+		admitted = append(admitted, off)
+	}
+	return admitted
+}
+
+// IsBackfilled reports whether the Offline was admitted by Backfill.
+func (q *Queue) IsBackfilled(off *v1.Offline) bool {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	_, exist := q.backfilled[key(off)]
+	return exist
+}
+
+// RestoreBackfilled remembers off as backfilled, restoring an Offline
+// backfilled before the controller restarted.
+func (q *Queue) RestoreBackfilled(off *v1.Offline) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, exist, _ := q.schedulingQ.Get(off); exist {
+		_ = q.schedulingQ.Delete(off)
+	}
+	delete(q.admitted, key(off))
+	q.backfilled[key(off)] = off
+}
+
+// Finish forgets a backfilled Offline once it no longer holds resources
+// reserved for the head.
+func (q *Queue) Finish(off *v1.Offline) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.backfilled, key(off))
+}
+
+func key(off *v1.Offline) string {
+	k, _ := utils.KeyFn(off)
+	return k
+}
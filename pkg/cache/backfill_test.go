@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func cpu(quantity string) corev1.ResourceList {
+	return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(quantity)}
+}
+
+// newOffline returns an Offline of one pod requesting cpu, created that
+// long after epoch.
+func newOffline(name, requests string, created time.Duration) *v1.Offline {
+	return &v1.Offline{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(epoch.Add(created)),
+		},
+		Spec: v1.OfflineSpec{
+			MinGang: 1,
+			Tasks: []*corev1.PodTemplateSpec{{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:      "main",
+					Resources: corev1.ResourceRequirements{Requests: cpu(requests)},
+				}}},
+			}},
+		},
+	}
+}
+
+// started sets when off started and how long it declares to run.
+func started(off *v1.Offline, at time.Duration, maxRuntime int64) *v1.Offline {
+	start := metav1.NewTime(epoch.Add(at))
+	off.Status.StartTime = &start
+	off.Spec.MaxRuntimeSeconds = &maxRuntime
+	return off
+}
+
+func withMaxRuntime(off *v1.Offline, maxRuntime int64) *v1.Offline {
+	off.Spec.MaxRuntimeSeconds = &maxRuntime
+	return off
+}
+
+var _ = Describe("EstimateWait", func() {
+	now := epoch.Add(time.Minute)
+
+	It("doesn't wait when the head fits now", func() {
+		Expect(EstimateWait(newOffline("head", "2", 0), cpu("2"), nil, now)).To(BeZero())
+	})
+
+	It("waits for the earliest releases that make room for the head", func() {
+		running := []*v1.Offline{
+			started(newOffline("late", "4", 0), 0, 600),
+			started(newOffline("early", "1", 0), 0, 120),
+			started(newOffline("middle", "1", 0), 0, 300),
+		}
+		Expect(EstimateWait(newOffline("head", "3", 0), cpu("1"), running, now)).To(Equal(4 * time.Minute))
+	})
+
+	It("doesn't wait for overdue releases", func() {
+		running := []*v1.Offline{started(newOffline("overdue", "2", 0), 0, 30)}
+		Expect(EstimateWait(newOffline("head", "2", 0), cpu("0"), running, now)).To(BeZero())
+	})
+
+	It("waits forever when the Offlines known to finish don't make room", func() {
+		running := []*v1.Offline{
+			started(newOffline("bounded", "1", 0), 0, 120),
+			newOffline("unbounded", "4", 0),
+		}
+		Expect(EstimateWait(newOffline("head", "4", 0), cpu("1"), running, now)).To(Equal(Forever))
+	})
+})
+
+var _ = Describe("Queue.Backfill", func() {
+	var queue *Queue
+
+	BeforeEach(func() {
+		queue = newQueueWithPolicy("default", Policy{Backfill: true})
+		queue.Admit(newOffline("head", "8", 0))
+	})
+
+	names := func(offs []*v1.Offline) []string {
+		result := make([]string, 0, len(offs))
+		for _, off := range offs {
+			result = append(result, off.Name)
+		}
+		return result
+	}
+
+	It("admits the Offlines that fit now and finish before the head may start", func() {
+		for _, off := range []*v1.Offline{
+			withMaxRuntime(newOffline("short", "1", time.Second), 60),
+			withMaxRuntime(newOffline("long", "1", 2*time.Second), 600),
+			newOffline("unbounded", "1", 3*time.Second),
+			withMaxRuntime(newOffline("large", "4", 4*time.Second), 60),
+			withMaxRuntime(newOffline("fits", "1", 5*time.Second), 60),
+		} {
+			Expect(queue.AddSchedulingQ(off)).To(Succeed())
+		}
+
+		backfilled := queue.Backfill(cpu("2"), 5*time.Minute)
+		Expect(names(backfilled)).To(Equal([]string{"short", "fits"}))
+		Expect(queue.IsBackfilled(backfilled[0])).To(BeTrue())
+		Expect(queue.Len()).To(BeEquivalentTo(3))
+	})
+
+	It("admits any bounded Offline that fits when the head waits forever", func() {
+		Expect(queue.AddSchedulingQ(withMaxRuntime(newOffline("long", "1", time.Second), 86400))).To(Succeed())
+		Expect(names(queue.Backfill(cpu("1"), Forever))).To(Equal([]string{"long"}))
+	})
+
+	It("admits nothing while no head is blocked or backfill is disabled", func() {
+		Expect(queue.AddSchedulingQ(withMaxRuntime(newOffline("short", "1", time.Second), 60))).To(Succeed())
+
+		idle := newQueueWithPolicy("idle", Policy{Backfill: true})
+		Expect(idle.AddSchedulingQ(withMaxRuntime(newOffline("short", "1", time.Second), 60))).To(Succeed())
+		Expect(idle.Backfill(cpu("2"), time.Hour)).To(BeEmpty())
+
+		queue.setPolicy(Policy{})
+		Expect(queue.Backfill(cpu("2"), time.Hour)).To(BeEmpty())
+	})
+
+	It("forgets a backfilled Offline once it finishes", func() {
+		off := withMaxRuntime(newOffline("short", "1", time.Second), 60)
+		Expect(queue.AddSchedulingQ(off)).To(Succeed())
+		Expect(queue.Backfill(cpu("2"), time.Hour)).To(HaveLen(1))
+
+		queue.Finish(off)
+		Expect(queue.IsBackfilled(off)).To(BeFalse())
+	})
+
+	It("isn't empty while a backfilled Offline runs", func() {
+		off := withMaxRuntime(newOffline("short", "1", time.Second), 60)
+		Expect(queue.AddSchedulingQ(off)).To(Succeed())
+		Expect(queue.Backfill(cpu("2"), time.Hour)).To(HaveLen(1))
+		queue.Release(queue.Admitted()[0])
+		Expect(queue.IsEmpty()).To(BeFalse())
+
+		queue.Finish(off)
+		Expect(queue.IsEmpty()).To(BeTrue())
+	})
+})
@@ -3,78 +3,190 @@ package cache
 import (
 	"fmt"
 	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog"
+	"sync"
+	"time"
 )
 
 type Cache struct {
 	queues map[string]*Queue
-	unschedulableQ map[string]struct{}
+	unschedulableQ *UnschedulableQueue
+	policies map[string]Policy
+	defaultPolicy Policy
+	fairShare bool
+	onlineReservation corev1.ResourceList
+	// lock guards the policies, fair share and online reservation, which
+	// are reloaded while the controllers read them.
+	lock sync.RWMutex
+	// queuesLock guards queues, which the standby mirror and the
+	// reconciler both fill.
+	queuesLock sync.RWMutex
 }
 
 func(c *Cache) Add(name string)error{
+	c.queuesLock.Lock()
+	defer c.queuesLock.Unlock()
 	if _,exist:=c.queues[name];exist {
-		klog.V(0).Info("Queue %v has existed!",name)
+		klog.V(0).Infof("Queue %v has existed!",name)
 		return nil
 	}
-	c.queues[name]=NewQueue()
+	c.queues[name]=newQueueWithPolicy(name,c.GetPolicy(name))
 	return nil
 }
 
 func(c *Cache) Delete(name string)error{
+	c.queuesLock.Lock()
+	defer c.queuesLock.Unlock()
 	if _,exist := c.queues[name];!exist {
-		klog.V(0).Info("Queue %v isn't exist",name)
+		klog.V(0).Infof("Queue %v isn't exist",name)
 		return nil
 	}
 	delete(c.queues, name)
 	return nil
 }
 
+// List returns the names of all queues.
+func(c *Cache) List() []string{
+	c.queuesLock.RLock()
+	defer c.queuesLock.RUnlock()
+	names:=make([]string,0,len(c.queues))
+	for name:=range c.queues{
+		names=append(names, name)
+	}
+	return names
+}
+
 func(c *Cache) Get(name string) *Queue{
+	c.queuesLock.Lock()
+	defer c.queuesLock.Unlock()
 	if _,exist := c.queues[name];!exist{
-		c.queues[name]=NewQueue()
+		c.queues[name]=newQueueWithPolicy(name,c.GetPolicy(name))
 		return c.queues[name]
 	}
 	return c.queues[name]
 }
 
+// SetDefaultPolicy sets the policy of queues without one of their own.
+func(c *Cache) SetDefaultPolicy(policy Policy){
+	c.queuesLock.Lock()
+	defer c.queuesLock.Unlock()
+	c.lock.Lock()
+	c.defaultPolicy=policy
+	c.lock.Unlock()
+	for name,q:=range c.queues{
+		if _,exist:=c.policies[name];!exist{
+			q.setPolicy(policy)
+		}
+	}
+}
+
+// SetPolicy sets the policy of the named queue, including an existing one.
+func(c *Cache) SetPolicy(name string,policy Policy){
+	c.queuesLock.Lock()
+	defer c.queuesLock.Unlock()
+	c.lock.Lock()
+	c.policies[name]=policy
+	c.lock.Unlock()
+	if q,exist:=c.queues[name];exist{
+		q.setPolicy(policy)
+	}
+}
+
+// SetPolicies replaces the default policy and the policies of all named
+// queues, existing queues take theirs at once.
+func(c *Cache) SetPolicies(defaultPolicy Policy,policies map[string]Policy){
+	c.queuesLock.Lock()
+	defer c.queuesLock.Unlock()
+	c.lock.Lock()
+	c.defaultPolicy=defaultPolicy
+	c.policies=make(map[string]Policy,len(policies))
+	for name,policy:=range policies{
+		c.policies[name]=policy
+	}
+	c.lock.Unlock()
+	for name,q:=range c.queues{
+		q.setPolicy(c.GetPolicy(name))
+	}
+}
+
+// SetFairShare enables reclaiming capacity lent to other queues.
+func(c *Cache) SetFairShare(enabled bool){
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fairShare=enabled
+}
+
+func(c *Cache) FairShareEnabled()bool{
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.fairShare
+}
+
+// SetOnlineReservation sets the resources online workloads are expected to
+// need soon, offlines aren't admitted into them.
+func(c *Cache) SetOnlineReservation(reservation corev1.ResourceList){
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onlineReservation=reservation
+}
+
+func(c *Cache) GetOnlineReservation()corev1.ResourceList{
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.onlineReservation.DeepCopy()
+}
+
+func(c *Cache) GetPolicy(name string)Policy{
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if policy,exist:=c.policies[name];exist{
+		return policy
+	}
+	return c.defaultPolicy
+}
+
+// ErrMaxAttempts is returned for an offline that has been unschedulable as
+// many times as allowed, it isn't retried anymore.
+var ErrMaxAttempts=fmt.Errorf("offline has been unschedulable too many times")
+
 func(c *Cache) AddToUnSchedulableQ(off *v1.Offline) error{
-	key:=c.key(off)
-	if _,exist:=c.unschedulableQ[key];exist{
-		klog.V(0).Info("Offline has existed, you cann't add again!")
-		return nil
+	if !c.unschedulableQ.Add(off,time.Now()){
+		return ErrMaxAttempts
 	}
-	c.unschedulableQ[key]= struct{}{}
 	return nil
 }
 
 func(c *Cache) DeleteFromUnSchedulableQ(off *v1.Offline)error{
-	key:=c.key(off)
-	if _,exist:=c.unschedulableQ[key];!exist{
-		klog.V(0).Info("Offline isn't exist!")
-		return nil
-	}
-	delete(c.unschedulableQ, key)
+	c.unschedulableQ.Delete(off)
 	return nil
 }
 
-func(c *Cache) key(off *v1.Offline)string{
-	return fmt.Sprintf("%s/%s", off.Namespace, off.Name)
+func(c *Cache) IsExistInUnSchedulableQ(off *v1.Offline)bool{
+	return c.unschedulableQ.Has(off)
 }
 
-func(c *Cache) IsExistInUnSchedulableQ(off *v1.Offline)bool{
-	key:=c.key(off)
-	if _,exist:=c.unschedulableQ[key];exist{
-		return true
-	}else{
-		return false
-	}
+func(c *Cache) GetUnSchedulableQ()*UnschedulableQueue{
+	return c.unschedulableQ
+}
+
+// MoveAllToActiveQueue is called on events that may make unschedulable
+// offlines schedulable, they are retried once their backoff expires.
+func(c *Cache) MoveAllToActiveQueue(event string){
+	c.unschedulableQ.MoveAll(event)
+}
+
+func(c *Cache) key(off *v1.Offline)string{
+	return fmt.Sprintf("%s/%s", off.Namespace, off.Name)
 }
 
 func NewCache()*Cache{
 	c:= &Cache{
 		queues:make(map[string]*Queue),
-		unschedulableQ: make(map[string]struct{}),
+		unschedulableQ: NewUnschedulableQueue(DefaultInitialBackoff,DefaultMaxBackoff),
+		policies: make(map[string]Policy),
 	}
-	c.queues["default"]=NewQueue()
+	c.queues[utils.DefaultQueue]=newQueueWithPolicy(utils.DefaultQueue,c.defaultPolicy)
 	return c
 }
\ No newline at end of file
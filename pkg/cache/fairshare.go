@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"sort"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultWeight is the weight of a queue whose policy doesn't set one.
+const DefaultWeight = 1
+
+type shareNode struct {
+	name     string
+	weight   int64
+	demand   corev1.ResourceList
+	children []*shareNode
+}
+
+// FairShare returns the share of total each queue deserves given the
+// resources demanded by its Offlines, admitted or waiting. Capacity is
+// divided among the queues without a parent in proportion to their weights,
+// and the share of a parent queue among its children the same way. A queue
+// never deserves more than it demands: the capacity it leaves idle goes to
+// its siblings first and then up the hierarchy. The Offlines of a parent
+// queue take part in its children's division as if they were one more child
+// with the parent's weight.
+func (c *Cache) FairShare(total corev1.ResourceList, demand map[string]corev1.ResourceList) map[string]corev1.ResourceList {
+	// the policies may be reloaded meanwhile, divide by a copy of them
+	c.lock.RLock()
+	defaultPolicy := c.defaultPolicy
+	policies := make(map[string]Policy, len(c.policies))
+	for name, policy := range c.policies {
+		policies[name] = policy
+	}
+	c.lock.RUnlock()
+	policy := func(name string) Policy {
+		if p, exist := policies[name]; exist {
+			return p
+		}
+		return defaultPolicy
+	}
+
+	nodes := make(map[string]*shareNode)
+	node := func(name string) *shareNode {
+		if n, exist := nodes[name]; exist {
+			return n
+		}
+		weight := int64(policy(name).Weight)
+		if weight <= 0 {
+			weight = DefaultWeight
+		}
+		n := &shareNode{name: name, weight: weight, demand: corev1.ResourceList{}}
+		nodes[name] = n
+		return n
+	}
+	for _, name := range c.List() {
+		node(name)
+	}
+	for name := range policies {
+		node(name)
+	}
+	for name, reqs := range demand {
+		node(name).demand = reqs.DeepCopy()
+	}
+
+	root := &shareNode{}
+	for _, name := range sortedNames(nodes) {
+		n := nodes[name]
+		parent := policy(name).Parent
+		if p, exist := nodes[parent]; exist && parent != name && !isAncestor(policy, name, parent) {
+			p.children = append(p.children, n)
+		} else {
+			root.children = append(root.children, n)
+		}
+	}
+
+	shares := make(map[string]corev1.ResourceList)
+	divide(root, total, shares)
+	return shares
+}
+
+// isAncestor reports whether name is an ancestor of queue, which would make
+// queue's parent link a cycle.
+func isAncestor(policy func(string) Policy, name, queue string) bool {
+	seen := make(map[string]bool)
+	for parent := policy(queue).Parent; parent != "" && !seen[parent]; parent = policy(parent).Parent {
+		if parent == name {
+			return true
+		}
+		seen[parent] = true
+	}
+	return false
+}
+
+func sortedNames(nodes map[string]*shareNode) []string {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// subtreeDemand returns what n and all its descendants demand.
+func subtreeDemand(n *shareNode) corev1.ResourceList {
+	reqs := n.demand.DeepCopy()
+	for _, child := range n.children {
+		utils.AddResources(reqs, subtreeDemand(child))
+	}
+	return reqs
+}
+
+// divide splits capacity among the children of n, and n itself when it has
+// its own demand, then recurses into the children.
+func divide(n *shareNode, capacity corev1.ResourceList, shares map[string]corev1.ResourceList) {
+	type claimant struct {
+		weight int64
+		demand corev1.ResourceList
+		share  corev1.ResourceList
+	}
+	claimants := make([]*claimant, 0, len(n.children)+1)
+	for _, child := range n.children {
+		claimants = append(claimants, &claimant{weight: child.weight, demand: subtreeDemand(child), share: corev1.ResourceList{}})
+	}
+	self := &claimant{weight: n.weight, demand: n.demand, share: corev1.ResourceList{}}
+	if n.name != "" {
+		claimants = append(claimants, self)
+	}
+
+	for name, quantity := range capacity {
+		weights := make([]int64, len(claimants))
+		demands := make([]int64, len(claimants))
+		for i, cl := range claimants {
+			weights[i] = cl.weight
+			value := cl.demand[name]
+			demands[i] = value.MilliValue()
+		}
+		for i, milli := range waterfill(quantity.MilliValue(), weights, demands) {
+			claimants[i].share[name] = *resource.NewMilliQuantity(milli, quantity.Format)
+		}
+	}
+
+	if n.name != "" {
+		shares[n.name] = self.share
+	}
+	for i, child := range n.children {
+		divide(child, claimants[i].share, shares)
+	}
+}
+
+// waterfill divides capacity in proportion to weights without giving anyone
+// more than its demand, handing what is left over to the others.
+func waterfill(capacity int64, weights, demands []int64) []int64 {
+	alloc := make([]int64, len(weights))
+	active := make([]int, 0, len(weights))
+	for i := range weights {
+		if demands[i] > 0 {
+			active = append(active, i)
+		}
+	}
+	remaining := capacity
+	for remaining > 0 && len(active) > 0 {
+		var totalWeight int64
+		for _, i := range active {
+			totalWeight += weights[i]
+		}
+		given := int64(0)
+		next := active[:0]
+		for _, i := range active {
+			// remaining*weight/totalWeight, without overflowing
+			give := remaining/totalWeight*weights[i] + remaining%totalWeight*weights[i]/totalWeight
+			if give == 0 {
+				give = 1
+			}
+			if alloc[i]+give >= demands[i] {
+				give = demands[i] - alloc[i]
+			} else {
+				next = append(next, i)
+			}
+			if given+give > remaining {
+				give = remaining - given
+			}
+			alloc[i] += give
+			given += give
+		}
+		remaining -= given
+		active = next
+		if given == 0 {
+			break
+		}
+	}
+	return alloc
+}
+
+// Reclaim returns the admitted Offlines to preempt so that demand of the
+// claimant queue fits in free. Only Offlines of queues using more than their
+// deserved share are taken, lowest effective priority first, and a queue is
+// never taken below its deserved share. Nothing is returned when the
+// claimant is itself borrowing or when preempting every candidate still
+// wouldn't make room.
+func Reclaim(claimant string, demand, free corev1.ResourceList, admitted map[string][]*v1.Offline, deserved map[string]corev1.ResourceList) []*v1.Offline {
+	usage := make(map[string]corev1.ResourceList)
+	for name, offs := range admitted {
+		usage[name] = corev1.ResourceList{}
+		for _, off := range offs {
+			utils.AddResources(usage[name], utils.OfflineRequests(off))
+		}
+	}
+	claimed := demand.DeepCopy()
+	if used, exist := usage[claimant]; exist {
+		utils.AddResources(claimed, used)
+	}
+	if !utils.FitsResources(claimed, deserved[claimant]) {
+		return nil
+	}
+
+	candidates := make([]*v1.Offline, 0)
+	for name, offs := range admitted {
+		if name == claimant {
+			continue
+		}
+		candidates = append(candidates, offs...)
+	}
+	SortVictims(candidates)
+
+	available := free.DeepCopy()
+	victims := make([]*v1.Offline, 0)
+	for _, off := range candidates {
+		if utils.FitsResources(demand, available) {
+			break
+		}
+		name := utils.GetOfflineQueueName(off)
+		reqs := utils.OfflineRequests(off)
+		if !keepsShare(usage[name], reqs, deserved[name]) {
+			continue
+		}
+		utils.SubResources(usage[name], reqs)
+		utils.AddResources(available, reqs)
+		victims = append(victims, off)
+	}
+	if !utils.FitsResources(demand, available) {
+		return nil
+	}
+	return victims
+}
+
+// keepsShare reports whether a queue using usage still has its deserved
+// share of every resource reqs releases once they are taken from it.
+func keepsShare(usage, reqs, deserved corev1.ResourceList) bool {
+	for name, quantity := range reqs {
+		if quantity.IsZero() {
+			continue
+		}
+		left := usage[name].DeepCopy()
+		left.Sub(quantity)
+		if left.Cmp(deserved[name]) < 0 {
+			return false
+		}
+	}
+	return true
+}
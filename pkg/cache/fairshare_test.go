@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func inQueue(off *v1.Offline, queue string) *v1.Offline {
+	off.Spec.Queue = queue
+	return off
+}
+
+var _ = Describe("waterfill", func() {
+	It("divides in proportion to the weights", func() {
+		Expect(waterfill(12, []int64{1, 2, 3}, []int64{100, 100, 100})).To(Equal([]int64{2, 4, 6}))
+	})
+
+	It("divides by weight when there is less left than the total weight", func() {
+		Expect(waterfill(3, []int64{1, 3}, []int64{100, 100})).To(Equal([]int64{1, 2}))
+		Expect(waterfill(4000, []int64{1, 3}, []int64{100000, 100000})).To(Equal([]int64{1000, 3000}))
+	})
+
+	It("hands what a claimant doesn't demand to the others", func() {
+		Expect(waterfill(12, []int64{1, 1, 1}, []int64{2, 100, 100})).To(Equal([]int64{2, 5, 5}))
+		Expect(waterfill(12, []int64{1, 1}, []int64{0, 100})).To(Equal([]int64{0, 12}))
+	})
+
+	It("leaves capacity nobody demands idle", func() {
+		Expect(waterfill(12, []int64{1, 2}, []int64{3, 4})).To(Equal([]int64{3, 4}))
+	})
+})
+
+var _ = Describe("FairShare", func() {
+	It("divides a parent's share among its children", func() {
+		c := NewCache()
+		c.SetPolicies(Policy{}, map[string]Policy{
+			"a":  {Weight: 1},
+			"b":  {Weight: 3},
+			"b1": {Weight: 1, Parent: "b"},
+			"b2": {Weight: 1, Parent: "b"},
+		})
+		shares := c.FairShare(cpu("8"), map[string]corev1.ResourceList{
+			"a": cpu("8"), "b1": cpu("8"), "b2": cpu("1"),
+		})
+		milliCPU := func(name string) int64 {
+			quantity := shares[name][corev1.ResourceCPU]
+			return quantity.MilliValue()
+		}
+		Expect(milliCPU("a")).To(BeEquivalentTo(2000))
+		Expect(milliCPU("b1")).To(BeEquivalentTo(5000))
+		Expect(milliCPU("b2")).To(BeEquivalentTo(1000))
+	})
+
+	It("divides while the policies are reloaded", func() {
+		c := NewCache()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				c.SetPolicies(Policy{}, map[string]Policy{"a": {Weight: 1}, "b": {Weight: int32(i%3 + 1)}})
+				c.SetPolicy("c", Policy{Weight: 2})
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			c.FairShare(cpu("8"), map[string]corev1.ResourceList{"a": cpu("8"), "b": cpu("8")})
+		}
+		<-done
+	})
+})
+
+var _ = Describe("Reclaim", func() {
+	deserved := map[string]corev1.ResourceList{"a": cpu("4"), "b": cpu("4")}
+
+	It("preempts the lowest priority Offlines of a borrowing queue", func() {
+		admitted := map[string][]*v1.Offline{
+			"b": {
+				inQueue(withLevel(newOffline("high", "2", 0), 5), "b"),
+				inQueue(newOffline("low", "2", 0), "b"),
+				inQueue(newOffline("lowest", "2", time.Second), "b"),
+				inQueue(withLevel(newOffline("kept", "2", 0), 9), "b"),
+			},
+		}
+		victims := Reclaim("a", cpu("4"), cpu("0"), admitted, deserved)
+		Expect(victims).To(HaveLen(2))
+		Expect(victims[0].Name).To(Equal("lowest"))
+		Expect(victims[1].Name).To(Equal("low"))
+	})
+
+	It("never takes a queue below its deserved share", func() {
+		admitted := map[string][]*v1.Offline{
+			"b": {
+				inQueue(newOffline("first", "2", 0), "b"),
+				inQueue(newOffline("second", "2", 0), "b"),
+				inQueue(newOffline("third", "2", 0), "b"),
+			},
+		}
+		Expect(Reclaim("a", cpu("4"), cpu("0"), admitted, deserved)).To(BeNil())
+		Expect(Reclaim("a", cpu("2"), cpu("0"), admitted, deserved)).To(HaveLen(1))
+	})
+
+	It("skips a victim that would take its queue below its deserved share", func() {
+		admitted := map[string][]*v1.Offline{
+			"b": {
+				inQueue(newOffline("big", "4", time.Second), "b"),
+				inQueue(newOffline("small", "2", 0), "b"),
+			},
+		}
+		victims := Reclaim("a", cpu("2"), cpu("0"), admitted, deserved)
+		Expect(victims).To(HaveLen(1))
+		Expect(victims[0].Name).To(Equal("small"))
+		Expect(Reclaim("a", cpu("4"), cpu("0"), admitted, deserved)).To(BeNil())
+	})
+
+	It("doesn't preempt for a claimant borrowing itself", func() {
+		admitted := map[string][]*v1.Offline{
+			"a": {inQueue(newOffline("own", "4", 0), "a")},
+			"b": {inQueue(newOffline("other", "6", 0), "b")},
+		}
+		Expect(Reclaim("a", cpu("2"), cpu("0"), admitted, deserved)).To(BeNil())
+	})
+})
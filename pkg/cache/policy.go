@@ -0,0 +1,30 @@
+package cache
+
+import corev1 "k8s.io/api/core/v1"
+
+// DefaultMaxAdmitted is the size of the admission window of a queue whose
+// policy doesn't set one: the head gathers its gang before the next
+// offline is admitted.
+const DefaultMaxAdmitted = 1
+
+// Policy holds the per-queue scheduling options.
+type Policy struct {
+	// Backfill admits Offlines that fit the free capacity now and declare a
+	// MaxRuntimeSeconds shorter than the head's estimated wait, while the
+	// head of the queue is blocked.
+	Backfill bool
+	// Aging raises the priority of waiting Offlines, nil disables it.
+	Aging *AgingPolicy
+	// Weight is the queue's part of its parent's capacity under fair share,
+	// DefaultWeight when unset.
+	Weight int32
+	// Parent names the queue whose share this queue divides with its
+	// siblings, the cluster when empty.
+	Parent string
+	// MaxAdmitted is how many Offlines of the queue may gather their gangs
+	// at the same time, DefaultMaxAdmitted when unset.
+	MaxAdmitted int32
+	// MaxAdmittedResources bounds the resources requested by the Offlines
+	// gathering their gangs at the same time, unbounded when nil.
+	MaxAdmittedResources corev1.ResourceList
+}
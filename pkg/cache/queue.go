@@ -3,15 +3,19 @@ package cache
 import (
 	"github.com/YunWang/colocation/api/v1"
 	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"sort"
 	"sync"
 )
 
 type Queue struct {
 	lock         sync.RWMutex
 	schedulingQ  *cache.Heap
-	current		 *v1.Offline
+	admitted     map[string]*v1.Offline
 	name 		 string
+	policy       Policy
+	backfilled   map[string]*v1.Offline
 }
 
 func (q *Queue) Pop() (*v1.Offline,error){
@@ -29,6 +33,14 @@ func(q *Queue) GetName()string{
 	return q.name
 }
 
+// IsEmpty reports whether the queue has nothing waiting, nothing admitted
+// and no backfilled offline still running, so it can be dropped.
+func(q *Queue) IsEmpty()bool{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.Len()==0 && len(q.admitted)==0 && len(q.backfilled)==0
+}
+
 func (q *Queue) AddSchedulingQ(offline *v1.Offline) error{
 	return q.schedulingQ.Add(offline)
 }
@@ -41,39 +53,192 @@ func (q *Queue) Get(key string) (*v1.Offline,bool){
 	return obj.(*v1.Offline),true
 }
 
-func(q *Queue) GetCurrent()*v1.Offline{
-	return q.current
+// Admitted returns the offlines admitted from the queue that are still
+// gathering their gangs, in queue order.
+func(q *Queue) Admitted()[]*v1.Offline{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.sortedAdmitted()
+}
+
+func(q *Queue) sortedAdmitted()[]*v1.Offline{
+	offs:=make([]*v1.Offline,0,len(q.admitted))
+	for _,off:=range q.admitted{
+		offs=append(offs, off)
+	}
+	sort.Slice(offs, func(i, j int) bool {
+		return utils.LessFn(offs[i],offs[j])
+	})
+	return offs
 }
-func(q *Queue) UpdateCurrent()error{
-	if q.Len()==0 {
-		q.current=nil
-		return nil
+
+// Head returns the admitted offline first in queue order, the one backfill
+// and reclaim make room for, or nil when none is admitted.
+func(q *Queue) Head()*v1.Offline{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if offs:=q.sortedAdmitted();len(offs)>0{
+		return offs[0]
+	}
+	return nil
+}
+
+// IsAdmitted reports whether off is in the admission window of the queue.
+func(q *Queue) IsAdmitted(off *v1.Offline)bool{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	_,exist:=q.admitted[key(off)]
+	return exist
+}
+
+// Admit puts off in the admission window whether there is room or not,
+// restoring an offline whose pods were created before the controller
+// restarted.
+func(q *Queue) Admit(off *v1.Offline){
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _,exist,_:=q.schedulingQ.Get(off);exist{
+		_=q.schedulingQ.Delete(off)
+	}
+	q.admitted[key(off)]=off
+}
+
+// Release takes off out of the admission window once its gang has
+// gathered, or it failed, finished or went away.
+func(q *Queue) Release(off *v1.Offline){
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.admitted,key(off))
+}
+
+// AdmitNext pops the head of schedulingQ into the admission window. It
+// returns nil when the queue is empty or the window has no room left for
+// the head.
+func(q *Queue) AdmitNext()(*v1.Offline,error){
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.schedulingQ.ListKeys())==0 {
+		return nil,nil
+	}
+	if !q.hasRoom(q.Peek()) {
+		return nil,nil
 	}
 	obj,err:=q.schedulingQ.Pop()
 	if err!=nil {
-		return err
+		return nil,err
 	}
-	q.current,err=obj.(*v1.Offline)
-	if err!=nil {
-		return err
+	off:=obj.(*v1.Offline)
+	q.admitted[key(off)]=off
+	return off,nil
+}
+
+// CanAdmit reports whether AdmitNext would admit the head of schedulingQ.
+func(q *Queue) CanAdmit()bool{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	head:=q.Peek()
+	return head!=nil && q.hasRoom(head)
+}
+
+// hasRoom reports whether the admission window takes off besides the
+// offlines already in it. The window always takes an offline when it is
+// empty, so one larger than MaxAdmittedResources still gets admitted.
+func(q *Queue) hasRoom(off *v1.Offline)bool{
+	if len(q.admitted)==0 {
+		return true
 	}
-	return nil
+	max:=q.policy.MaxAdmitted
+	if max<=0 {
+		max=DefaultMaxAdmitted
+	}
+	if int32(len(q.admitted))>=max {
+		return false
+	}
+	if q.policy.MaxAdmittedResources==nil {
+		return true
+	}
+	reqs:=utils.OfflineRequests(off)
+	for _,admitted:=range q.admitted{
+		utils.AddResources(reqs,utils.OfflineRequests(admitted))
+	}
+	return utils.FitsResources(reqs,q.policy.MaxAdmittedResources)
 }
 
-func (q *Queue) List() {
+// AdmittedRequests returns the resources requested by the offlines in the
+// admission window.
+func(q *Queue) AdmittedRequests()corev1.ResourceList{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	reqs:=corev1.ResourceList{}
+	for _,off:=range q.admitted{
+		utils.AddResources(reqs,utils.OfflineRequests(off))
+	}
+	return reqs
+}
 
+// List returns the offlines waiting in schedulingQ, in no particular order.
+func (q *Queue) List() []*v1.Offline {
+	objs:=q.schedulingQ.List()
+	offs:=make([]*v1.Offline,0,len(objs))
+	for _,obj:=range objs{
+		offs=append(offs, obj.(*v1.Offline))
+	}
+	return offs
 }
 
-func (q *Queue) Peek() {
+// Peek returns the offline that would be popped next without popping it.
+func (q *Queue) Peek() *v1.Offline {
+	var head *v1.Offline
+	for _,off:=range q.List(){
+		if head==nil || utils.LessFn(off,head){
+			head=off
+		}
+	}
+	return head
+}
 
+// Position returns the place of off in schedulingQ in the order it would be
+// popped, from 1, or 0 when it isn't waiting there.
+func (q *Queue) Position(off *v1.Offline) int32 {
+	if _,exist,_:=q.schedulingQ.Get(off);!exist{
+		return 0
+	}
+	position:=int32(1)
+	for _,other:=range q.List(){
+		if key(other)!=key(off) && utils.LessFn(other,off){
+			position++
+		}
+	}
+	return position
 }
 
 func (q *Queue) Delete(offline *v1.Offline) error {
 	return q.schedulingQ.Delete(offline)
 }
 
+func(q *Queue) GetPolicy()Policy{
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.policy
+}
+
+func(q *Queue) setPolicy(policy Policy){
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.policy=policy
+}
+
 func NewQueue() *Queue {
 	return &Queue{
 		schedulingQ:  cache.NewHeap(utils.KeyFn, utils.LessFn),
+		admitted:     make(map[string]*v1.Offline),
+		backfilled:   make(map[string]*v1.Offline),
 	}
 }
+
+func newQueueWithPolicy(name string, policy Policy) *Queue {
+	q := NewQueue()
+	q.name = name
+	q.policy = policy
+	return q
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"sort"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+)
+
+// Mirror puts off where its status says it is: an Offline waiting to be
+// admitted in schedulingQ, one whose pods were created while its gang
+// gathers in the admission window or among the backfilled ones, and one
+// failed without a reason in the unschedulable queue, which the reconciler
+// forgets again unless its pods show it failed to schedule. Any other
+// Offline is taken out of its queue.
+//
+// A pending Offline counts as admitted once it has a start time: the
+// reconciler sets it in the status update admitting the Offline and clears
+// it on every way back to pending, retry, preemption and suspension, before
+// the Offline is queued again.
+func (c *Cache) Mirror(off *v1.Offline, now time.Time) {
+	queue := c.Get(utils.GetOfflineQueueName(off))
+	if !off.DeletionTimestamp.IsZero() {
+		c.Forget(off)
+		return
+	}
+	switch off.Status.Phase {
+	case "", v1.OfflinePendingPhase, v1.OfflineSchedulingPhase:
+		c.unschedulableQ.Delete(off)
+		if off.Status.StartTime == nil {
+			queue.Release(off)
+			queue.Finish(off)
+			// held out of its queue until its topology can be satisfied
+			if off.Status.Reason == v1.OfflineTopologyUnsatisfiableReason {
+				break
+			}
+			_ = queue.AddSchedulingQ(off.DeepCopy())
+		} else if off.Status.Backfilled {
+			queue.RestoreBackfilled(off.DeepCopy())
+		} else {
+			queue.Finish(off)
+			queue.Admit(off.DeepCopy())
+		}
+	case v1.OfflineFailedPhase:
+		c.remove(queue, off)
+		if off.Status.Reason == "" && !c.unschedulableQ.Has(off) {
+			_ = c.unschedulableQ.Add(off.DeepCopy(), now)
+		}
+	default:
+		c.remove(queue, off)
+		c.unschedulableQ.Delete(off)
+	}
+}
+
+// Forget takes off out of every queue of the cache.
+func (c *Cache) Forget(off *v1.Offline) {
+	c.remove(c.Get(utils.GetOfflineQueueName(off)), off)
+	c.unschedulableQ.Forget(off)
+}
+
+func (c *Cache) remove(queue *Queue, off *v1.Offline) {
+	if _, exist := queue.Get(c.key(off)); exist {
+		_ = queue.Delete(off)
+	}
+	queue.Release(off)
+	queue.Finish(off)
+}
+
+// MirrorAll mirrors offs oldest first, so every replica mirroring the same
+// Offlines ends up with the same queues whatever order it saw them in.
+func (c *Cache) MirrorAll(offs []v1.Offline, now time.Time) {
+	sorted := make([]*v1.Offline, 0, len(offs))
+	for i := range offs {
+		sorted = append(sorted, &offs[i])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return c.key(a) < c.key(b)
+	})
+	for _, off := range sorted {
+		c.Mirror(off, now)
+	}
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func inPhase(off *v1.Offline, phase v1.OfflinePhase) *v1.Offline {
+	off.Status.Phase = phase
+	return off
+}
+
+var _ = Describe("Cache.Mirror", func() {
+	var c *Cache
+	var queue *Queue
+
+	BeforeEach(func() {
+		c = NewCache()
+		queue = c.Get("default")
+	})
+
+	It("queues a pending Offline without a start time", func() {
+		off := inPhase(newOffline("waiting", "1", 0), v1.OfflinePendingPhase)
+		c.Mirror(off, epoch)
+
+		_, queued := queue.Get("default/waiting")
+		Expect(queued).To(BeTrue())
+		Expect(queue.IsAdmitted(off)).To(BeFalse())
+	})
+
+	It("admits a pending Offline with a start time", func() {
+		off := started(inPhase(newOffline("admitted", "1", 0), v1.OfflinePendingPhase), time.Second, 60)
+		c.Mirror(off, epoch)
+
+		_, queued := queue.Get("default/admitted")
+		Expect(queued).To(BeFalse())
+		Expect(queue.IsAdmitted(off)).To(BeTrue())
+	})
+
+	It("queues an Offline again once its start time is reset", func() {
+		off := started(inPhase(newOffline("retried", "1", 0), v1.OfflineSchedulingPhase), time.Second, 60)
+		c.Mirror(off, epoch)
+		Expect(queue.IsAdmitted(off)).To(BeTrue())
+
+		// the way back to pending of a retry, a preemption or a suspension
+		off = inPhase(off.DeepCopy(), v1.OfflinePendingPhase)
+		off.Status.StartTime = nil
+		c.Mirror(off, epoch)
+		_, queued := queue.Get("default/retried")
+		Expect(queued).To(BeTrue())
+		Expect(queue.IsAdmitted(off)).To(BeFalse())
+	})
+
+	It("keeps a backfilled Offline among the backfilled ones", func() {
+		off := started(inPhase(newOffline("backfilled", "1", 0), v1.OfflineSchedulingPhase), time.Second, 60)
+		off.Status.Backfilled = true
+		c.Mirror(off, epoch)
+
+		Expect(queue.IsBackfilled(off)).To(BeTrue())
+		Expect(queue.IsAdmitted(off)).To(BeFalse())
+	})
+
+	It("waits to retry an Offline that failed to schedule", func() {
+		off := inPhase(newOffline("unschedulable", "1", 0), v1.OfflineFailedPhase)
+		c.Mirror(off, epoch)
+		Expect(c.IsExistInUnSchedulableQ(off)).To(BeTrue())
+
+		expired := inPhase(newOffline("expired", "1", 0), v1.OfflineFailedPhase)
+		expired.Status.Reason = v1.OfflineDeadlineExceededReason
+		c.Mirror(expired, epoch)
+		Expect(c.IsExistInUnSchedulableQ(expired)).To(BeFalse())
+	})
+
+	It("takes running and deleted Offlines out of their queue", func() {
+		running := started(inPhase(newOffline("running", "1", 0), v1.OfflineSchedulingPhase), time.Second, 60)
+		c.Mirror(running, epoch)
+		c.Mirror(inPhase(running.DeepCopy(), v1.OfflineRunningPhase), epoch)
+		Expect(queue.IsAdmitted(running)).To(BeFalse())
+
+		deleted := inPhase(newOffline("deleted", "1", 0), v1.OfflinePendingPhase)
+		c.Mirror(deleted, epoch)
+		now := metav1.NewTime(epoch)
+		deleted.DeletionTimestamp = &now
+		c.Mirror(deleted, epoch)
+		_, queued := queue.Get("default/deleted")
+		Expect(queued).To(BeFalse())
+	})
+
+	It("mirrors Offlines alike whatever order they come in", func() {
+		offs := []v1.Offline{
+			*started(inPhase(newOffline("b", "1", time.Second), v1.OfflineSchedulingPhase), time.Second, 60),
+			*inPhase(newOffline("a", "1", 0), v1.OfflinePendingPhase),
+		}
+		c.MirrorAll(offs, epoch)
+		other := NewCache()
+		other.MirrorAll([]v1.Offline{offs[1], offs[0]}, epoch)
+
+		Expect(c.Get("default").List()).To(Equal(other.Get("default").List()))
+		Expect(c.Get("default").Admitted()).To(Equal(other.Get("default").Admitted()))
+	})
+
+	It("keeps what was mirrored before when mirroring more", func() {
+		c.Mirror(started(inPhase(newOffline("admitted", "1", 0), v1.OfflineSchedulingPhase), time.Second, 60), epoch)
+		c.MirrorAll([]v1.Offline{*inPhase(newOffline("waiting", "1", time.Second), v1.OfflinePendingPhase)}, epoch)
+
+		Expect(queue.Admitted()).To(HaveLen(1))
+		Expect(queue.Len()).To(BeEquivalentTo(1))
+	})
+})
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultInitialBackoff is the backoff of an Offline the first time it
+	// becomes unschedulable.
+	DefaultInitialBackoff = 10 * time.Second
+	// DefaultMaxBackoff caps the backoff of an Offline.
+	DefaultMaxBackoff = 5 * time.Minute
+	// DefaultUnschedulableTimeout is how long an Offline stays unschedulable
+	// when no event flushes it.
+	DefaultUnschedulableTimeout = 5 * time.Minute
+	// DefaultMaxAttempts is how many times an Offline may fail to schedule
+	// before it is no longer retried.
+	DefaultMaxAttempts = 5
+)
+
+// Events that may make unschedulable Offlines schedulable.
+const (
+	NodeAdd     = "NodeAdd"
+	PodFreed    = "PodFreed"
+	QuotaChange = "QuotaChange"
+)
+
+type unschedulableEntry struct {
+	name          types.NamespacedName
+	addedAt       time.Time
+	backoffExpiry time.Time
+	// movable is set by a flush event, the entry leaves the queue as soon
+	// as its backoff expires.
+	movable bool
+}
+
+// UnschedulableQueue holds the Offlines that failed to schedule. Like the
+// unschedulable and backoff queues of kube-scheduler, an Offline leaves it
+// once its exponential backoff has expired and either an event may have
+// made it schedulable or it has waited longer than the timeout.
+type UnschedulableQueue struct {
+	lock     sync.Mutex
+	entries  map[string]*unschedulableEntry
+	attempts map[string]int32
+	// retrying holds the Offlines popped for a retry until they are seen
+	// pending again, so the failure being cleaned up doesn't re-add them.
+	retrying map[string]struct{}
+
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration
+	maxAttempts    int32
+}
+
+func NewUnschedulableQueue(initialBackoff, maxBackoff time.Duration) *UnschedulableQueue {
+	return &UnschedulableQueue{
+		entries:        make(map[string]*unschedulableEntry),
+		attempts:       make(map[string]int32),
+		retrying:       make(map[string]struct{}),
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		timeout:        DefaultUnschedulableTimeout,
+		maxAttempts:    DefaultMaxAttempts,
+	}
+}
+
+// Add puts off in the queue and backs it off for twice as long as the
+// previous time it was added. It returns false, leaving off out of the
+// queue, once off has been unschedulable as many times as allowed.
+func (u *UnschedulableQueue) Add(off *v1.Offline, now time.Time) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	key := key(off)
+	if _, exist := u.retrying[key]; exist {
+		return true
+	}
+	if _, exist := u.entries[key]; exist {
+		klog.V(0).Info("Offline has existed, you cann't add again!")
+		return true
+	}
+	if u.maxAttempts > 0 && u.attempts[key] >= u.maxAttempts {
+		return false
+	}
+	u.attempts[key]++
+	u.entries[key] = &unschedulableEntry{
+		name:          types.NamespacedName{Namespace: off.Namespace, Name: off.Name},
+		addedAt:       now,
+		backoffExpiry: now.Add(u.backoff(u.attempts[key])),
+	}
+	return true
+}
+
+func (u *UnschedulableQueue) backoff(attempts int32) time.Duration {
+	backoff := u.initialBackoff
+	for i := int32(1); i < attempts; i++ {
+		backoff *= 2
+		if backoff >= u.maxBackoff {
+			return u.maxBackoff
+		}
+	}
+	return backoff
+}
+
+func (u *UnschedulableQueue) Delete(off *v1.Offline) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	delete(u.entries, key(off))
+}
+
+func (u *UnschedulableQueue) Has(off *v1.Offline) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	_, exist := u.entries[key(off)]
+	return exist
+}
+
+// Attempts returns how many times off has been unschedulable.
+func (u *UnschedulableQueue) Attempts(off *v1.Offline) int32 {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.attempts[key(off)]
+}
+
+// MoveAll lets every Offline leave the queue once its backoff expires.
+func (u *UnschedulableQueue) MoveAll(event string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if len(u.entries) > 0 {
+		klog.V(4).Infof("%v moves %d unschedulable Offlines", event, len(u.entries))
+	}
+	for _, entry := range u.entries {
+		entry.movable = true
+	}
+}
+
+// Pop removes and returns the Offlines that are ready to be retried.
+func (u *UnschedulableQueue) Pop(now time.Time) []types.NamespacedName {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	ready := make([]types.NamespacedName, 0)
+	for key, entry := range u.entries {
+		if now.Before(entry.backoffExpiry) {
+			continue
+		}
+		if !entry.movable && now.Sub(entry.addedAt) < u.timeout {
+			continue
+		}
+		delete(u.entries, key)
+		u.retrying[key] = struct{}{}
+		ready = append(ready, entry.name)
+	}
+	return ready
+}
+
+// IsRetrying reports whether off has been popped and isn't pending yet.
+func (u *UnschedulableQueue) IsRetrying(off *v1.Offline) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	_, exist := u.retrying[key(off)]
+	return exist
+}
+
+// Retried marks a popped Offline as back in its scheduling queue.
+func (u *UnschedulableQueue) Retried(off *v1.Offline) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	delete(u.retrying, key(off))
+}
+
+// Forget resets the backoff of off, once it runs or is deleted.
+func (u *UnschedulableQueue) Forget(off *v1.Offline) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	key := key(off)
+	delete(u.entries, key)
+	delete(u.attempts, key)
+	delete(u.retrying, key)
+}
+
+func (u *UnschedulableQueue) SetBackoff(initialBackoff, maxBackoff time.Duration) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.initialBackoff = initialBackoff
+	u.maxBackoff = maxBackoff
+}
+
+func (u *UnschedulableQueue) SetTimeout(timeout time.Duration) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.timeout = timeout
+}
+
+// SetMaxAttempts sets how many times an Offline may be unschedulable, 0
+// retries it forever.
+func (u *UnschedulableQueue) SetMaxAttempts(maxAttempts int32) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.maxAttempts = maxAttempts
+}
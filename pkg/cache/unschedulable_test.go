@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("UnschedulableQueue", func() {
+	var queue *UnschedulableQueue
+
+	BeforeEach(func() {
+		queue = NewUnschedulableQueue(10*time.Second, time.Minute)
+		queue.SetMaxAttempts(3)
+	})
+
+	It("lets an Offline go once its backoff expired and an event moved it", func() {
+		off := newOffline("off", "1", 0)
+		Expect(queue.Add(off, epoch)).To(BeTrue())
+
+		queue.MoveAll(NodeAdd)
+		Expect(queue.Pop(epoch.Add(5 * time.Second))).To(BeEmpty())
+		Expect(queue.Pop(epoch.Add(10 * time.Second))).To(ConsistOf(types.NamespacedName{Namespace: "default", Name: "off"}))
+		Expect(queue.IsRetrying(off)).To(BeTrue())
+	})
+
+	It("lets an Offline go after the timeout without an event", func() {
+		off := newOffline("off", "1", 0)
+		queue.Add(off, epoch)
+		Expect(queue.Pop(epoch.Add(time.Minute))).To(BeEmpty())
+		Expect(queue.Pop(epoch.Add(DefaultUnschedulableTimeout))).To(HaveLen(1))
+	})
+
+	It("doubles the backoff every attempt up to the max", func() {
+		Expect(queue.backoff(1)).To(Equal(10 * time.Second))
+		Expect(queue.backoff(2)).To(Equal(20 * time.Second))
+		Expect(queue.backoff(4)).To(Equal(time.Minute))
+	})
+
+	It("refuses an Offline unschedulable as many times as allowed", func() {
+		off := newOffline("off", "1", 0)
+		for attempt := 1; attempt <= 3; attempt++ {
+			Expect(queue.Add(off, epoch)).To(BeTrue())
+			// added again while backing off or retrying, it isn't counted
+			Expect(queue.Add(off, epoch)).To(BeTrue())
+			queue.MoveAll(PodFreed)
+			Expect(queue.Pop(epoch.Add(time.Hour))).To(HaveLen(1))
+			Expect(queue.Add(off, epoch)).To(BeTrue())
+			queue.Retried(off)
+		}
+		Expect(queue.Attempts(off)).To(BeEquivalentTo(3))
+		Expect(queue.Add(off, epoch)).To(BeFalse())
+		Expect(queue.Has(off)).To(BeFalse())
+
+		queue.Forget(off)
+		Expect(queue.Add(off, epoch)).To(BeTrue())
+	})
+
+	It("retries forever without a max", func() {
+		queue.SetMaxAttempts(0)
+		off := newOffline("off", "1", 0)
+		for attempt := 0; attempt < 10; attempt++ {
+			Expect(queue.Add(off, epoch)).To(BeTrue())
+			queue.Delete(off)
+		}
+	})
+})
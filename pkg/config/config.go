@@ -0,0 +1,299 @@
+package config
+
+import (
+	"io/ioutil"
+	"sort"
+
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/health"
+	"github.com/YunWang/colocation/pkg/history"
+	"github.com/YunWang/colocation/pkg/reclaim"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultWebhookPort is the port the webhook server listens on.
+const DefaultWebhookPort = 9443
+
+// Default returns the configuration the manager runs with when no file or
+// flag changes it.
+func Default() *Configuration {
+	return &Configuration{
+		TypeMeta:     metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		DefaultQueue: "default",
+		LeaderElection: LeaderElection{
+			ID: "colocation-controller-leader",
+		},
+		Metrics: Metrics{BindAddress: ":8080"},
+		Webhook: Webhook{Port: DefaultWebhookPort},
+		Health: Health{
+			BindAddress:      ":8081",
+			ReconcileTimeout: metav1.Duration{Duration: health.DefaultReconcileTimeout},
+		},
+		Logging: Logging{Level: LogLevelInfo, Format: LogFormatJSON},
+		Scheduling: Scheduling{
+			Aging:           Aging{Step: 1, Max: 10},
+			AdmissionWindow: AdmissionWindow{MaxAdmitted: cache.DefaultMaxAdmitted},
+		},
+		Timeouts: Timeouts{
+			UnschedulableInitialBackoff: metav1.Duration{Duration: cache.DefaultInitialBackoff},
+			UnschedulableMaxBackoff:     metav1.Duration{Duration: cache.DefaultMaxBackoff},
+			Unschedulable:               metav1.Duration{Duration: cache.DefaultUnschedulableTimeout},
+			UnschedulableMaxAttempts:    cache.DefaultMaxAttempts,
+		},
+		History:        History{Limit: history.DefaultLimit},
+		BatchResources: BatchResourcesConfig{SafetyMargin: reclaim.DefaultSafetyMargin},
+	}
+}
+
+// Load reads a configuration file in YAML or JSON, fields it doesn't set
+// keep their defaults. Unknown fields are rejected.
+func Load(path string) (*Configuration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a configuration.
+func Parse(data []byte) (*Configuration, error) {
+	cfg := Default()
+	cfg.TypeMeta = metav1.TypeMeta{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Enabled tells whether the named feature gate is on.
+func (c *Configuration) Enabled(gate string) bool {
+	return c.FeatureGates[gate]
+}
+
+// Validate returns every invalid field of the configuration at once.
+func (c *Configuration) Validate() error {
+	var errs field.ErrorList
+	if c.APIVersion != APIVersion {
+		errs = append(errs, field.Invalid(field.NewPath("apiVersion"), c.APIVersion, "must be "+APIVersion))
+	}
+	if c.Kind != Kind {
+		errs = append(errs, field.Invalid(field.NewPath("kind"), c.Kind, "must be "+Kind))
+	}
+	for i, ns := range c.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(field.NewPath("namespaces").Index(i), ns, msg))
+		}
+	}
+	if c.DefaultQueue == "" {
+		errs = append(errs, field.Required(field.NewPath("defaultQueue"), ""))
+	}
+	if c.LeaderElection.Enabled && c.LeaderElection.ID == "" {
+		errs = append(errs, field.Required(field.NewPath("leaderElection", "id"), "required when leader election is enabled"))
+	}
+	if c.Webhook.Port <= 0 || c.Webhook.Port > 65535 {
+		errs = append(errs, field.Invalid(field.NewPath("webhook", "port"), c.Webhook.Port, "must be between 1 and 65535"))
+	}
+	if c.Health.BindAddress == "" {
+		errs = append(errs, field.Required(field.NewPath("health", "bindAddress"), ""))
+	}
+	if c.Health.ReconcileTimeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("health", "reconcileTimeout"), c.Health.ReconcileTimeout.Duration.String(), "must be positive"))
+	}
+	errs = append(errs, c.Logging.validate(field.NewPath("logging"))...)
+	errs = append(errs, c.Scheduling.Aging.validate(field.NewPath("scheduling", "aging"))...)
+	errs = append(errs, c.Scheduling.AdmissionWindow.validate(field.NewPath("scheduling", "admissionWindow"))...)
+	for _, name := range c.queueNames() {
+		errs = append(errs, c.Queues[name].validate(name, c.Queues, field.NewPath("queues").Key(name))...)
+	}
+	errs = append(errs, c.Timeouts.validate(field.NewPath("timeouts"))...)
+	if c.History.Limit <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("history", "limit"), c.History.Limit, "must be positive"))
+	}
+	if margin := c.BatchResources.SafetyMargin; margin < 0 || margin >= 1 {
+		errs = append(errs, field.Invalid(field.NewPath("batchResources", "safetyMargin"), margin, "must be at least 0 and below 1"))
+	}
+	for _, gate := range c.gateNames() {
+		if !knownGate(gate) {
+			errs = append(errs, field.NotSupported(field.NewPath("featureGates").Key(gate), gate, KnownFeatureGates))
+		}
+	}
+	return errs.ToAggregate()
+}
+
+func (l Logging) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch l.Level {
+	case LogLevelDebug, LogLevelInfo, LogLevelError:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("level"), l.Level, []string{LogLevelDebug, LogLevelInfo, LogLevelError}))
+	}
+	switch l.Format {
+	case LogFormatConsole, LogFormatJSON:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("format"), l.Format, []string{LogFormatConsole, LogFormatJSON}))
+	}
+	return errs
+}
+
+func (a Aging) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if a.Interval.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("interval"), a.Interval.Duration.String(), "must not be negative"))
+	}
+	if a.Interval.Duration > 0 && a.Step <= 0 {
+		errs = append(errs, field.Invalid(path.Child("step"), a.Step, "must be positive when aging is enabled"))
+	}
+	if a.Max < 0 {
+		errs = append(errs, field.Invalid(path.Child("max"), a.Max, "must not be negative"))
+	}
+	return errs
+}
+
+func (w AdmissionWindow) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if w.MaxAdmitted <= 0 {
+		errs = append(errs, field.Invalid(path.Child("maxAdmitted"), w.MaxAdmitted, "must be positive"))
+	}
+	for name, quantity := range w.MaxResources {
+		if quantity.Sign() <= 0 {
+			errs = append(errs, field.Invalid(path.Child("maxResources").Key(string(name)), quantity.String(), "must be positive"))
+		}
+	}
+	return errs
+}
+
+func (q QueueConfig) validate(name string, queues map[string]QueueConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if q.Weight < 0 {
+		errs = append(errs, field.Invalid(path.Child("weight"), q.Weight, "must not be negative"))
+	}
+	if q.Parent == name {
+		errs = append(errs, field.Invalid(path.Child("parent"), q.Parent, "a queue can't be its own parent"))
+	} else if q.Parent != "" {
+		// Walk up the parents to catch cycles, which fair share can't divide.
+		seen := map[string]bool{name: true}
+		for parent := q.Parent; parent != ""; parent = queues[parent].Parent {
+			if seen[parent] {
+				errs = append(errs, field.Invalid(path.Child("parent"), q.Parent, "parents form a cycle"))
+				break
+			}
+			seen[parent] = true
+		}
+	}
+	if q.Aging != nil {
+		errs = append(errs, q.Aging.validate(path.Child("aging"))...)
+	}
+	if q.AdmissionWindow != nil {
+		errs = append(errs, q.AdmissionWindow.validate(path.Child("admissionWindow"))...)
+	}
+	return errs
+}
+
+func (t Timeouts) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if t.UnschedulableInitialBackoff.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("unschedulableInitialBackoff"), t.UnschedulableInitialBackoff.Duration.String(), "must be positive"))
+	}
+	if t.UnschedulableMaxBackoff.Duration < t.UnschedulableInitialBackoff.Duration {
+		errs = append(errs, field.Invalid(path.Child("unschedulableMaxBackoff"), t.UnschedulableMaxBackoff.Duration.String(), "must not be below unschedulableInitialBackoff"))
+	}
+	if t.Unschedulable.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("unschedulable"), t.Unschedulable.Duration.String(), "must be positive"))
+	}
+	if t.UnschedulableMaxAttempts < 0 {
+		errs = append(errs, field.Invalid(path.Child("unschedulableMaxAttempts"), t.UnschedulableMaxAttempts, "must not be negative"))
+	}
+	return errs
+}
+
+func knownGate(gate string) bool {
+	for _, known := range KnownFeatureGates {
+		if gate == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Configuration) queueNames() []string {
+	names := make([]string, 0, len(c.Queues))
+	for name := range c.Queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Configuration) gateNames() []string {
+	names := make([]string, 0, len(c.FeatureGates))
+	for name := range c.FeatureGates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Policies returns the policy of queues without one of their own and the
+// policy of each configured queue.
+func (c *Configuration) Policies() (cache.Policy, map[string]cache.Policy) {
+	base := cache.Policy{Backfill: c.Scheduling.Backfill}
+	setAging(&base, c.Scheduling.Aging)
+	setAdmissionWindow(&base, c.Scheduling.AdmissionWindow)
+	policies := make(map[string]cache.Policy, len(c.Queues))
+	for name, q := range c.Queues {
+		policy := base
+		policy.Weight = q.Weight
+		policy.Parent = q.Parent
+		if q.Backfill != nil {
+			policy.Backfill = *q.Backfill
+		}
+		if q.Aging != nil {
+			setAging(&policy, *q.Aging)
+		}
+		if q.AdmissionWindow != nil {
+			setAdmissionWindow(&policy, *q.AdmissionWindow)
+		}
+		policies[name] = policy
+	}
+	return base, policies
+}
+
+func setAging(policy *cache.Policy, aging Aging) {
+	policy.Aging = nil
+	if aging.Interval.Duration > 0 {
+		policy.Aging = &cache.AgingPolicy{Interval: aging.Interval.Duration, Step: aging.Step, Max: aging.Max}
+	}
+}
+
+func setAdmissionWindow(policy *cache.Policy, window AdmissionWindow) {
+	policy.MaxAdmitted = window.MaxAdmitted
+	policy.MaxAdmittedResources = window.MaxResources.DeepCopy()
+}
+
+// Apply sets the policies, fair share and timeouts of the configuration on
+// c, replacing the ones it had.
+func (c *Configuration) Apply(offlineCache *cache.Cache) {
+	base, policies := c.Policies()
+	offlineCache.SetPolicies(base, policies)
+	offlineCache.SetFairShare(c.Scheduling.FairShare)
+	unschedulableQ := offlineCache.GetUnSchedulableQ()
+	unschedulableQ.SetBackoff(c.Timeouts.UnschedulableInitialBackoff.Duration, c.Timeouts.UnschedulableMaxBackoff.Duration)
+	unschedulableQ.SetTimeout(c.Timeouts.Unschedulable.Duration)
+	unschedulableQ.SetMaxAttempts(c.Timeouts.UnschedulableMaxAttempts)
+}
+
+// static returns a copy of c without the fields the manager reloads,
+// two configurations whose copies differ need a restart.
+func (c *Configuration) static() *Configuration {
+	out := *c
+	out.Scheduling = Scheduling{}
+	out.Queues = nil
+	out.Timeouts = Timeouts{}
+	return &out
+}
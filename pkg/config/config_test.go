@@ -0,0 +1,145 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/go-logr/logr/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const header = "apiVersion: " + APIVersion + "\nkind: " + Kind + "\n"
+
+var _ = Describe("Configuration", func() {
+	It("keeps the defaults of fields a file doesn't set", func() {
+		cfg, err := Parse([]byte(header + "namespaces: [batch]\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Namespaces).To(Equal([]string{"batch"}))
+		Expect(cfg.Webhook.Port).To(Equal(DefaultWebhookPort))
+		Expect(cfg.DefaultQueue).To(Equal("default"))
+		Expect(cfg.Timeouts.Unschedulable.Duration).To(Equal(cache.DefaultUnschedulableTimeout))
+	})
+
+	It("requires the apiVersion and kind", func() {
+		_, err := Parse([]byte("namespaces: [batch]\n"))
+		Expect(err).To(MatchError(ContainSubstring("apiVersion")))
+		Expect(err).To(MatchError(ContainSubstring("kind")))
+	})
+
+	It("rejects unknown fields", func() {
+		_, err := Parse([]byte(header + "webhook:\n  prot: 9443\n"))
+		Expect(err).To(MatchError(ContainSubstring("prot")))
+	})
+
+	It("watches a set of namespaces", func() {
+		cfg, err := Parse([]byte(header + "namespaces: [batch, research]\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Namespaces).To(Equal([]string{"batch", "research"}))
+	})
+
+	It("logs at info level in json by default", func() {
+		cfg, err := Parse([]byte(header))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Logging).To(Equal(Logging{Level: LogLevelInfo, Format: LogFormatJSON}))
+	})
+
+	It("reports every invalid field with its path", func() {
+		_, err := Parse([]byte(header + `
+webhook:
+  port: 70000
+logging:
+  level: verbose
+scheduling:
+  admissionWindow:
+    maxAdmitted: 0
+queues:
+  a: {parent: b}
+  b: {parent: a}
+timeouts:
+  unschedulableInitialBackoff: 1m
+  unschedulableMaxBackoff: 10s
+  unschedulableMaxAttempts: -1
+featureGates:
+  Unknown: true
+`))
+		Expect(err).To(HaveOccurred())
+		for _, path := range []string{"webhook.port", "logging.level", "scheduling.admissionWindow.maxAdmitted",
+			"queues[a].parent", "queues[b].parent", "timeouts.unschedulableMaxBackoff",
+			"timeouts.unschedulableMaxAttempts", "featureGates[Unknown]"} {
+			Expect(err.Error()).To(ContainSubstring(path))
+		}
+	})
+
+	It("overrides the scheduling options per queue", func() {
+		cfg, err := Parse([]byte(header + `
+scheduling:
+  backfill: true
+  aging: {interval: 1m, step: 2, max: 6}
+  admissionWindow: {maxAdmitted: 2}
+queues:
+  research:
+    weight: 3
+    backfill: false
+    admissionWindow:
+      maxAdmitted: 4
+      maxResources: {cpu: "8"}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		base, policies := cfg.Policies()
+		Expect(base.Backfill).To(BeTrue())
+		Expect(base.Aging).To(Equal(&cache.AgingPolicy{Interval: time.Minute, Step: 2, Max: 6}))
+		Expect(base.MaxAdmitted).To(Equal(int32(2)))
+
+		research := policies["research"]
+		Expect(research.Weight).To(Equal(int32(3)))
+		Expect(research.Backfill).To(BeFalse())
+		Expect(research.Aging).To(Equal(base.Aging))
+		Expect(research.MaxAdmitted).To(Equal(int32(4)))
+		Expect(research.MaxAdmittedResources).To(Equal(corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")}))
+	})
+})
+
+var _ = Describe("Reloader", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "config.yaml")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	write := func(content string) {
+		Expect(ioutil.WriteFile(path, []byte(header+content), 0644)).To(Succeed())
+	}
+
+	It("applies changed policies and keeps the running ones on an invalid file", func() {
+		write("scheduling:\n  backfill: false\n")
+		cfg, err := Load(path)
+		Expect(err).NotTo(HaveOccurred())
+		c := cache.NewCache()
+		cfg.Apply(c)
+		reloader := NewReloader(path, cfg, c, testing.NullLogger{})
+		Expect(reloader.Reload()).To(BeFalse())
+
+		write("scheduling:\n  backfill: true\n  fairShare: true\nqueues:\n  research: {weight: 2}\n")
+		Expect(reloader.Reload()).To(BeTrue())
+		Expect(c.Get("default").GetPolicy().Backfill).To(BeTrue())
+		Expect(c.Get("research").GetPolicy().Weight).To(Equal(int32(2)))
+		Expect(c.FairShareEnabled()).To(BeTrue())
+
+		write("scheduling:\n  backfill: false\n  admissionWindow: {maxAdmitted: -1}\n")
+		Expect(reloader.Reload()).To(BeFalse())
+		Expect(c.Get("default").GetPolicy().Backfill).To(BeTrue())
+	})
+})
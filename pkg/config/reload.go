@@ -0,0 +1,86 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/go-logr/logr"
+)
+
+// DefaultReloadPeriod is how often a Reloader reads the configuration file.
+const DefaultReloadPeriod = 10 * time.Second
+
+// Reloader reads the configuration file every Period and applies the
+// scheduling options, queues and timeouts it sets to the Cache. A file that
+// doesn't parse or validate is logged and the running configuration kept;
+// changes to the other fields are logged as needing a restart.
+//
+// The file is polled rather than watched: a ConfigMap volume replaces it by
+// swapping a symlink, which file watches lose track of.
+type Reloader struct {
+	Path   string
+	Period time.Duration
+	Cache  *cache.Cache
+	Log    logr.Logger
+
+	current *Configuration
+	data    []byte
+}
+
+// NewReloader returns a Reloader of the file at path, which the manager
+// started with as current.
+func NewReloader(path string, current *Configuration, c *cache.Cache, log logr.Logger) *Reloader {
+	data, _ := ioutil.ReadFile(path)
+	return &Reloader{Path: path, Period: DefaultReloadPeriod, Cache: c, Log: log, current: current, data: data}
+}
+
+// NeedLeaderElection is false: standby replicas keep their cache on the
+// same policies as the leader's.
+func (r *Reloader) NeedLeaderElection() bool {
+	return false
+}
+
+// Start reloads the file until stop is closed. It is a manager.Runnable.
+func (r *Reloader) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(r.Period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Reload()
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// Reload applies the file if it changed since it was last read, and
+// reports whether it did.
+func (r *Reloader) Reload() bool {
+	data, err := ioutil.ReadFile(r.Path)
+	if err != nil {
+		r.Log.Error(err, "unable to read configuration", "path", r.Path)
+		return false
+	}
+	if bytes.Equal(data, r.data) {
+		return false
+	}
+	r.data = data
+	next, err := Parse(data)
+	if err != nil {
+		r.Log.Error(err, "invalid configuration, keeping the running one", "path", r.Path)
+		return false
+	}
+	if !reflect.DeepEqual(next.static(), r.current.static()) {
+		r.Log.Info("configuration changed beyond scheduling, queues and timeouts, restart the manager to apply it", "path", r.Path)
+	}
+	next.Apply(r.Cache)
+	r.current.Scheduling = next.Scheduling
+	r.current.Queues = next.Queues
+	r.current.Timeouts = next.Timeouts
+	r.Log.Info("reloaded configuration", "path", r.Path)
+	return true
+}
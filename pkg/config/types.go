@@ -0,0 +1,169 @@
+// Package config holds the versioned configuration file of the controller
+// manager.
+package config
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// APIVersion is the apiVersion a configuration file must declare.
+	APIVersion = "config.colocation.cmyun.io/v1alpha1"
+	// Kind is the kind a configuration file must declare.
+	Kind = "ControllerManagerConfiguration"
+)
+
+// Feature gates of the manager.
+const (
+	// HPATrading reserves capacity for the replicas HorizontalPodAutoscalers
+	// are about to add and shrinks elastic Offlines ahead of them.
+	HPATrading = "HPATrading"
+	// BatchResources advertises reclaimable node capacity as batch extended
+	// resources and rewrites Offline tasks to request them.
+	BatchResources = "BatchResources"
+	// ConversionWebhook serves the v1beta2 Offline API by converting it to
+	// and from v1, which needs the webhook server and its certificate. The
+	// CRD serves v1beta2 only with the webhook patch of config/crd enabled.
+	ConversionWebhook = "ConversionWebhook"
+)
+
+// KnownFeatureGates lists the gates a configuration may set.
+var KnownFeatureGates = []string{HPATrading, BatchResources, ConversionWebhook}
+
+// Configuration is the configuration of the controller manager. Scheduling,
+// Queues and Timeouts are reloaded while the manager runs, the other fields
+// are read once at startup.
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Namespaces the manager watches, every namespace when empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// DefaultQueue is the queue of Offlines that don't name one.
+	DefaultQueue string `json:"defaultQueue,omitempty"`
+
+	LeaderElection LeaderElection `json:"leaderElection"`
+	Metrics        Metrics        `json:"metrics"`
+	Webhook        Webhook        `json:"webhook"`
+	Health         Health         `json:"health"`
+	Logging        Logging        `json:"logging"`
+
+	Scheduling Scheduling `json:"scheduling"`
+	// Queues overrides the scheduling options of the named queues.
+	Queues   map[string]QueueConfig `json:"queues,omitempty"`
+	Timeouts Timeouts               `json:"timeouts"`
+
+	History        History              `json:"history"`
+	BatchResources BatchResourcesConfig `json:"batchResources"`
+
+	// FeatureGates enables or disables the optional controllers by name.
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+type LeaderElection struct {
+	Enabled bool `json:"enabled"`
+	// ID is the name of the configmap holding the lock.
+	ID string `json:"id,omitempty"`
+	// Namespace of the lock, the namespace the manager runs in when empty.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type Metrics struct {
+	// BindAddress the metric endpoint binds to, "0" disables it.
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+type Webhook struct {
+	Port int `json:"port,omitempty"`
+	// CertDir holds tls.crt and tls.key of the webhook server.
+	CertDir string `json:"certDir,omitempty"`
+}
+
+type Health struct {
+	// BindAddress the liveness and readiness probes are served on.
+	BindAddress string `json:"bindAddress,omitempty"`
+	// ReconcileTimeout is how long a reconcile may run before the liveness
+	// probe fails.
+	ReconcileTimeout metav1.Duration `json:"reconcileTimeout"`
+}
+
+// Log levels and formats.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelError = "error"
+
+	LogFormatConsole = "console"
+	LogFormatJSON    = "json"
+)
+
+type Logging struct {
+	// Level is one of debug, info and error, info by default.
+	Level string `json:"level,omitempty"`
+	// Format is one of console and json, json by default.
+	Format string `json:"format,omitempty"`
+}
+
+// Scheduling holds the options of every queue without one of its own.
+type Scheduling struct {
+	Backfill bool `json:"backfill"`
+	// Aging raises the priority of waiting Offlines, a zero interval
+	// disables it.
+	Aging Aging `json:"aging"`
+	// FairShare preempts Offlines of queues borrowing beyond their share.
+	FairShare       bool            `json:"fairShare"`
+	AdmissionWindow AdmissionWindow `json:"admissionWindow"`
+}
+
+type Aging struct {
+	Interval metav1.Duration `json:"interval"`
+	Step     int32           `json:"step,omitempty"`
+	Max      int32           `json:"max,omitempty"`
+}
+
+type AdmissionWindow struct {
+	// MaxAdmitted is how many Offlines of a queue may gather their gangs at
+	// the same time.
+	MaxAdmitted int32 `json:"maxAdmitted,omitempty"`
+	// MaxResources bounds the resources requested by the Offlines gathering
+	// their gangs at the same time, unbounded when empty.
+	MaxResources corev1.ResourceList `json:"maxResources,omitempty"`
+}
+
+// QueueConfig holds the options of one queue, unset ones are taken from
+// Scheduling.
+type QueueConfig struct {
+	Weight          int32            `json:"weight,omitempty"`
+	Parent          string           `json:"parent,omitempty"`
+	Backfill        *bool            `json:"backfill,omitempty"`
+	Aging           *Aging           `json:"aging,omitempty"`
+	AdmissionWindow *AdmissionWindow `json:"admissionWindow,omitempty"`
+}
+
+type Timeouts struct {
+	// UnschedulableInitialBackoff is how long an Offline waits before it is
+	// retried the first time it fails to schedule.
+	UnschedulableInitialBackoff metav1.Duration `json:"unschedulableInitialBackoff"`
+	// UnschedulableMaxBackoff caps the wait of an Offline failing again.
+	UnschedulableMaxBackoff metav1.Duration `json:"unschedulableMaxBackoff"`
+	// Unschedulable is how long an Offline stays unschedulable when no
+	// event moves it.
+	Unschedulable metav1.Duration `json:"unschedulable"`
+	// UnschedulableMaxAttempts is how many times an Offline may fail to
+	// schedule before it is failed for good, 0 retries it forever.
+	UnschedulableMaxAttempts int32 `json:"unschedulableMaxAttempts"`
+}
+
+type History struct {
+	// Sink is a file or an http(s) URL transitions are streamed to, empty
+	// disables the stream.
+	Sink string `json:"sink,omitempty"`
+	// Limit is how many transitions an Offline keeps in its status.
+	Limit int `json:"limit,omitempty"`
+}
+
+type BatchResourcesConfig struct {
+	// SafetyMargin is the part of a node's allocatable cpu and memory never
+	// advertised as batch resources.
+	SafetyMargin float64 `json:"safetyMargin"`
+}
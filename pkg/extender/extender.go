@@ -0,0 +1,473 @@
+// Package extender is a kube-scheduler extender placing offline pods on
+// the nodes that have reclaimable capacity for them, close to the rest of
+// their gang, and binding them only once the whole gang can be placed.
+package extender
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/reclaim"
+	"github.com/YunWang/colocation/pkg/topology"
+	"github.com/YunWang/colocation/pkg/utils"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReservationTTL is how long the node a pod of a gang was tentatively
+// placed on is held for it while the rest of its gang is placed. It lets
+// two gangs holding part of what both need give it up.
+const ReservationTTL = time.Minute
+
+// reservation is the node a pod of an Offline was tentatively placed on and
+// what it requests there.
+type reservation struct {
+	node     string
+	requests corev1.ResourceList
+	expires  time.Time
+}
+
+// Extender answers the filter, prioritize and bind verbs for offline pods.
+// Other pods are passed through untouched.
+type Extender struct {
+	Client client.Client
+	// Cache mirrors the Offlines still waiting in their queues, their
+	// pods aren't placed before they are admitted.
+	Cache        *cache.Cache
+	SafetyMargin float64
+	// TopologyKey is the node label gangs are kept together by.
+	TopologyKey string
+	Log         logr.Logger
+
+	lock sync.Mutex
+	// reservations holds the nodes the unbound pods of each Offline were
+	// tentatively placed on by Bind, so the pods of other Offlines aren't
+	// placed in the resources the gang is gathering.
+	reservations map[types.NamespacedName]map[types.UID]reservation
+	now          func() time.Time
+}
+
+func NewExtender(c client.Client, margin float64, topologyKey string, log logr.Logger) *Extender {
+	return &Extender{
+		Client:       c,
+		Cache:        cache.NewCache(),
+		SafetyMargin: margin,
+		TopologyKey:  topologyKey,
+		Log:          log,
+		reservations: make(map[types.NamespacedName]map[types.UID]reservation),
+		now:          time.Now,
+	}
+}
+
+// offlineOf returns the name of the Offline owning pod, false for pods of
+// online workloads.
+func offlineOf(pod *corev1.Pod) (types.NamespacedName, bool) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.APIVersion != v1.GroupVersion.String() || owner.Kind != "Offline" {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}, true
+}
+
+// batchRequests returns what pod requests in batch resources, counting
+// the cpu and memory of pods the webhook didn't rewrite as batch ones.
+func batchRequests(pod *corev1.Pod) corev1.ResourceList {
+	reqs := corev1.ResourceList{}
+	for name, quantity := range utils.PodRequests(&pod.Spec) {
+		switch name {
+		case corev1.ResourceCPU:
+			name = v1.BatchCPUResource
+			quantity = *resource.NewQuantity(quantity.MilliValue(), resource.DecimalSI)
+		case corev1.ResourceMemory:
+			name = v1.BatchMemoryResource
+		case v1.BatchCPUResource, v1.BatchMemoryResource:
+		default:
+			continue
+		}
+		utils.AddResources(reqs, corev1.ResourceList{name: quantity})
+	}
+	return reqs
+}
+
+func isActive(pod *corev1.Pod) bool {
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// freeBatch returns the batch resources each node has left for offline
+// pods: what it can reclaim from online pods minus what the offline pods
+// placed on it request and what is reserved there for pods other than pod.
+func (e *Extender) freeBatch(ctx context.Context, nodes []corev1.Node, pod *corev1.Pod) (map[string]corev1.ResourceList, error) {
+	podList := &corev1.PodList{}
+	if err := e.Client.List(ctx, podList); err != nil {
+		return nil, err
+	}
+	online := make(map[string][]corev1.Pod)
+	offline := make(map[string]corev1.ResourceList)
+	unbound := make(map[types.UID]bool)
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName == "" && isActive(pod) && pod.DeletionTimestamp.IsZero() {
+			unbound[pod.UID] = true
+		}
+		if pod.Spec.NodeName == "" || !isActive(pod) {
+			continue
+		}
+		if _, ok := offlineOf(pod); ok {
+			if offline[pod.Spec.NodeName] == nil {
+				offline[pod.Spec.NodeName] = corev1.ResourceList{}
+			}
+			utils.AddResources(offline[pod.Spec.NodeName], batchRequests(pod))
+			continue
+		}
+		online[pod.Spec.NodeName] = append(online[pod.Spec.NodeName], *pod)
+	}
+	usage, err := reclaim.MeasuredUsage(ctx, e.Client)
+	if err != nil {
+		e.Log.Error(err, "unable to read pod metrics, online pods are counted by their requests")
+	}
+	reserved := e.reserved(unbound, pod.UID)
+	free := make(map[string]corev1.ResourceList, len(nodes))
+	for i := range nodes {
+		name := nodes[i].Name
+		free[name] = reclaim.Reclaimable(&nodes[i], online[name], usage, e.SafetyMargin)
+		utils.SubResources(free[name], offline[name])
+		if reqs, ok := reserved[name]; ok {
+			utils.SubResources(free[name], reqs)
+		}
+	}
+	return free, nil
+}
+
+// reserved sums the reservations on each node, leaving out the one of the
+// pod except. Reservations of pods that are bound, gone or expired are
+// dropped.
+func (e *Extender) reserved(unbound map[types.UID]bool, except types.UID) map[string]corev1.ResourceList {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := e.now()
+	reserved := make(map[string]corev1.ResourceList)
+	for gang, pods := range e.reservations {
+		for uid, r := range pods {
+			if !unbound[uid] || now.After(r.expires) {
+				delete(pods, uid)
+				continue
+			}
+			if uid == except {
+				continue
+			}
+			if reserved[r.node] == nil {
+				reserved[r.node] = corev1.ResourceList{}
+			}
+			utils.AddResources(reserved[r.node], r.requests)
+		}
+		if len(pods) == 0 {
+			delete(e.reservations, gang)
+		}
+	}
+	return reserved
+}
+
+// nodes returns the candidate nodes of args, getting them from the client
+// when the scheduler only sent their names.
+func (e *Extender) nodes(ctx context.Context, args *Args) ([]corev1.Node, error) {
+	if args.Nodes != nil {
+		return args.Nodes.Items, nil
+	}
+	nodes := make([]corev1.Node, 0)
+	if args.NodeNames == nil {
+		return nodes, nil
+	}
+	for _, name := range *args.NodeNames {
+		node := corev1.Node{}
+		if err := e.Client.Get(ctx, types.NamespacedName{Name: name}, &node); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// isQueued tells whether the Offline is still waiting in its queue.
+func (e *Extender) isQueued(name types.NamespacedName) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, queue := range e.Cache.List() {
+		if _, exist := e.Cache.Get(queue).Get(name.String()); exist {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter keeps the nodes with enough reclaimable capacity left for an
+// offline pod, once the reservations of the other pods are taken out, and,
+// when its Offline requires a topology, in a domain the policy allows given
+// where the rest of the gang is.
+func (e *Extender) Filter(ctx context.Context, args *Args) *FilterResult {
+	nodes, err := e.nodes(ctx, args)
+	if err != nil {
+		return &FilterResult{Error: err.Error()}
+	}
+	name, ok := offlineOf(args.Pod)
+	if !ok {
+		return filterResult(args, nodes, FailedNodesMap{})
+	}
+	failed := FailedNodesMap{}
+	if e.isQueued(name) {
+		for _, node := range nodes {
+			failed[node.Name] = fmt.Sprintf("offline %s isn't admitted yet", name)
+		}
+		return filterResult(args, nil, failed)
+	}
+
+	free, err := e.freeBatch(ctx, nodes, args.Pod)
+	if err != nil {
+		return &FilterResult{Error: err.Error()}
+	}
+	policy, placed, err := e.gangTopology(ctx, name, args.Pod)
+	if err != nil {
+		return &FilterResult{Error: err.Error()}
+	}
+	reqs := batchRequests(args.Pod)
+	fit := make([]corev1.Node, 0, len(nodes))
+	for i, node := range nodes {
+		if !utils.FitsResources(reqs, free[node.Name]) {
+			failed[node.Name] = "not enough reclaimable resources for offline pods"
+			continue
+		}
+		if policy != nil && policy.Required {
+			domain, ok := topology.Domain(policy, &nodes[i])
+			if !ok || !topology.Allowed(policy, domain, placed) {
+				failed[node.Name] = fmt.Sprintf("offline %s requires topology %s by %s", name, policy.Mode, topology.Key(policy))
+				continue
+			}
+		}
+		fit = append(fit, node)
+	}
+	return filterResult(args, fit, failed)
+}
+
+func filterResult(args *Args, nodes []corev1.Node, failed FailedNodesMap) *FilterResult {
+	result := &FilterResult{FailedNodes: failed}
+	if args.NodeNames != nil {
+		names := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+		result.NodeNames = &names
+		return result
+	}
+	result.Nodes = &corev1.NodeList{Items: nodes}
+	return result
+}
+
+// reserve holds node for pod of the Offline name until ReservationTTL
+// passes, it is released or the pod is bound.
+func (e *Extender) reserve(name types.NamespacedName, pod *corev1.Pod, node string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.reservations[name] == nil {
+		e.reservations[name] = make(map[types.UID]reservation)
+	}
+	e.reservations[name][pod.UID] = reservation{node: node, requests: batchRequests(pod), expires: e.now().Add(ReservationTTL)}
+}
+
+// release drops the reservation of a pod of the Offline name.
+func (e *Extender) release(name types.NamespacedName, uid types.UID) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.reservations[name], uid)
+	if len(e.reservations[name]) == 0 {
+		delete(e.reservations, name)
+	}
+}
+
+// gangPods returns the active pods of an Offline.
+func (e *Extender) gangPods(ctx context.Context, name types.NamespacedName) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := e.Client.List(ctx, podList, client.InNamespace(name.Namespace)); err != nil {
+		return nil, err
+	}
+	pods := make([]corev1.Pod, 0)
+	for i := range podList.Items {
+		if owner, ok := offlineOf(&podList.Items[i]); ok && owner == name && isActive(&podList.Items[i]) {
+			pods = append(pods, podList.Items[i])
+		}
+	}
+	return pods, nil
+}
+
+// Prioritize prefers the nodes closest to the pods of the gang already
+// placed: the same node scores best, then the same topology domain. An
+// Offline with a topology policy is scored by its own domains instead,
+// farthest from the rest of the gang first for Spread.
+func (e *Extender) Prioritize(ctx context.Context, args *Args) (HostPriorityList, error) {
+	nodes, err := e.nodes(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	priorities := make(HostPriorityList, 0, len(nodes))
+	name, ok := offlineOf(args.Pod)
+	if !ok {
+		for _, node := range nodes {
+			priorities = append(priorities, HostPriority{Host: node.Name})
+		}
+		return priorities, nil
+	}
+	policy, inPolicyDomain, err := e.gangTopology(ctx, name, args.Pod)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		placed := 0
+		for _, n := range inPolicyDomain {
+			placed += n
+		}
+		for i, node := range nodes {
+			var score int64
+			if placed > 0 {
+				domain, _ := topology.Domain(policy, &nodes[i])
+				near := inPolicyDomain[domain]
+				if policy.Mode == v1.TopologySpread {
+					near = placed - near
+				}
+				score = int64(MaxPriority * near / placed)
+			}
+			priorities = append(priorities, HostPriority{Host: node.Name, Score: score})
+		}
+		return priorities, nil
+	}
+
+	pods, err := e.gangPods(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	onNode := make(map[string]int)
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			onNode[pod.Spec.NodeName]++
+		}
+	}
+	inDomain := make(map[string]int)
+	for nodeName, n := range onNode {
+		node := &corev1.Node{}
+		if err := e.Client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+			continue
+		}
+		if domain, ok := node.Labels[e.TopologyKey]; ok {
+			inDomain[domain] += n
+		}
+	}
+	placed := 0
+	for _, n := range onNode {
+		placed += n
+	}
+	for _, node := range nodes {
+		var score int64
+		if placed > 0 {
+			//half the score for sharing the domain, half for sharing the node
+			near := 0
+			if domain, ok := node.Labels[e.TopologyKey]; ok {
+				near = inDomain[domain]
+			}
+			score = int64(MaxPriority*near/(2*placed) + MaxPriority*onNode[node.Name]/(2*placed))
+		}
+		priorities = append(priorities, HostPriority{Host: node.Name, Score: score})
+	}
+	return priorities, nil
+}
+
+// gangTopology returns the topology policy of an Offline and how many pods
+// of its gang, other than pod, are placed in each of the policy's domains.
+// The policy is nil when the Offline has none.
+func (e *Extender) gangTopology(ctx context.Context, name types.NamespacedName, pod *corev1.Pod) (*v1.TopologyPolicy, map[string]int, error) {
+	off := &v1.Offline{}
+	if err := e.Client.Get(ctx, name, off); err != nil {
+		return nil, nil, client.IgnoreNotFound(err)
+	}
+	policy := off.Spec.Topology
+	if policy == nil {
+		return nil, nil, nil
+	}
+	pods, err := e.gangPods(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	placed := make(map[string]int)
+	for _, p := range pods {
+		if p.Spec.NodeName == "" || p.UID == pod.UID {
+			continue
+		}
+		node := &corev1.Node{}
+		if err := e.Client.Get(ctx, types.NamespacedName{Name: p.Spec.NodeName}, node); err != nil {
+			continue
+		}
+		if domain, ok := topology.Domain(policy, node); ok {
+			placed[domain]++
+		}
+	}
+	return policy, placed, nil
+}
+
+// Bind binds an offline pod only once MinGang pods of its gang are bound
+// or reserved a node, so that no part of a gang holds resources the rest
+// of it can't get. Until then the pod reserves the node it was to be bound
+// to and the scheduler retries it.
+func (e *Extender) Bind(ctx context.Context, args *BindingArgs) *BindingResult {
+	pod := &corev1.Pod{}
+	if err := e.Client.Get(ctx, types.NamespacedName{Namespace: args.PodNamespace, Name: args.PodName}, pod); err != nil {
+		return &BindingResult{Error: err.Error()}
+	}
+	name, offline := offlineOf(pod)
+	if offline {
+		e.reserve(name, pod, args.Node)
+		ready, err := e.gangReady(ctx, name)
+		if err != nil {
+			return &BindingResult{Error: err.Error()}
+		}
+		if !ready {
+			return &BindingResult{Error: fmt.Sprintf("fewer than minGang pods of offline %s are placed, %s is reserved", name, args.Node)}
+		}
+	}
+	binding := &corev1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: args.PodNamespace, Name: args.PodName, UID: args.PodUID},
+		Target:     corev1.ObjectReference{Kind: "Node", Name: args.Node},
+	}
+	if err := e.Client.Create(ctx, binding); err != nil {
+		return &BindingResult{Error: err.Error()}
+	}
+	if offline {
+		// the pod is counted on its node from now on
+		e.release(name, pod.UID)
+	}
+	return &BindingResult{}
+}
+
+func (e *Extender) gangReady(ctx context.Context, name types.NamespacedName) (bool, error) {
+	off := &v1.Offline{}
+	if err := e.Client.Get(ctx, name, off); err != nil {
+		return false, err
+	}
+	pods, err := e.gangPods(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := e.now()
+	placed := int32(0)
+	for _, pod := range pods {
+		if r, ok := e.reservations[name][pod.UID]; pod.Spec.NodeName != "" || ok && !now.After(r.expires) {
+			placed++
+		}
+	}
+	return placed >= off.Spec.MinGang, nil
+}
@@ -0,0 +1,13 @@
+package extender
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestExtender(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Extender Suite")
+}
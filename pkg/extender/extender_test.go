@@ -0,0 +1,258 @@
+package extender
+
+import (
+	"context"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const topologyKey = "zone"
+
+func newNode(name, zone, cpu string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{topologyKey: zone}},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+		}},
+	}
+}
+
+func newOffline(name string, minGang int32) *v1.Offline {
+	return &v1.Offline{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.OfflineSpec{MinGang: minGang},
+		Status:     v1.OfflineStatus{Phase: v1.OfflineRunningPhase},
+	}
+}
+
+// newGangPod returns a pod of the Offline owner, or of an online workload
+// when owner is empty, requesting cpu and placed on node when it is set.
+func newGangPod(name, owner, cpu, node string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	if node != "" {
+		pod.Status.Phase = corev1.PodRunning
+	}
+	if owner != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: v1.GroupVersion.String(),
+			Kind:       "Offline",
+			Name:       owner,
+			Controller: &controller,
+		}}
+	}
+	return pod
+}
+
+func nodeNames(result *FilterResult) []string {
+	names := make([]string, 0)
+	for _, node := range result.Nodes.Items {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+var _ = Describe("Extender", func() {
+	var ctx context.Context
+	var c client.Client
+	var e *Extender
+	var nodes *corev1.NodeList
+	var now time.Time
+
+	setup := func(objs ...runtime.Object) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1.AddToScheme(scheme)).To(Succeed())
+		nodes = &corev1.NodeList{Items: []corev1.Node{
+			*newNode("node-a", "zone-1", "4"),
+			*newNode("node-b", "zone-1", "4"),
+			*newNode("node-c", "zone-2", "4"),
+		}}
+		for i := range nodes.Items {
+			objs = append(objs, &nodes.Items[i])
+		}
+		c = fake.NewFakeClientWithScheme(scheme, objs...)
+		e = NewExtender(c, 0, topologyKey, zap.Logger(true))
+		now = time.Now()
+		e.now = func() time.Time { return now }
+	}
+
+	// bind binds pod through the extender and, as the API server does for
+	// a Binding, sets its node.
+	bind := func(pod *corev1.Pod, node string) *BindingResult {
+		result := e.Bind(ctx, &BindingArgs{PodName: pod.Name, PodNamespace: pod.Namespace, PodUID: pod.UID, Node: node})
+		if result.Error == "" {
+			bound := &corev1.Pod{}
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, bound)).To(Succeed())
+			bound.Spec.NodeName = node
+			Expect(c.Update(ctx, bound)).To(Succeed())
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	Describe("Filter", func() {
+		It("keeps the nodes online pods leave enough capacity on", func() {
+			pod := newGangPod("job-0", "job", "2", "")
+			setup(newOffline("job", 1), pod,
+				newGangPod("online", "", "3", "node-a"),
+				newGangPod("other-0", "other", "3", "node-b"))
+
+			result := e.Filter(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(result.Error).To(BeEmpty())
+			Expect(nodeNames(result)).To(ConsistOf("node-c"))
+			Expect(result.FailedNodes).To(HaveKey("node-a"))
+			Expect(result.FailedNodes).To(HaveKey("node-b"))
+		})
+
+		It("passes the pods of online workloads through", func() {
+			pod := newGangPod("online", "", "8", "")
+			setup(pod)
+
+			result := e.Filter(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(nodeNames(result)).To(ConsistOf("node-a", "node-b", "node-c"))
+		})
+
+		It("places no pod of an Offline still waiting in its queue", func() {
+			off := newOffline("job", 1)
+			off.Status.Phase = v1.OfflinePendingPhase
+			pod := newGangPod("job-0", "job", "1", "")
+			setup(off, pod)
+			_, err := e.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "job"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			result := e.Filter(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(nodeNames(result)).To(BeEmpty())
+			Expect(result.FailedNodes).To(HaveLen(3))
+		})
+
+		It("keeps the nodes reserved for another gang for it", func() {
+			gathering := newGangPod("gathering-0", "gathering", "3", "")
+			pod := newGangPod("job-0", "job", "2", "")
+			setup(newOffline("gathering", 2), newOffline("job", 1), gathering, pod,
+				newGangPod("gathering-1", "gathering", "3", ""))
+			Expect(bind(gathering, "node-a").Error).NotTo(BeEmpty())
+
+			result := e.Filter(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(nodeNames(result)).To(ConsistOf("node-b", "node-c"))
+
+			By("not counting a pod's own reservation against it")
+			result = e.Filter(ctx, &Args{Pod: gathering, Nodes: nodes})
+			Expect(nodeNames(result)).To(ConsistOf("node-a", "node-b", "node-c"))
+
+			By("giving the node up once the reservation expires")
+			now = now.Add(ReservationTTL + time.Second)
+			result = e.Filter(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(nodeNames(result)).To(ConsistOf("node-a", "node-b", "node-c"))
+		})
+
+		It("drops the reservation of a deleted pod", func() {
+			gathering := newGangPod("gathering-0", "gathering", "3", "")
+			pod := newGangPod("job-0", "job", "2", "")
+			setup(newOffline("gathering", 2), newOffline("job", 1), gathering, pod)
+			Expect(bind(gathering, "node-a").Error).NotTo(BeEmpty())
+			Expect(c.Delete(ctx, gathering)).To(Succeed())
+
+			result := e.Filter(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(nodeNames(result)).To(ConsistOf("node-a", "node-b", "node-c"))
+			Expect(e.reservations).To(BeEmpty())
+		})
+	})
+
+	Describe("Prioritize", func() {
+		It("prefers the node and then the domain of the gang", func() {
+			pod := newGangPod("job-1", "job", "1", "")
+			setup(newOffline("job", 2), pod, newGangPod("job-0", "job", "1", "node-a"))
+
+			priorities, err := e.Prioritize(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(priorities).To(ConsistOf(
+				HostPriority{Host: "node-a", Score: MaxPriority},
+				HostPriority{Host: "node-b", Score: MaxPriority / 2},
+				HostPriority{Host: "node-c", Score: 0},
+			))
+		})
+
+		It("prefers the domains farthest from the gang for Spread", func() {
+			off := newOffline("job", 2)
+			off.Spec.Topology = &v1.TopologyPolicy{Mode: v1.TopologySpread, TopologyKey: topologyKey}
+			pod := newGangPod("job-1", "job", "1", "")
+			setup(off, pod, newGangPod("job-0", "job", "1", "node-a"))
+
+			priorities, err := e.Prioritize(ctx, &Args{Pod: pod, Nodes: nodes})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(priorities).To(ConsistOf(
+				HostPriority{Host: "node-a", Score: 0},
+				HostPriority{Host: "node-b", Score: 0},
+				HostPriority{Host: "node-c", Score: MaxPriority},
+			))
+		})
+	})
+
+	Describe("Bind", func() {
+		It("binds a gang only once minGang of its pods are placed", func() {
+			first := newGangPod("job-0", "job", "1", "")
+			second := newGangPod("job-1", "job", "1", "")
+			setup(newOffline("job", 2), first, second)
+
+			Expect(bind(first, "node-a").Error).To(ContainSubstring("fewer than minGang"))
+			Expect(e.reservations[types.NamespacedName{Namespace: "default", Name: "job"}]).To(HaveKey(first.UID))
+
+			Expect(bind(second, "node-b").Error).To(BeEmpty())
+			binding := &corev1.Binding{}
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "job-1"}, binding)).To(Succeed())
+			Expect(binding.Target.Name).To(Equal("node-b"))
+			Expect(e.reservations[types.NamespacedName{Namespace: "default", Name: "job"}]).NotTo(HaveKey(second.UID))
+
+			By("binding the rest of the gang on its retry")
+			Expect(bind(first, "node-a").Error).To(BeEmpty())
+			Expect(e.reservations).To(BeEmpty())
+		})
+
+		It("doesn't count reservations that expired", func() {
+			first := newGangPod("job-0", "job", "1", "")
+			second := newGangPod("job-1", "job", "1", "")
+			setup(newOffline("job", 2), first, second)
+
+			Expect(bind(first, "node-a").Error).NotTo(BeEmpty())
+			now = now.Add(ReservationTTL + time.Second)
+			Expect(bind(second, "node-b").Error).To(ContainSubstring("fewer than minGang"))
+		})
+
+		It("binds the pods of online workloads right away", func() {
+			pod := newGangPod("online", "", "1", "")
+			setup(pod)
+
+			Expect(bind(pod, "node-a").Error).To(BeEmpty())
+		})
+	})
+})
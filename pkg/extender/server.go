@@ -0,0 +1,71 @@
+package extender
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Handler serves the filter, prioritize and bind verbs under prefix.
+func (e *Extender) Handler(prefix string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/filter", func(w http.ResponseWriter, r *http.Request) {
+		args := &Args{}
+		if !decode(w, r, args) {
+			return
+		}
+		e.reply(w, e.Filter(r.Context(), args))
+	})
+	mux.HandleFunc(prefix+"/prioritize", func(w http.ResponseWriter, r *http.Request) {
+		args := &Args{}
+		if !decode(w, r, args) {
+			return
+		}
+		priorities, err := e.Prioritize(r.Context(), args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e.reply(w, priorities)
+	})
+	mux.HandleFunc(prefix+"/bind", func(w http.ResponseWriter, r *http.Request) {
+		args := &BindingArgs{}
+		if !decode(w, r, args) {
+			return
+		}
+		e.reply(w, e.Bind(r.Context(), args))
+	})
+	return mux
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (e *Extender) reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		e.Log.Error(err, "unable to write response")
+	}
+}
+
+// Serve serves the verbs on addr until stop is closed.
+func (e *Extender) Serve(addr, prefix string, stop <-chan struct{}) error {
+	server := &http.Server{Addr: addr, Handler: e.Handler(prefix)}
+	go func() {
+		<-stop
+		_ = server.Shutdown(context.Background())
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package extender
+
+import (
+	"context"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reconcile mirrors an Offline into the queues of Cache: Offlines still
+// pending are queued, the others are removed from their queue.
+func (e *Extender) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	off := &v1.Offline{}
+	if err := e.Client.Get(context.Background(), req.NamespacedName, off); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		e.forget(&v1.Offline{ObjectMeta: metav1.ObjectMeta{Namespace: req.Namespace, Name: req.Name}})
+		return ctrl.Result{}, nil
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	queue := e.Cache.Get(utils.GetOfflineQueueName(off))
+	pending := off.Status.Phase == "" || off.Status.Phase == v1.OfflinePendingPhase || off.Status.Phase == v1.OfflineWaitingPhase
+	if pending && off.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, queue.AddSchedulingQ(off)
+	}
+	if _, exist := queue.Get(req.String()); exist {
+		return ctrl.Result{}, queue.Delete(off)
+	}
+	return ctrl.Result{}, nil
+}
+
+// forget removes a deleted Offline from every queue and drops the
+// reservations of its gang.
+func (e *Extender) forget(off *v1.Offline) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	name, _ := utils.KeyFn(off)
+	for _, queueName := range e.Cache.List() {
+		queue := e.Cache.Get(queueName)
+		if _, exist := queue.Get(name); exist {
+			_ = queue.Delete(off)
+		}
+	}
+	for gang := range e.reservations {
+		if gang.String() == name {
+			delete(e.reservations, gang)
+		}
+	}
+}
+
+// podDeleted drops the reservation of a deleted offline pod.
+func (e *Extender) podDeleted(evt event.DeleteEvent, _ workqueue.RateLimitingInterface) {
+	pod, ok := evt.Object.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	if name, ok := offlineOf(pod); ok {
+		e.release(name, pod.UID)
+	}
+}
+
+// SetupWithManager mirrors the Offlines into Cache and releases the
+// reservations of the pods deleted.
+func (e *Extender) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Offline{}).
+		Watches(&source.Kind{Type: &corev1.Pod{}}, &handler.Funcs{DeleteFunc: e.podDeleted}).
+		Complete(e)
+}
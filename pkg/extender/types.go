@@ -0,0 +1,54 @@
+package extender
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The types below are the wire format of the scheduler extender protocol
+// of kube-scheduler, copied to avoid depending on k8s.io/kubernetes.
+
+// MaxPriority is the highest score an extender gives a node.
+const MaxPriority = 10
+
+// Args is the pod to schedule and its candidate nodes.
+type Args struct {
+	Pod *corev1.Pod `json:"pod"`
+	// Nodes is set unless the extender is configured with nodeCacheCapable.
+	Nodes *corev1.NodeList `json:"nodes,omitempty"`
+	// NodeNames is set if the extender is configured with nodeCacheCapable.
+	NodeNames *[]string `json:"nodenames,omitempty"`
+}
+
+// FailedNodesMap maps the nodes filtered out to the reason why.
+type FailedNodesMap map[string]string
+
+// FilterResult is the response of the filter verb.
+type FilterResult struct {
+	Nodes       *corev1.NodeList `json:"nodes,omitempty"`
+	NodeNames   *[]string        `json:"nodenames,omitempty"`
+	FailedNodes FailedNodesMap   `json:"failedNodes,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// HostPriority is the score of a node.
+type HostPriority struct {
+	Host  string `json:"host"`
+	Score int64  `json:"score"`
+}
+
+// HostPriorityList is the response of the prioritize verb.
+type HostPriorityList []HostPriority
+
+// BindingArgs is the request of the bind verb.
+type BindingArgs struct {
+	PodName      string    `json:"podName"`
+	PodNamespace string    `json:"podNamespace"`
+	PodUID       types.UID `json:"podUID"`
+	Node         string    `json:"node"`
+}
+
+// BindingResult is the response of the bind verb.
+type BindingResult struct {
+	Error string `json:"error,omitempty"`
+}
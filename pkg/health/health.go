@@ -0,0 +1,144 @@
+// Package health serves the liveness and readiness probes of the manager.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// LivenessPath fails when the manager is wedged and should be
+	// restarted.
+	LivenessPath = "/healthz"
+	// ReadinessPath fails until the manager can serve.
+	ReadinessPath = "/readyz"
+)
+
+// Check returns nil when its part of the manager is fine.
+type Check func(req *http.Request) error
+
+// Ping always succeeds, it tells the probe server itself is up.
+func Ping(_ *http.Request) error {
+	return nil
+}
+
+// Server serves LivenessPath and ReadinessPath, each failing with 500 while
+// one of its checks fails. The failing checks are listed in the body, all
+// checks with ?verbose.
+type Server struct {
+	BindAddress string
+	Log         logr.Logger
+
+	lock      sync.RWMutex
+	liveness  map[string]Check
+	readiness map[string]Check
+}
+
+func NewServer(bindAddress string, log logr.Logger) *Server {
+	return &Server{
+		BindAddress: bindAddress,
+		Log:         log,
+		liveness:    map[string]Check{"ping": Ping},
+		readiness:   map[string]Check{"ping": Ping},
+	}
+}
+
+// AddLivenessCheck adds check to LivenessPath under name.
+func (s *Server) AddLivenessCheck(name string, check Check) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.liveness[name] = check
+}
+
+// AddReadinessCheck adds check to ReadinessPath under name.
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.readiness[name] = check
+}
+
+// NeedLeaderElection is false: standby replicas are probed too.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler serves both paths.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, req *http.Request) {
+		s.serve(w, req, s.liveness)
+	})
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, req *http.Request) {
+		s.serve(w, req, s.readiness)
+	})
+	return mux
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request, checks map[string]Check) {
+	s.lock.RLock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failed := false
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := checks[name](req); err != nil {
+			failed = true
+			lines = append(lines, fmt.Sprintf("[-]%s failed: %v", name, err))
+		} else {
+			lines = append(lines, fmt.Sprintf("[+]%s ok", name))
+		}
+	}
+	s.lock.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if failed {
+		w.WriteHeader(http.StatusInternalServerError)
+		for _, line := range lines {
+			if strings.HasPrefix(line, "[-]") || req.URL.Query().Get("verbose") != "" {
+				fmt.Fprintln(w, line)
+			}
+		}
+		fmt.Fprintf(w, "%s check failed\n", strings.TrimPrefix(req.URL.Path, "/"))
+		return
+	}
+	if req.URL.Query().Get("verbose") != "" {
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Start serves the probes until stop is closed. It is a manager.Runnable.
+func (s *Server) Start(stop <-chan struct{}) error {
+	listener, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: s.Handler()}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			s.Log.Error(err, "unable to shut the probe server down")
+		}
+	}()
+	s.Log.Info("serving probes", "address", s.BindAddress)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-logr/logr/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	probe := func(s *Server, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	It("fails a path while one of its checks fails", func() {
+		s := NewServer(":0", testing.NullLogger{})
+		ready := errors.New("informers not synced")
+		s.AddReadinessCheck("cache", func(_ *http.Request) error { return ready })
+
+		Expect(probe(s, LivenessPath).Code).To(Equal(http.StatusOK))
+		recorder := probe(s, ReadinessPath)
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Body.String()).To(ContainSubstring("[-]cache failed: informers not synced"))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("[+]ping"))
+
+		ready = nil
+		Expect(probe(s, ReadinessPath).Code).To(Equal(http.StatusOK))
+		Expect(probe(s, ReadinessPath+"?verbose=1").Body.String()).To(Equal("[+]cache ok\n[+]ping ok\nok\n"))
+	})
+})
+
+var _ = Describe("Watchdog", func() {
+	It("fails once a reconcile runs longer than its timeout", func() {
+		now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		w := NewWatchdog(time.Minute)
+		w.now = func() time.Time { return now }
+
+		done := w.Begin()
+		now = now.Add(30 * time.Second)
+		Expect(w.Check(nil)).To(Succeed())
+		now = now.Add(time.Minute)
+		Expect(w.Check(nil)).To(MatchError(ContainSubstring("running for 1m30s")))
+
+		done()
+		Expect(w.Check(nil)).To(Succeed())
+	})
+})
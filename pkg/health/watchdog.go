@@ -0,0 +1,59 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultReconcileTimeout is how long a reconcile may run before the
+// Watchdog reports the loop wedged.
+const DefaultReconcileTimeout = 5 * time.Minute
+
+// Watchdog tracks the reconciles in flight and fails its Check once one
+// of them has run longer than Timeout, which a reconcile blocked on a lock
+// or a hung call does; a reconcile loop merely waiting for events is fine.
+type Watchdog struct {
+	Timeout time.Duration
+
+	lock     sync.Mutex
+	next     uint64
+	inflight map[uint64]time.Time
+	now      func() time.Time
+}
+
+func NewWatchdog(timeout time.Duration) *Watchdog {
+	if timeout <= 0 {
+		timeout = DefaultReconcileTimeout
+	}
+	return &Watchdog{Timeout: timeout, inflight: map[uint64]time.Time{}, now: time.Now}
+}
+
+// Begin records the start of a reconcile, the returned func its end.
+func (w *Watchdog) Begin() func() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	id := w.next
+	w.next++
+	w.inflight[id] = w.now()
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.inflight, id)
+	}
+}
+
+// Check fails when the oldest reconcile in flight started more than
+// Timeout ago.
+func (w *Watchdog) Check(_ *http.Request) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := w.now()
+	for _, started := range w.inflight {
+		if running := now.Sub(started); running > w.Timeout {
+			return fmt.Errorf("a reconcile has been running for %s, longer than %s", running.Round(time.Second), w.Timeout)
+		}
+	}
+	return nil
+}
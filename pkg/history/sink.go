@@ -0,0 +1,177 @@
+// Package history streams the transitions of Offlines out of the cluster
+// as JSON lines, so what happened to a job can be reconstructed after its
+// status has been overwritten or the Offline deleted.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/go-logr/logr"
+)
+
+// DefaultLimit is how many transitions an Offline keeps in its status when
+// no history limit is set.
+const DefaultLimit = 20
+
+// Record is a transition of an Offline written to a Sink.
+type Record struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Queue     string `json:"queue"`
+	v1.OfflineTransition
+}
+
+// Sink receives every transition of every Offline.
+type Sink interface {
+	Send(records ...Record) error
+}
+
+// FileSink appends records to a file, one JSON object per line.
+type FileSink struct {
+	Path string
+
+	lock sync.Mutex
+}
+
+func (f *FileSink) Send(records ...Record) error {
+	data, err := encode(records)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WebhookSink posts records to a URL, one JSON object per line of the body.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookSink) Send(records ...Record) error {
+	data, err := encode(records)
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(w.URL, "application/x-ndjson", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+func encode(records []Record) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// NewSink returns a WebhookSink for an http or https URL and a FileSink for
+// any other target.
+func NewSink(target string) Sink {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return &WebhookSink{URL: target, Client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	return &FileSink{Path: target}
+}
+
+// DefaultBufferSize is how many records a Stream holds before it drops them.
+const DefaultBufferSize = 1024
+
+// Stream hands records over to a Sink in the background so reconciles
+// never wait on it. Records sent while its buffer is full are dropped and
+// logged, and a batch the Sink fails to take is retried once.
+type Stream struct {
+	Sink Sink
+	Log  logr.Logger
+
+	records chan Record
+}
+
+func NewStream(sink Sink, size int, log logr.Logger) *Stream {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Stream{Sink: sink, Log: log, records: make(chan Record, size)}
+}
+
+// Send queues records without blocking.
+func (s *Stream) Send(records ...Record) error {
+	for _, record := range records {
+		select {
+		case s.records <- record:
+		default:
+			return fmt.Errorf("history buffer full, dropped transition of %s/%s to %s", record.Namespace, record.Name, record.Phase)
+		}
+	}
+	return nil
+}
+
+// Start writes queued records to the Sink until stop is closed, flushing
+// what is left before it returns. It is a manager.Runnable.
+func (s *Stream) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case record := <-s.records:
+			s.write(append([]Record{record}, s.drain()...))
+		case <-stop:
+			if records := s.drain(); len(records) > 0 {
+				s.write(records)
+			}
+			return nil
+		}
+	}
+}
+
+// drain takes the records queued so far.
+func (s *Stream) drain() []Record {
+	records := make([]Record, 0)
+	for {
+		select {
+		case record := <-s.records:
+			records = append(records, record)
+		default:
+			return records
+		}
+	}
+}
+
+func (s *Stream) write(records []Record) {
+	err := s.Sink.Send(records...)
+	if err != nil {
+		err = s.Sink.Send(records...)
+	}
+	if err != nil {
+		s.Log.Error(err, "unable to write transitions", "records", len(records))
+	}
+}
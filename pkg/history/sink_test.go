@@ -0,0 +1,139 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newRecord(name string, phase v1.OfflinePhase) Record {
+	return Record{
+		Namespace: "default",
+		Name:      name,
+		Queue:     "default",
+		OfflineTransition: v1.OfflineTransition{
+			Phase:         phase,
+			From:          v1.OfflinePendingPhase,
+			Time:          metav1.NewTime(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC).Local()),
+			QueuePosition: 2,
+			Attempt:       1,
+		},
+	}
+}
+
+// decode parses JSON lines into records.
+func decode(data string) []Record {
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		record := Record{}
+		Expect(json.Unmarshal(scanner.Bytes(), &record)).To(Succeed())
+		records = append(records, record)
+	}
+	return records
+}
+
+// receiver is a local webhook collecting the lines posted to it.
+type receiver struct {
+	lock   sync.Mutex
+	bodies []string
+	status int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+}
+
+func (r *receiver) records() []Record {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return decode(strings.Join(r.bodies, ""))
+}
+
+var _ = Describe("Sinks", func() {
+	It("appends JSON lines to a file", func() {
+		dir, err := ioutil.TempDir("", "history")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "transitions.jsonl")
+
+		sink := NewSink(path)
+		Expect(sink).To(BeAssignableToTypeOf(&FileSink{}))
+		Expect(sink.Send(newRecord("a", v1.OfflineSchedulingPhase))).To(Succeed())
+		Expect(sink.Send(newRecord("b", v1.OfflineRunningPhase), newRecord("c", v1.OfflineFailedPhase))).To(Succeed())
+
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		records := decode(string(data))
+		Expect(records).To(HaveLen(3))
+		Expect(records[0]).To(Equal(newRecord("a", v1.OfflineSchedulingPhase)))
+		Expect(records[2].Name).To(Equal("c"))
+	})
+
+	It("posts JSON lines to a webhook", func() {
+		r := &receiver{}
+		server := httptest.NewServer(r)
+		defer server.Close()
+
+		sink := NewSink(server.URL)
+		Expect(sink).To(BeAssignableToTypeOf(&WebhookSink{}))
+		Expect(sink.Send(newRecord("a", v1.OfflineRunningPhase), newRecord("b", v1.OfflineSucceededPhase))).To(Succeed())
+		Expect(r.records()).To(Equal([]Record{newRecord("a", v1.OfflineRunningPhase), newRecord("b", v1.OfflineSucceededPhase)}))
+	})
+
+	It("fails when the webhook refuses the records", func() {
+		server := httptest.NewServer(&receiver{status: http.StatusInternalServerError})
+		defer server.Close()
+		Expect(NewSink(server.URL).Send(newRecord("a", v1.OfflineRunningPhase))).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("Stream", func() {
+	It("streams records to the sink in the background and flushes them on stop", func() {
+		r := &receiver{}
+		server := httptest.NewServer(r)
+		defer server.Close()
+
+		stream := NewStream(NewSink(server.URL), 10, log.Log)
+		stop := make(chan struct{})
+		done := make(chan error)
+		go func() { done <- stream.Start(stop) }()
+
+		Expect(stream.Send(newRecord("a", v1.OfflineSchedulingPhase))).To(Succeed())
+		Eventually(r.records).Should(HaveLen(1))
+		Expect(stream.Send(newRecord("b", v1.OfflineRunningPhase), newRecord("c", v1.OfflineSucceededPhase))).To(Succeed())
+		close(stop)
+		Eventually(done).Should(Receive(BeNil()))
+
+		names := make([]string, 0)
+		for _, record := range r.records() {
+			names = append(names, record.Name)
+		}
+		Expect(names).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("drops records instead of blocking when its buffer is full", func() {
+		stream := NewStream(&FileSink{Path: os.DevNull}, 1, log.Log)
+		Expect(stream.Send(newRecord("a", v1.OfflineSchedulingPhase))).To(Succeed())
+		Expect(stream.Send(newRecord("b", v1.OfflineSchedulingPhase))).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,140 @@
+// Package multicache builds the cache of a manager watching a set of
+// namespaces.
+package multicache
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("multicache")
+
+// New returns a cache holding the namespaced objects of namespaces only. The
+// multi-namespace cache of controller-runtime can't serve cluster-scoped
+// objects such as nodes, which are read through a cluster-wide cache
+// instead.
+func New(namespaces []string) ctrlcache.NewCacheFunc {
+	return func(config *rest.Config, opts ctrlcache.Options) (ctrlcache.Cache, error) {
+		if opts.Scheme == nil {
+			opts.Scheme = scheme.Scheme
+		}
+		if opts.Mapper == nil {
+			mapper, err := apiutil.NewDiscoveryRESTMapper(config)
+			if err != nil {
+				return nil, err
+			}
+			opts.Mapper = mapper
+		}
+		namespaced, err := ctrlcache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.Namespace = ""
+		cluster, err := ctrlcache.New(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &scopedCache{namespaced: namespaced, cluster: cluster, scheme: opts.Scheme, mapper: opts.Mapper}, nil
+	}
+}
+
+// scopedCache reads namespaced objects from one cache and cluster-scoped
+// objects from another.
+type scopedCache struct {
+	namespaced ctrlcache.Cache
+	cluster    ctrlcache.Cache
+	scheme     *runtime.Scheme
+	mapper     meta.RESTMapper
+}
+
+var _ ctrlcache.Cache = &scopedCache{}
+
+// cacheForKind returns the cache holding the objects of gvk.
+func (c *scopedCache) cacheForKind(gvk schema.GroupVersionKind) (ctrlcache.Cache, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return c.cluster, nil
+	}
+	return c.namespaced, nil
+}
+
+// cacheFor returns the cache holding obj, or the items of obj when it is a
+// list.
+func (c *scopedCache) cacheFor(obj runtime.Object) (ctrlcache.Cache, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	if meta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	return c.cacheForKind(gvk)
+}
+
+func (c *scopedCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	cache, err := c.cacheFor(obj)
+	if err != nil {
+		return err
+	}
+	return cache.Get(ctx, key, obj)
+}
+
+func (c *scopedCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	cache, err := c.cacheFor(list)
+	if err != nil {
+		return err
+	}
+	return cache.List(ctx, list, opts...)
+}
+
+func (c *scopedCache) GetInformer(obj runtime.Object) (ctrlcache.Informer, error) {
+	cache, err := c.cacheFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return cache.GetInformer(obj)
+}
+
+func (c *scopedCache) GetInformerForKind(gvk schema.GroupVersionKind) (ctrlcache.Informer, error) {
+	cache, err := c.cacheForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return cache.GetInformerForKind(gvk)
+}
+
+func (c *scopedCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	cache, err := c.cacheFor(obj)
+	if err != nil {
+		return err
+	}
+	return cache.IndexField(obj, field, extractValue)
+}
+
+// Start runs both caches until stop is closed.
+func (c *scopedCache) Start(stop <-chan struct{}) error {
+	go func() {
+		if err := c.cluster.Start(stop); err != nil {
+			log.Error(err, "cluster-scoped cache failed to start")
+		}
+	}()
+	return c.namespaced.Start(stop)
+}
+
+func (c *scopedCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	synced := c.cluster.WaitForCacheSync(stop)
+	return c.namespaced.WaitForCacheSync(stop) && synced
+}
@@ -0,0 +1,13 @@
+package multicache
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMulticache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Multicache Suite")
+}
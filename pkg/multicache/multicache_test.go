@@ -0,0 +1,81 @@
+package multicache
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recordingCache remembers the kinds read through it.
+type recordingCache struct {
+	ctrlcache.Cache
+	read []string
+}
+
+func (c *recordingCache) record(obj runtime.Object) {
+	c.read = append(c.read, obj.GetObjectKind().GroupVersionKind().Kind)
+}
+
+func (c *recordingCache) Get(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	c.record(obj)
+	return nil
+}
+
+func (c *recordingCache) List(_ context.Context, list runtime.Object, _ ...client.ListOption) error {
+	c.record(list)
+	return nil
+}
+
+func (c *recordingCache) GetInformerForKind(gvk schema.GroupVersionKind) (ctrlcache.Informer, error) {
+	c.read = append(c.read, gvk.Kind)
+	return nil, nil
+}
+
+var _ = Describe("scopedCache", func() {
+	var namespaced, cluster *recordingCache
+	var c *scopedCache
+
+	BeforeEach(func() {
+		namespaced, cluster = &recordingCache{}, &recordingCache{}
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+		mapper.Add(corev1.SchemeGroupVersion.WithKind("Pod"), meta.RESTScopeNamespace)
+		c = &scopedCache{namespaced: namespaced, cluster: cluster, scheme: scheme.Scheme, mapper: mapper}
+	})
+
+	It("reads cluster-scoped objects from the cluster-wide cache", func() {
+		node := &corev1.Node{}
+		node.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+		Expect(c.Get(context.Background(), client.ObjectKey{Name: "node"}, node)).To(Succeed())
+		nodes := &corev1.NodeList{}
+		nodes.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NodeList"))
+		Expect(c.List(context.Background(), nodes)).To(Succeed())
+		_, err := c.GetInformerForKind(corev1.SchemeGroupVersion.WithKind("Node"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cluster.read).To(Equal([]string{"Node", "NodeList", "Node"}))
+		Expect(namespaced.read).To(BeEmpty())
+	})
+
+	It("reads namespaced objects from the namespaces it watches", func() {
+		pods := &corev1.PodList{}
+		pods.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PodList"))
+		Expect(c.List(context.Background(), pods, client.InNamespace("batch"))).To(Succeed())
+
+		Expect(namespaced.read).To(Equal([]string{"PodList"}))
+		Expect(cluster.read).To(BeEmpty())
+	})
+
+	It("fails for a kind the API server doesn't serve", func() {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "batch", Name: "cm"}, cm)).NotTo(Succeed())
+	})
+})
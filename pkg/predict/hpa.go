@@ -0,0 +1,82 @@
+package predict
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+)
+
+// DefaultWindow is how far back replica counts are remembered to find the
+// trend of an autoscaler.
+const DefaultWindow = 5 * time.Minute
+
+type sample struct {
+	at       time.Time
+	replicas int32
+}
+
+// HPAPredictor predicts how many replicas a HorizontalPodAutoscaler will ask
+// for soon, from its current utilisation and the recent trend of its
+// desired replicas.
+type HPAPredictor struct {
+	lock    sync.Mutex
+	window  time.Duration
+	history map[string][]sample
+}
+
+func NewHPAPredictor(window time.Duration) *HPAPredictor {
+	return &HPAPredictor{
+		window:  window,
+		history: make(map[string][]sample),
+	}
+}
+
+// Observe records the desired replicas of the autoscaler named key.
+func (p *HPAPredictor) Observe(key string, hpa *autoscalingv1.HorizontalPodAutoscaler, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	samples := append(p.history[key], sample{at: now, replicas: hpa.Status.DesiredReplicas})
+	for len(samples) > 0 && now.Sub(samples[0].at) > p.window {
+		samples = samples[1:]
+	}
+	p.history[key] = samples
+}
+
+// Forget drops the history of a deleted autoscaler.
+func (p *HPAPredictor) Forget(key string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.history, key)
+}
+
+// Predict returns the replicas the autoscaler named key is expected to ask
+// for within the window: the replicas its current utilisation calls for,
+// plus the growth seen over the window, bounded by its max replicas.
+func (p *HPAPredictor) Predict(key string, hpa *autoscalingv1.HorizontalPodAutoscaler) int32 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	predicted := hpa.Status.DesiredReplicas
+	if hpa.Status.CurrentReplicas > predicted {
+		predicted = hpa.Status.CurrentReplicas
+	}
+	target := hpa.Spec.TargetCPUUtilizationPercentage
+	current := hpa.Status.CurrentCPUUtilizationPercentage
+	if target != nil && *target > 0 && current != nil {
+		byUtilisation := int32(math.Ceil(float64(hpa.Status.CurrentReplicas) * float64(*current) / float64(*target)))
+		if byUtilisation > predicted {
+			predicted = byUtilisation
+		}
+	}
+	if samples := p.history[key]; len(samples) > 1 {
+		if growth := samples[len(samples)-1].replicas - samples[0].replicas; growth > 0 {
+			predicted += growth
+		}
+	}
+	if predicted > hpa.Spec.MaxReplicas {
+		predicted = hpa.Spec.MaxReplicas
+	}
+	return predicted
+}
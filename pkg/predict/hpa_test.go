@@ -0,0 +1,92 @@
+package predict
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// newHPA returns an autoscaler at current replicas asking for desired,
+// bounded by max, with no cpu target.
+func newHPA(current, desired, max int32) *autoscalingv1.HorizontalPodAutoscaler {
+	hpa := &autoscalingv1.HorizontalPodAutoscaler{}
+	hpa.Spec.MaxReplicas = max
+	hpa.Status.CurrentReplicas = current
+	hpa.Status.DesiredReplicas = desired
+	return hpa
+}
+
+func withUtilisation(hpa *autoscalingv1.HorizontalPodAutoscaler, target, current int32) *autoscalingv1.HorizontalPodAutoscaler {
+	hpa.Spec.TargetCPUUtilizationPercentage = int32Ptr(target)
+	hpa.Status.CurrentCPUUtilizationPercentage = int32Ptr(current)
+	return hpa
+}
+
+var _ = Describe("HPAPredictor", func() {
+	var p *HPAPredictor
+
+	BeforeEach(func() {
+		p = NewHPAPredictor(DefaultWindow)
+	})
+
+	It("predicts the larger of the current and desired replicas", func() {
+		Expect(p.Predict("a", newHPA(3, 5, 10))).To(Equal(int32(5)))
+		Expect(p.Predict("a", newHPA(4, 2, 10))).To(Equal(int32(4)))
+	})
+
+	It("predicts the replicas the current utilisation calls for", func() {
+		Expect(p.Predict("a", withUtilisation(newHPA(4, 4, 10), 50, 80))).To(Equal(int32(7)))
+	})
+
+	It("ignores the utilisation when there is no target", func() {
+		hpa := withUtilisation(newHPA(4, 4, 10), 0, 80)
+		Expect(p.Predict("a", hpa)).To(Equal(int32(4)))
+	})
+
+	It("adds the growth seen over the window", func() {
+		p.Observe("a", newHPA(2, 2, 10), epoch)
+		p.Observe("a", newHPA(2, 3, 10), epoch.Add(time.Minute))
+		p.Observe("a", newHPA(3, 5, 10), epoch.Add(2*time.Minute))
+		Expect(p.Predict("a", newHPA(3, 5, 10))).To(Equal(int32(8)))
+	})
+
+	It("doesn't subtract a shrinking trend", func() {
+		p.Observe("a", newHPA(5, 5, 10), epoch)
+		p.Observe("a", newHPA(5, 3, 10), epoch.Add(time.Minute))
+		Expect(p.Predict("a", newHPA(5, 3, 10))).To(Equal(int32(5)))
+	})
+
+	It("forgets samples older than the window", func() {
+		p.Observe("a", newHPA(1, 1, 10), epoch)
+		p.Observe("a", newHPA(3, 3, 10), epoch.Add(time.Minute))
+		p.Observe("a", newHPA(3, 3, 10), epoch.Add(DefaultWindow+2*time.Minute))
+		Expect(p.Predict("a", newHPA(3, 3, 10))).To(Equal(int32(3)))
+	})
+
+	It("keeps the history of every autoscaler apart", func() {
+		p.Observe("a", newHPA(1, 1, 10), epoch)
+		p.Observe("a", newHPA(1, 4, 10), epoch.Add(time.Minute))
+		Expect(p.Predict("b", newHPA(1, 1, 10))).To(Equal(int32(1)))
+	})
+
+	It("forgets the history of a deleted autoscaler", func() {
+		p.Observe("a", newHPA(1, 1, 10), epoch)
+		p.Observe("a", newHPA(1, 4, 10), epoch.Add(time.Minute))
+		p.Forget("a")
+		Expect(p.Predict("a", newHPA(1, 4, 10))).To(Equal(int32(4)))
+	})
+
+	It("never predicts more than the max replicas", func() {
+		p.Observe("a", newHPA(2, 2, 6), epoch)
+		p.Observe("a", newHPA(2, 5, 6), epoch.Add(time.Minute))
+		Expect(p.Predict("a", withUtilisation(newHPA(2, 5, 6), 50, 100))).To(Equal(int32(6)))
+	})
+})
@@ -0,0 +1,13 @@
+package predict
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPredict(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Predict Suite")
+}
@@ -0,0 +1,62 @@
+package reclaim
+
+import (
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultSafetyMargin is the part of a node's allocatable cpu and memory
+// never offered to offlines.
+const DefaultSafetyMargin = 0.1
+
+// Reclaimable returns the batch resources a node can offer to offlines: its
+// allocatable cpu and memory minus what online pods use and the safety
+// margin. The usage of an online pod is its measured usage when usage has
+// it, and its requests otherwise.
+func Reclaimable(node *corev1.Node, onlinePods []corev1.Pod, usage Usage, margin float64) corev1.ResourceList {
+	used := corev1.ResourceList{}
+	for i := range onlinePods {
+		utils.AddResources(used, usage.Of(&onlinePods[i]))
+	}
+
+	cpu := reclaimable(node.Status.Allocatable, used, corev1.ResourceCPU, margin, true)
+	memory := reclaimable(node.Status.Allocatable, used, corev1.ResourceMemory, margin, false)
+	return corev1.ResourceList{
+		v1.BatchCPUResource:    *resource.NewQuantity(cpu, resource.DecimalSI),
+		v1.BatchMemoryResource: *resource.NewQuantity(memory, resource.BinarySI),
+	}
+}
+
+func reclaimable(allocatable, usage corev1.ResourceList, name corev1.ResourceName, margin float64, milli bool) int64 {
+	value := func(list corev1.ResourceList) int64 {
+		quantity, ok := list[name]
+		if !ok {
+			return 0
+		}
+		if milli {
+			return quantity.MilliValue()
+		}
+		return quantity.Value()
+	}
+	total := value(allocatable)
+	left := total - value(usage) - int64(float64(total)*margin)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Equal reports whether node already advertises the batch resources.
+func Equal(node *corev1.Node, batch corev1.ResourceList) bool {
+	for name, quantity := range batch {
+		for _, list := range []corev1.ResourceList{node.Status.Capacity, node.Status.Allocatable} {
+			current, ok := list[name]
+			if !ok || current.Cmp(quantity) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
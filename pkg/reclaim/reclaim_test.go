@@ -0,0 +1,87 @@
+package reclaim_test
+
+import (
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/reclaim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newNode(cpu, memory string) *corev1.Node {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	return node
+}
+
+func newPod(name, cpu, memory string) corev1.Pod {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	pod.Spec.Containers = []corev1.Container{{
+		Name: "main",
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}},
+	}}
+	return pod
+}
+
+func batchOf(list corev1.ResourceList) (int64, int64) {
+	cpu := list[v1.BatchCPUResource]
+	memory := list[v1.BatchMemoryResource]
+	return cpu.Value(), memory.Value()
+}
+
+var _ = Describe("Reclaimable", func() {
+	It("offers the allocatable resources less the margin to an idle node", func() {
+		cpu, memory := batchOf(reclaim.Reclaimable(newNode("10", "10Gi"), nil, nil, 0.1))
+		Expect(cpu).To(Equal(int64(9000)))
+		Expect(memory).To(Equal(int64(9 * 1024 * 1024 * 1024)))
+	})
+
+	It("counts online pods by their requests when they aren't measured", func() {
+		pods := []corev1.Pod{newPod("a", "2", "1Gi"), newPod("b", "500m", "1Gi")}
+		cpu, memory := batchOf(reclaim.Reclaimable(newNode("10", "10Gi"), pods, nil, 0))
+		Expect(cpu).To(Equal(int64(7500)))
+		Expect(memory).To(Equal(int64(8 * 1024 * 1024 * 1024)))
+	})
+
+	It("counts online pods by their measured usage", func() {
+		pods := []corev1.Pod{newPod("a", "2", "1Gi"), newPod("b", "500m", "1Gi")}
+		usage := reclaim.Usage{
+			types.NamespacedName{Namespace: "default", Name: "a"}: {
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		}
+		cpu, memory := batchOf(reclaim.Reclaimable(newNode("10", "10Gi"), pods, usage, 0))
+		Expect(cpu).To(Equal(int64(9000)))
+		Expect(memory).To(Equal(int64(8*1024*1024*1024 + 512*1024*1024)))
+	})
+
+	It("never offers less than nothing", func() {
+		pods := []corev1.Pod{newPod("a", "12", "12Gi")}
+		cpu, memory := batchOf(reclaim.Reclaimable(newNode("10", "10Gi"), pods, nil, 0.1))
+		Expect(cpu).To(BeZero())
+		Expect(memory).To(BeZero())
+	})
+})
+
+var _ = Describe("Equal", func() {
+	It("reports whether the node advertises the batch resources already", func() {
+		node := newNode("10", "10Gi")
+		batch := reclaim.Reclaimable(node, nil, nil, 0)
+		Expect(reclaim.Equal(node, batch)).To(BeFalse())
+
+		node.Status.Capacity = batch.DeepCopy()
+		node.Status.Allocatable = batch.DeepCopy()
+		Expect(reclaim.Equal(node, batch)).To(BeTrue())
+		Expect(reclaim.Equal(node, reclaim.Reclaimable(newNode("8", "10Gi"), nil, nil, 0))).To(BeFalse())
+	})
+})
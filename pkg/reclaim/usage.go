@@ -0,0 +1,69 @@
+package reclaim
+
+import (
+	"context"
+
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podMetricsList is the list of the pod usage the metrics server serves.
+var podMetricsList = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetricsList"}
+
+// Usage is the measured cpu and memory of pods, by namespaced name.
+type Usage map[types.NamespacedName]corev1.ResourceList
+
+// Of returns the measured usage of pod, or its requests when it wasn't
+// measured.
+func (u Usage) Of(pod *corev1.Pod) corev1.ResourceList {
+	if used, ok := u[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]; ok {
+		return used
+	}
+	return utils.PodRequests(&pod.Spec)
+}
+
+// MeasuredUsage reads the usage of every pod from the metrics API. It
+// returns no usage, and no error, when no metrics server is installed.
+// The list is read as unstructured objects so it goes to the API server
+// rather than to an informer.
+func MeasuredUsage(ctx context.Context, c client.Reader) (Usage, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(podMetricsList)
+	if err := c.List(ctx, list); err != nil {
+		if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	usage := make(Usage, len(list.Items))
+	for _, item := range list.Items {
+		containers, _, err := unstructured.NestedSlice(item.Object, "containers")
+		if err != nil {
+			return nil, err
+		}
+		used := corev1.ResourceList{}
+		for _, container := range containers {
+			fields, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			values, _, _ := unstructured.NestedStringMap(fields, "usage")
+			for name, value := range values {
+				quantity, err := resource.ParseQuantity(value)
+				if err != nil {
+					return nil, err
+				}
+				utils.AddResources(used, corev1.ResourceList{corev1.ResourceName(name): quantity})
+			}
+		}
+		usage[types.NamespacedName{Namespace: item.GetNamespace(), Name: item.GetName()}] = used
+	}
+	return usage, nil
+}
@@ -0,0 +1,552 @@
+// Package simulate replays a trace of Offline submissions against a
+// synthetic cluster in virtual time, driving the ordering and admission
+// logic of pkg/cache the way the controller does, so scheduling policies can
+// be compared before they are rolled out.
+package simulate
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/cache"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// epoch is the virtual time the trace starts at.
+var epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Report summarizes how a trace ran under a Policy.
+type Report struct {
+	Policy string `json:"policy"`
+	// Submitted counts the Offlines of the trace, Completed those that ran
+	// to the end and Unfinished those that never started.
+	Submitted  int `json:"submitted"`
+	Completed  int `json:"completed"`
+	Unfinished int `json:"unfinished"`
+	// The wait of an Offline is the time from its arrival to its first
+	// start.
+	WaitP50 time.Duration `json:"waitP50"`
+	WaitP90 time.Duration `json:"waitP90"`
+	WaitP99 time.Duration `json:"waitP99"`
+	WaitMax time.Duration `json:"waitMax"`
+	// CPUUtilisation and MemoryUtilisation are the fractions of the
+	// inventory used between the first arrival and the last completion.
+	CPUUtilisation    float64 `json:"cpuUtilisation"`
+	MemoryUtilisation float64 `json:"memoryUtilisation"`
+	// Starved counts the Offlines that waited longer than the starvation
+	// threshold, including the Unfinished ones.
+	Starved     int `json:"starved"`
+	Preemptions int `json:"preemptions"`
+	Backfilled  int `json:"backfilled"`
+	// Makespan is the time from the first arrival to the last completion.
+	Makespan time.Duration `json:"makespan"`
+}
+
+type job struct {
+	off   *v1.Offline
+	queue string
+	reqs  corev1.ResourceList
+	// task is what each of the tasks of the gang requests, placement the
+	// node each task runs on while the gang is running.
+	task      corev1.ResourceList
+	placement []int
+	arrival   time.Time
+	runtime   time.Duration
+	started   *time.Time
+	running   bool
+	finished  bool
+	// generation invalidates the completion of a run that was preempted.
+	generation int
+}
+
+type completion struct {
+	at         time.Time
+	job        *job
+	generation int
+}
+
+type completions []completion
+
+func (c completions) Len() int            { return len(c) }
+func (c completions) Less(i, j int) bool  { return c[i].at.Before(c[j].at) }
+func (c completions) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *completions) Push(x interface{}) { *c = append(*c, x.(completion)) }
+func (c *completions) Pop() interface{} {
+	old := *c
+	x := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return x
+}
+
+type simulator struct {
+	policy Policy
+	cache  *cache.Cache
+	queues []string
+	total  corev1.ResourceList
+	// free is what is left of the whole inventory, nodes what is left of
+	// each node. A gang only starts once each of its tasks fits on a node.
+	free    corev1.ResourceList
+	nodes   []corev1.ResourceList
+	now     time.Time
+	jobs    map[string]*job
+	pending completions
+	report  Report
+	// used integrates the resources in use over time, in units·seconds.
+	cpuUsed, memoryUsed float64
+}
+
+// Capacity returns the resources of each node of the inventory.
+func (inv *Inventory) Capacity() []corev1.ResourceList {
+	nodes := make([]corev1.ResourceList, 0, len(inv.Nodes))
+	for _, group := range inv.Nodes {
+		count := group.Count
+		if count <= 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			nodes = append(nodes, corev1.ResourceList{
+				corev1.ResourceCPU:    group.CPU,
+				corev1.ResourceMemory: group.Memory,
+			})
+		}
+	}
+	return nodes
+}
+
+// Validate checks the trace can be replayed.
+func Validate(trace []Submission) error {
+	names := make(map[string]bool)
+	for i, s := range trace {
+		if s.Name == "" {
+			return fmt.Errorf("submission %d has no name", i)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("submission %s is duplicated", s.Name)
+		}
+		names[s.Name] = true
+		if s.Arrival < 0 || s.Runtime < 0 {
+			return fmt.Errorf("submission %s has a negative arrival or runtime", s.Name)
+		}
+		if s.Tasks < 0 {
+			return fmt.Errorf("submission %s has negative tasks", s.Name)
+		}
+	}
+	return nil
+}
+
+// Run replays trace on the inventory under policy. Offlines waiting longer
+// than starvation are reported as starved.
+func Run(inv *Inventory, trace []Submission, policy Policy, starvation time.Duration) (*Report, error) {
+	if err := Validate(trace); err != nil {
+		return nil, err
+	}
+	s := &simulator{
+		policy: policy,
+		cache:  cache.NewCache(),
+		total:  corev1.ResourceList{},
+		nodes:  inv.Capacity(),
+		now:    epoch,
+		jobs:   make(map[string]*job),
+		report: Report{Policy: policy.Name, Submitted: len(trace)},
+	}
+	for _, node := range s.nodes {
+		utils.AddResources(s.total, node)
+	}
+	s.free = s.total.DeepCopy()
+	s.configure()
+
+	arrivals := make([]*job, 0, len(trace))
+	for i := range trace {
+		j := newJob(&trace[i])
+		s.jobs[j.off.Name] = j
+		arrivals = append(arrivals, j)
+	}
+	sort.SliceStable(arrivals, func(i, k int) bool {
+		return arrivals[i].arrival.Before(arrivals[k].arrival)
+	})
+
+	start := epoch
+	if len(arrivals) > 0 {
+		start = arrivals[0].arrival
+		s.now = start
+	}
+	for len(arrivals) > 0 || s.pending.Len() > 0 {
+		next := time.Time{}
+		if len(arrivals) > 0 {
+			next = arrivals[0].arrival
+		}
+		if s.pending.Len() > 0 && (next.IsZero() || s.pending[0].at.Before(next)) {
+			next = s.pending[0].at
+		}
+		s.advance(next)
+		for s.pending.Len() > 0 && !s.pending[0].at.After(s.now) {
+			s.complete(heap.Pop(&s.pending).(completion))
+		}
+		for len(arrivals) > 0 && !arrivals[0].arrival.After(s.now) {
+			s.submit(arrivals[0])
+			arrivals = arrivals[1:]
+		}
+		s.schedule()
+	}
+
+	s.summarize(start, starvation)
+	return &s.report, nil
+}
+
+func newJob(sub *Submission) *job {
+	tasks := sub.Tasks
+	if tasks <= 0 {
+		tasks = 1
+	}
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    sub.CPU,
+		corev1.ResourceMemory: sub.Memory,
+	}
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:      "task",
+				Resources: corev1.ResourceRequirements{Requests: requests},
+			}},
+		},
+	}
+	arrival := epoch.Add(seconds(sub.Arrival))
+	off := &v1.Offline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              sub.Name,
+			CreationTimestamp: metav1.NewTime(arrival),
+		},
+		Spec: v1.OfflineSpec{
+			Level:             sub.Level,
+			Queue:             sub.Queue,
+			MinGang:           tasks,
+			MaxRuntimeSeconds: sub.MaxRuntimeSeconds,
+		},
+	}
+	for i := int32(0); i < tasks; i++ {
+		off.Spec.Tasks = append(off.Spec.Tasks, template.DeepCopy())
+	}
+	return &job{
+		off:     off,
+		queue:   utils.GetOfflineQueueName(off),
+		reqs:    utils.OfflineRequests(off),
+		task:    requests,
+		arrival: arrival,
+		runtime: seconds(sub.Runtime),
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// configure sets the cache up the way the controller does from its flags.
+func (s *simulator) configure() {
+	var aging *cache.AgingPolicy
+	if s.policy.Aging != nil {
+		aging = &cache.AgingPolicy{
+			Interval: s.policy.Aging.Interval.Duration,
+			Step:     s.policy.Aging.Step,
+			Max:      s.policy.Aging.Max,
+		}
+	}
+	base := cache.Policy{
+		Backfill:             s.policy.Backfill,
+		Aging:                aging,
+		MaxAdmitted:          s.policy.MaxAdmitted,
+		MaxAdmittedResources: s.policy.MaxAdmittedResources,
+	}
+	s.cache.SetDefaultPolicy(base)
+	for name, q := range s.policy.Queues {
+		policy := base
+		policy.Weight = q.Weight
+		policy.Parent = q.Parent
+		s.cache.SetPolicy(name, policy)
+	}
+	s.cache.SetFairShare(s.policy.FairShare)
+}
+
+// advance moves the clock to t, accounting for the resources in use.
+func (s *simulator) advance(t time.Time) {
+	elapsed := t.Sub(s.now).Seconds()
+	if elapsed > 0 {
+		used := s.total.DeepCopy()
+		utils.SubResources(used, s.free)
+		cpu, memory := used[corev1.ResourceCPU], used[corev1.ResourceMemory]
+		s.cpuUsed += float64(cpu.MilliValue()) / 1000 * elapsed
+		s.memoryUsed += float64(memory.Value()) * elapsed
+		s.now = t
+	}
+}
+
+func (s *simulator) submit(j *job) {
+	if i := sort.SearchStrings(s.queues, j.queue); i == len(s.queues) || s.queues[i] != j.queue {
+		s.queues = append(s.queues, j.queue)
+		sort.Strings(s.queues)
+	}
+	s.cache.Get(j.queue).AddSchedulingQ(j.off)
+}
+
+// place returns the node each task of j fits on, first fit, given what is
+// left of the nodes. It is false when some task fits on no node.
+func place(j *job, nodes []corev1.ResourceList) ([]int, bool) {
+	free := make([]corev1.ResourceList, len(nodes))
+	for i := range nodes {
+		free[i] = nodes[i].DeepCopy()
+	}
+	placement := make([]int, 0, len(j.off.Spec.Tasks))
+	for range j.off.Spec.Tasks {
+		node := -1
+		for i := range free {
+			if utils.FitsResources(j.task, free[i]) {
+				node = i
+				break
+			}
+		}
+		if node < 0 {
+			return nil, false
+		}
+		utils.SubResources(free[node], j.task)
+		placement = append(placement, node)
+	}
+	return placement, true
+}
+
+// placeAfter is place once the running victims have released their nodes.
+func (s *simulator) placeAfter(j *job, victims []*v1.Offline) ([]int, bool) {
+	nodes := make([]corev1.ResourceList, len(s.nodes))
+	for i := range s.nodes {
+		nodes[i] = s.nodes[i].DeepCopy()
+	}
+	for _, victim := range victims {
+		for _, node := range s.jobs[victim.Name].placement {
+			utils.AddResources(nodes[node], s.jobs[victim.Name].task)
+		}
+	}
+	return place(j, nodes)
+}
+
+func (s *simulator) start(j *job, placement []int) {
+	now := metav1.NewTime(s.now)
+	j.off.Status.StartTime = &now
+	if j.started == nil {
+		started := s.now
+		j.started = &started
+	}
+	j.running = true
+	j.placement = placement
+	for _, node := range placement {
+		utils.SubResources(s.nodes[node], j.task)
+	}
+	utils.SubResources(s.free, j.reqs)
+	heap.Push(&s.pending, completion{at: s.now.Add(j.runtime), job: j, generation: j.generation})
+}
+
+// stop releases the resources of j and forgets it was backfilled.
+func (s *simulator) stop(j *job) {
+	j.running = false
+	j.generation++
+	j.off.Status.StartTime = nil
+	for _, node := range j.placement {
+		utils.AddResources(s.nodes[node], j.task)
+	}
+	j.placement = nil
+	utils.AddResources(s.free, j.reqs)
+	s.cache.Get(j.queue).Finish(j.off)
+}
+
+func (s *simulator) complete(c completion) {
+	if c.generation != c.job.generation || !c.job.running {
+		return
+	}
+	s.stop(c.job)
+	c.job.finished = true
+	s.report.Completed++
+}
+
+// preempt stops a running Offline and queues it again, to run from the
+// beginning once it is admitted again.
+func (s *simulator) preempt(j *job) {
+	s.stop(j)
+	s.report.Preemptions++
+	s.cache.Get(j.queue).AddSchedulingQ(j.off)
+}
+
+// schedule admits Offlines until no queue can make progress at the current
+// time.
+func (s *simulator) schedule() {
+	for progress := true; progress; {
+		progress = false
+		for _, name := range s.queues {
+			if s.scheduleQueue(s.cache.Get(name)) {
+				progress = true
+			}
+		}
+	}
+}
+
+// scheduleQueue follows the controller: waiting Offlines are aged, the
+// head is admitted while the admission window has room and an admitted
+// Offline leaves the window once each task of its gang fits on a node.
+// While the head of the window is blocked it may reclaim resources from
+// borrowing queues or let shorter Offlines backfill.
+func (s *simulator) scheduleQueue(q *cache.Queue) bool {
+	if aging := q.GetPolicy().Aging; aging != nil {
+		for _, off := range q.List() {
+			if priority := aging.EffectivePriority(off, s.now); off.Status.EffectivePriority == nil || priority != *off.Status.EffectivePriority {
+				off.Status.EffectivePriority = &priority
+				q.AddSchedulingQ(off)
+			}
+		}
+	}
+	for q.CanAdmit() {
+		if off, err := q.AdmitNext(); err != nil || off == nil {
+			break
+		}
+	}
+
+	progress := false
+	for _, off := range q.Admitted() {
+		if placement, ok := place(s.jobs[off.Name], s.nodes); ok {
+			s.start(s.jobs[off.Name], placement)
+			q.Release(off)
+			progress = true
+		}
+	}
+	if progress {
+		return true
+	}
+	if q.Head() == nil {
+		return false
+	}
+	head := s.jobs[q.Head().Name]
+
+	if s.cache.FairShareEnabled() {
+		victims := cache.Reclaim(q.GetName(), head.reqs, s.free, s.admitted(head), s.deserved(head))
+		if placement, ok := s.placeAfter(head, victims); len(victims) > 0 && ok {
+			for _, victim := range victims {
+				s.preempt(s.jobs[victim.Name])
+			}
+			s.start(head, placement)
+			q.Release(head.off)
+			return true
+		}
+	}
+
+	if q.GetPolicy().Backfill {
+		wait := cache.EstimateWait(head.off, s.free, s.running(), s.now)
+		for _, off := range q.Backfill(s.free, wait) {
+			placement, ok := place(s.jobs[off.Name], s.nodes)
+			if !ok {
+				// enough is free in total but not on the nodes, it waits again
+				q.Finish(off)
+				q.AddSchedulingQ(off)
+				continue
+			}
+			s.start(s.jobs[off.Name], placement)
+			s.report.Backfilled++
+			progress = true
+		}
+	}
+	return progress
+}
+
+func (s *simulator) running() []*v1.Offline {
+	offs := make([]*v1.Offline, 0)
+	for _, j := range s.jobs {
+		if j.running {
+			offs = append(offs, j.off)
+		}
+	}
+	return offs
+}
+
+// admitted groups the running Offlines by queue, leaving out head.
+func (s *simulator) admitted(head *job) map[string][]*v1.Offline {
+	admitted := make(map[string][]*v1.Offline)
+	for _, j := range s.jobs {
+		if j.running && j != head {
+			admitted[j.queue] = append(admitted[j.queue], j.off)
+		}
+	}
+	return admitted
+}
+
+// deserved divides the inventory among the queues by what their admitted
+// and waiting Offlines demand.
+func (s *simulator) deserved(head *job) map[string]corev1.ResourceList {
+	demand := make(map[string]corev1.ResourceList)
+	add := func(name string, reqs corev1.ResourceList) {
+		if _, exist := demand[name]; !exist {
+			demand[name] = corev1.ResourceList{}
+		}
+		utils.AddResources(demand[name], reqs)
+	}
+	for _, j := range s.jobs {
+		if j.running && j != head {
+			add(j.queue, j.reqs)
+		}
+	}
+	add(head.queue, head.reqs)
+	for _, name := range s.queues {
+		q := s.cache.Get(name)
+		for _, off := range append(q.List(), q.Admitted()...) {
+			if off.Name != head.off.Name {
+				add(name, s.jobs[off.Name].reqs)
+			}
+		}
+	}
+	return s.cache.FairShare(s.total, demand)
+}
+
+func (s *simulator) summarize(start time.Time, starvation time.Duration) {
+	waits := make([]time.Duration, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if j.started == nil {
+			s.report.Unfinished++
+			if s.now.Sub(j.arrival) > starvation {
+				s.report.Starved++
+			}
+			continue
+		}
+		wait := j.started.Sub(j.arrival)
+		if wait > starvation {
+			s.report.Starved++
+		}
+		waits = append(waits, wait)
+	}
+	sort.Slice(waits, func(i, k int) bool { return waits[i] < waits[k] })
+	s.report.WaitP50 = percentile(waits, 50)
+	s.report.WaitP90 = percentile(waits, 90)
+	s.report.WaitP99 = percentile(waits, 99)
+	s.report.WaitMax = percentile(waits, 100)
+
+	s.report.Makespan = s.now.Sub(start)
+	if span := s.report.Makespan.Seconds(); span > 0 {
+		cpu, memory := s.total[corev1.ResourceCPU], s.total[corev1.ResourceMemory]
+		if cores := float64(cpu.MilliValue()) / 1000; cores > 0 {
+			s.report.CPUUtilisation = s.cpuUsed / (cores * span)
+		}
+		if bytes := float64(memory.Value()); bytes > 0 {
+			s.report.MemoryUtilisation = s.memoryUsed / (bytes * span)
+		}
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted waits.
+func percentile(waits []time.Duration, p float64) time.Duration {
+	if len(waits) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(waits))))
+	if rank < 1 {
+		rank = 1
+	}
+	return waits[rank-1]
+}
@@ -0,0 +1,113 @@
+package simulate
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func submission(name string, arrival float64, tasks int32, cpu string, runtime float64) Submission {
+	return Submission{
+		Name:    name,
+		Arrival: arrival,
+		Tasks:   tasks,
+		CPU:     resource.MustParse(cpu),
+		Memory:  resource.MustParse("1Gi"),
+		Runtime: runtime,
+	}
+}
+
+var _ = Describe("Simulator", func() {
+	var inv *Inventory
+
+	BeforeEach(func() {
+		inv = &Inventory{Nodes: []NodeGroup{{
+			Name:   "worker",
+			Count:  2,
+			CPU:    resource.MustParse("4"),
+			Memory: resource.MustParse("16Gi"),
+		}}}
+	})
+
+	It("lists every node of the inventory", func() {
+		Expect(inv.Capacity()).To(HaveLen(2))
+	})
+
+	It("starts a gang only once each task fits on a node", func() {
+		trace := []Submission{
+			submission("spread", 0, 2, "3", 100),
+			submission("too-wide", 10, 1, "6", 100),
+		}
+		report, err := Run(inv, trace, Policy{Name: "fifo"}, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Completed).To(Equal(1))
+		Expect(report.Unfinished).To(Equal(1))
+	})
+
+	It("waits for a node to free up rather than splitting a task", func() {
+		trace := []Submission{
+			submission("first", 0, 2, "3", 100),
+			submission("second", 10, 1, "2", 50),
+		}
+		report, err := Run(inv, trace, Policy{Name: "fifo"}, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Completed).To(Equal(2))
+		Expect(report.WaitMax).To(Equal(90 * time.Second))
+		Expect(report.Makespan).To(Equal(150 * time.Second))
+	})
+
+	It("places several tasks on a node with room for them", func() {
+		trace := []Submission{
+			submission("first", 0, 1, "3", 100),
+			submission("second", 0, 2, "2", 100),
+		}
+		report, err := Run(inv, trace, Policy{Name: "fifo"}, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Completed).To(Equal(2))
+		Expect(report.WaitMax).To(BeZero())
+	})
+
+	It("waits while the capacity left is split across nodes", func() {
+		trace := []Submission{
+			submission("first", 0, 1, "3", 100),
+			submission("second", 0, 2, "2500m", 100),
+		}
+		report, err := Run(inv, trace, Policy{Name: "fifo"}, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Completed).To(Equal(2))
+		Expect(report.WaitMax).To(Equal(100 * time.Second))
+	})
+
+	It("doesn't backfill an Offline its nodes have no room for", func() {
+		short := int64(60)
+		trace := []Submission{
+			submission("running", 0, 2, "3", 100),
+			submission("head", 10, 2, "4", 100),
+			submission("backfill", 20, 1, "2", 50),
+		}
+		trace[0].MaxRuntimeSeconds = &short
+		trace[2].MaxRuntimeSeconds = &short
+		report, err := Run(inv, trace, Policy{Name: "backfill", Backfill: true}, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Completed).To(Equal(3))
+		Expect(report.Backfilled).To(BeZero())
+	})
+
+	It("rejects a trace with duplicated names", func() {
+		trace := []Submission{submission("a", 0, 1, "1", 1), submission("a", 1, 1, "1", 1)}
+		_, err := Run(inv, trace, Policy{Name: "fifo"}, time.Hour)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads a trace from CSV", func() {
+		trace, err := parseCSV(strings.NewReader("name,arrival,tasks,cpu,memory,runtime,maxRuntimeSeconds\na,1.5,2,500m,1Gi,60,90\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(trace).To(HaveLen(1))
+		Expect(trace[0].Arrival).To(Equal(1.5))
+		Expect(trace[0].Tasks).To(Equal(int32(2)))
+		Expect(*trace[0].MaxRuntimeSeconds).To(Equal(int64(90)))
+	})
+})
@@ -0,0 +1,207 @@
+package simulate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// NodeGroup is Count identical nodes.
+type NodeGroup struct {
+	Name   string            `json:"name"`
+	Count  int               `json:"count,omitempty"`
+	CPU    resource.Quantity `json:"cpu"`
+	Memory resource.Quantity `json:"memory"`
+}
+
+// Inventory is the capacity offlines are simulated on.
+type Inventory struct {
+	Nodes []NodeGroup `json:"nodes"`
+}
+
+// Submission is an Offline arriving in the trace. Every task requests CPU
+// and Memory and the gang runs for Runtime once all its tasks fit.
+type Submission struct {
+	Name  string `json:"name"`
+	Queue string `json:"queue,omitempty"`
+	Level int32  `json:"level,omitempty"`
+	// Arrival is the time of submission in seconds since the start.
+	Arrival float64 `json:"arrival"`
+	// Tasks defaults to 1.
+	Tasks  int32             `json:"tasks,omitempty"`
+	CPU    resource.Quantity `json:"cpu"`
+	Memory resource.Quantity `json:"memory"`
+	// Runtime is how long the gang actually runs in seconds.
+	Runtime float64 `json:"runtime"`
+	// MaxRuntimeSeconds is the runtime declared to the scheduler, which
+	// makes the Offline a candidate for backfill.
+	MaxRuntimeSeconds *int64 `json:"maxRuntimeSeconds,omitempty"`
+}
+
+// AgingPolicy mirrors cache.AgingPolicy in a file.
+type AgingPolicy struct {
+	Interval metav1.Duration `json:"interval"`
+	Step     int32           `json:"step"`
+	Max      int32           `json:"max"`
+}
+
+// QueuePolicy is the fair share weight and parent of a queue.
+type QueuePolicy struct {
+	Weight int32  `json:"weight,omitempty"`
+	Parent string `json:"parent,omitempty"`
+}
+
+// Policy is a configuration of the controller the trace is replayed with.
+type Policy struct {
+	Name      string                 `json:"name"`
+	Backfill  bool                   `json:"backfill,omitempty"`
+	Aging     *AgingPolicy           `json:"aging,omitempty"`
+	FairShare bool                   `json:"fairShare,omitempty"`
+	Queues    map[string]QueuePolicy `json:"queues,omitempty"`
+	// MaxAdmitted and MaxAdmittedResources bound the admission window of
+	// every queue.
+	MaxAdmitted          int32               `json:"maxAdmitted,omitempty"`
+	MaxAdmittedResources corev1.ResourceList `json:"maxAdmittedResources,omitempty"`
+}
+
+// DefaultPolicies compares the scheduling options with each other.
+func DefaultPolicies() []Policy {
+	aging := &AgingPolicy{Interval: metav1.Duration{Duration: 10 * time.Minute}, Step: 1, Max: 10}
+	return []Policy{
+		{Name: "fifo"},
+		{Name: "backfill", Backfill: true},
+		{Name: "aging", Aging: aging},
+		{Name: "fair-share", FairShare: true},
+		{Name: "backfill+aging", Backfill: true, Aging: aging},
+		{Name: "window", MaxAdmitted: 4},
+	}
+}
+
+// LoadInventory reads an Inventory in YAML or JSON.
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	inv := &Inventory{}
+	if err := yaml.UnmarshalStrict(data, inv); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return inv, nil
+}
+
+// LoadPolicies reads a list of Policies in YAML or JSON.
+func LoadPolicies(path string) ([]Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]Policy, 0)
+	if err := yaml.UnmarshalStrict(data, &policies); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return policies, nil
+}
+
+// LoadTrace reads Submissions from a CSV file, when its extension is
+// .csv, or else from a list in YAML or JSON.
+func LoadTrace(path string) ([]Submission, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var trace []Submission
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		trace, err = parseCSV(bytes.NewReader(data))
+	} else {
+		err = yaml.UnmarshalStrict(data, &trace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return trace, nil
+}
+
+// parseCSV reads Submissions from CSV with a header naming the columns:
+// name, queue, level, arrival, tasks, cpu, memory, runtime and
+// maxRuntimeSeconds. name, arrival, cpu, memory and runtime are required.
+func parseCSV(r io.Reader) ([]Submission, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"name", "arrival", "cpu", "memory", "runtime"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column %s is missing", name)
+		}
+	}
+
+	trace := make([]Submission, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return trace, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		s := Submission{Name: field("name"), Queue: field("queue")}
+		if s.Arrival, err = strconv.ParseFloat(field("arrival"), 64); err != nil {
+			return nil, fmt.Errorf("line %d: arrival: %v", line, err)
+		}
+		if s.Runtime, err = strconv.ParseFloat(field("runtime"), 64); err != nil {
+			return nil, fmt.Errorf("line %d: runtime: %v", line, err)
+		}
+		if s.CPU, err = resource.ParseQuantity(field("cpu")); err != nil {
+			return nil, fmt.Errorf("line %d: cpu: %v", line, err)
+		}
+		if s.Memory, err = resource.ParseQuantity(field("memory")); err != nil {
+			return nil, fmt.Errorf("line %d: memory: %v", line, err)
+		}
+		if v := field("level"); v != "" {
+			level, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: level: %v", line, err)
+			}
+			s.Level = int32(level)
+		}
+		if v := field("tasks"); v != "" {
+			tasks, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: tasks: %v", line, err)
+			}
+			s.Tasks = int32(tasks)
+		}
+		if v := field("maxRuntimeSeconds"); v != "" {
+			max, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: maxRuntimeSeconds: %v", line, err)
+			}
+			s.MaxRuntimeSeconds = &max
+		}
+		trace = append(trace, s)
+	}
+}
@@ -0,0 +1,248 @@
+// Package topology places the pods of an Offline's gang relative to each
+// other according to its spec.topology, through pod affinity for the
+// default scheduler and through domain checks for the extender and for
+// admission.
+package topology
+
+import (
+	"sort"
+
+	"github.com/YunWang/colocation/api/v1"
+	"github.com/YunWang/colocation/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PreferredWeight is the weight of the affinity term of a policy that isn't
+// required.
+const PreferredWeight = 100
+
+// Key returns the node label whose values are the domains of policy.
+func Key(policy *v1.TopologyPolicy) string {
+	if policy.Mode == v1.TopologySameNode {
+		return corev1.LabelHostname
+	}
+	if policy.TopologyKey == "" {
+		return corev1.LabelZoneFailureDomain
+	}
+	return policy.TopologyKey
+}
+
+// Domain returns the domain of node under policy, false when the node lacks
+// the label.
+func Domain(policy *v1.TopologyPolicy, node *corev1.Node) (string, bool) {
+	if policy.Mode == v1.TopologySameNode {
+		return node.Name, true
+	}
+	domain, ok := node.Labels[Key(policy)]
+	return domain, ok
+}
+
+// Affinity returns the pod affinity, for SameNode and Pack, or anti-affinity,
+// for Spread, that makes the default scheduler place the pods of off
+// according to its topology policy. It is nil without a policy.
+func Affinity(off *v1.Offline) *corev1.Affinity {
+	policy := off.Spec.Topology
+	if policy == nil {
+		return nil
+	}
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{v1.PodGroupLabel: off.Name},
+		},
+		Namespaces:  []string{off.Namespace},
+		TopologyKey: Key(policy),
+	}
+	var required []corev1.PodAffinityTerm
+	var preferred []corev1.WeightedPodAffinityTerm
+	if policy.Required {
+		required = []corev1.PodAffinityTerm{term}
+	} else {
+		preferred = []corev1.WeightedPodAffinityTerm{{Weight: PreferredWeight, PodAffinityTerm: term}}
+	}
+	switch policy.Mode {
+	case v1.TopologySameNode, v1.TopologyPack:
+		return &corev1.Affinity{PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  required,
+			PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+		}}
+	case v1.TopologySpread:
+		return &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  required,
+			PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+		}}
+	}
+	return nil
+}
+
+// Apply adds the affinity of off's topology policy to the pod spec, keeping
+// the terms its template already has.
+func Apply(off *v1.Offline, spec *corev1.PodSpec) {
+	affinity := Affinity(off)
+	if affinity == nil {
+		return
+	}
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	if a := affinity.PodAffinity; a != nil {
+		if spec.Affinity.PodAffinity == nil {
+			spec.Affinity.PodAffinity = &corev1.PodAffinity{}
+		}
+		p := spec.Affinity.PodAffinity
+		p.RequiredDuringSchedulingIgnoredDuringExecution = append(p.RequiredDuringSchedulingIgnoredDuringExecution, a.RequiredDuringSchedulingIgnoredDuringExecution...)
+		p.PreferredDuringSchedulingIgnoredDuringExecution = append(p.PreferredDuringSchedulingIgnoredDuringExecution, a.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+	if a := affinity.PodAntiAffinity; a != nil {
+		if spec.Affinity.PodAntiAffinity == nil {
+			spec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		p := spec.Affinity.PodAntiAffinity
+		p.RequiredDuringSchedulingIgnoredDuringExecution = append(p.RequiredDuringSchedulingIgnoredDuringExecution, a.RequiredDuringSchedulingIgnoredDuringExecution...)
+		p.PreferredDuringSchedulingIgnoredDuringExecution = append(p.PreferredDuringSchedulingIgnoredDuringExecution, a.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+}
+
+// gangRequests returns the requests of each pod of the gang of off, largest
+// cpu first.
+func gangRequests(off *v1.Offline) []corev1.ResourceList {
+	reqs := make([]corev1.ResourceList, 0)
+	for _, task := range off.Spec.Tasks {
+		reqs = append(reqs, utils.PodRequests(&task.Spec))
+	}
+	for _, task := range off.Spec.Elastic {
+		for i := int32(0); i < task.MinReplicas; i++ {
+			reqs = append(reqs, utils.PodRequests(&task.Template.Spec))
+		}
+	}
+	sort.SliceStable(reqs, func(i, j int) bool {
+		a, b := reqs[i][corev1.ResourceCPU], reqs[j][corev1.ResourceCPU]
+		return a.Cmp(b) > 0
+	})
+	return reqs
+}
+
+// domains groups the schedulable nodes by their domain under policy.
+func domains(policy *v1.TopologyPolicy, nodes []corev1.Node) map[string][]*corev1.Node {
+	grouped := make(map[string][]*corev1.Node)
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Spec.Unschedulable {
+			continue
+		}
+		if domain, ok := Domain(policy, node); ok {
+			grouped[domain] = append(grouped[domain], node)
+		}
+	}
+	return grouped
+}
+
+// pack reports whether every pod of reqs fits the allocatable resources of
+// nodes, placing them first fit.
+func pack(reqs []corev1.ResourceList, nodes []*corev1.Node) bool {
+	free := make([]corev1.ResourceList, len(nodes))
+	for i, node := range nodes {
+		free[i] = node.Status.Allocatable.DeepCopy()
+	}
+	for _, req := range reqs {
+		placed := false
+		for i := range free {
+			if utils.FitsResources(req, free[i]) {
+				utils.SubResources(free[i], req)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			return false
+		}
+	}
+	return true
+}
+
+// Feasible reports whether the gang of off could be placed on nodes
+// according to its topology policy were the nodes empty. It is true when
+// the policy isn't required, so admission only holds back Offlines that
+// can't run as they ask to on the nodes there are.
+func Feasible(off *v1.Offline, nodes []corev1.Node) bool {
+	policy := off.Spec.Topology
+	if policy == nil || !policy.Required {
+		return true
+	}
+	reqs := gangRequests(off)
+	grouped := domains(policy, nodes)
+	switch policy.Mode {
+	case v1.TopologySameNode, v1.TopologyPack:
+		for _, domain := range grouped {
+			if pack(reqs, domain) {
+				return true
+			}
+		}
+		return false
+	case v1.TopologySpread:
+		return spread(reqs, grouped)
+	}
+	return true
+}
+
+// spread reports whether every pod of reqs can go to a domain of its own
+// it fits in, matching pods to domains by augmenting paths so that a pod
+// taking a domain another pod needs more moves on to the next one.
+func spread(reqs []corev1.ResourceList, grouped map[string][]*corev1.Node) bool {
+	if len(reqs) > len(grouped) {
+		return false
+	}
+	names := make([]string, 0, len(grouped))
+	for name := range grouped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fits := make([][]int, len(reqs))
+	for i, req := range reqs {
+		for j, name := range names {
+			if pack([]corev1.ResourceList{req}, grouped[name]) {
+				fits[i] = append(fits[i], j)
+			}
+		}
+	}
+	owner := make([]int, len(names))
+	for j := range owner {
+		owner[j] = -1
+	}
+	var assign func(pod int, seen []bool) bool
+	assign = func(pod int, seen []bool) bool {
+		for _, domain := range fits[pod] {
+			if seen[domain] {
+				continue
+			}
+			seen[domain] = true
+			if owner[domain] < 0 || assign(owner[domain], seen) {
+				owner[domain] = pod
+				return true
+			}
+		}
+		return false
+	}
+	for pod := range reqs {
+		if !assign(pod, make([]bool, len(names))) {
+			return false
+		}
+	}
+	return true
+}
+
+// Allowed reports whether a pod of the gang may go to a node in domain
+// given the domains the pods of the gang already placed are in. Only a
+// required policy disallows anything.
+func Allowed(policy *v1.TopologyPolicy, domain string, placed map[string]int) bool {
+	if policy == nil || !policy.Required {
+		return true
+	}
+	switch policy.Mode {
+	case v1.TopologySameNode, v1.TopologyPack:
+		return len(placed) == 0 || placed[domain] > 0
+	case v1.TopologySpread:
+		return placed[domain] == 0
+	}
+	return true
+}
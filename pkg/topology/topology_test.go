@@ -0,0 +1,173 @@
+package topology
+
+import (
+	"github.com/YunWang/colocation/api/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const zoneKey = "zone"
+
+func newNode(name, zone, cpu, memory string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{zoneKey: zone}},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}},
+	}
+}
+
+func newTask(cpu, memory string) *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name: "task",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			}},
+		}},
+	}}
+}
+
+func newOffline(mode v1.TopologyMode, required bool, tasks ...*corev1.PodTemplateSpec) *v1.Offline {
+	return &v1.Offline{
+		ObjectMeta: metav1.ObjectMeta{Name: "gang", Namespace: "default"},
+		Spec: v1.OfflineSpec{
+			Tasks:    tasks,
+			Topology: &v1.TopologyPolicy{Mode: mode, TopologyKey: zoneKey, Required: required},
+		},
+	}
+}
+
+var _ = Describe("Feasible", func() {
+	var nodes []corev1.Node
+
+	BeforeEach(func() {
+		nodes = []corev1.Node{
+			newNode("a-1", "a", "4", "16Gi"),
+			newNode("a-2", "a", "4", "16Gi"),
+			newNode("b-1", "b", "4", "2Gi"),
+		}
+	})
+
+	It("packs the gang in a domain with room for all of it", func() {
+		off := newOffline(v1.TopologyPack, true, newTask("3", "1Gi"), newTask("3", "1Gi"))
+		Expect(Feasible(off, nodes)).To(BeTrue())
+
+		off.Spec.Tasks = append(off.Spec.Tasks, newTask("3", "1Gi"))
+		Expect(Feasible(off, nodes)).To(BeFalse())
+	})
+
+	It("needs a single node for SameNode", func() {
+		off := newOffline(v1.TopologySameNode, true, newTask("3", "1Gi"), newTask("3", "1Gi"))
+		Expect(Feasible(off, nodes)).To(BeFalse())
+
+		off.Spec.Tasks = []*corev1.PodTemplateSpec{newTask("2", "1Gi"), newTask("2", "1Gi")}
+		Expect(Feasible(off, nodes)).To(BeTrue())
+	})
+
+	It("spreads the gang over as many domains as it has pods", func() {
+		off := newOffline(v1.TopologySpread, true, newTask("1", "1Gi"), newTask("1", "1Gi"), newTask("1", "1Gi"))
+		Expect(Feasible(off, nodes)).To(BeFalse())
+
+		off.Spec.Tasks = off.Spec.Tasks[:2]
+		Expect(Feasible(off, nodes)).To(BeTrue())
+	})
+
+	It("gives each pod of a spread gang a domain it fits in", func() {
+		// the largest pod fits both domains, the other only the first
+		off := newOffline(v1.TopologySpread, true, newTask("2", "1Gi"), newTask("1", "8Gi"))
+		Expect(Feasible(off, nodes)).To(BeTrue())
+	})
+
+	It("leaves out unschedulable nodes", func() {
+		nodes[0].Spec.Unschedulable = true
+		off := newOffline(v1.TopologyPack, true, newTask("3", "1Gi"), newTask("3", "1Gi"))
+		Expect(Feasible(off, nodes)).To(BeFalse())
+	})
+
+	It("is always true for a policy that isn't required", func() {
+		off := newOffline(v1.TopologySpread, false, newTask("1", "1Gi"), newTask("1", "1Gi"), newTask("1", "1Gi"))
+		Expect(Feasible(off, nodes)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Allowed", func() {
+	It("keeps a packed gang in the domain its pods are in", func() {
+		policy := &v1.TopologyPolicy{Mode: v1.TopologyPack, Required: true}
+		Expect(Allowed(policy, "a", nil)).To(BeTrue())
+		Expect(Allowed(policy, "a", map[string]int{"a": 1})).To(BeTrue())
+		Expect(Allowed(policy, "b", map[string]int{"a": 1})).To(BeFalse())
+	})
+
+	It("keeps the pods of a spread gang out of each other's domain", func() {
+		policy := &v1.TopologyPolicy{Mode: v1.TopologySpread, Required: true}
+		Expect(Allowed(policy, "a", map[string]int{"a": 1})).To(BeFalse())
+		Expect(Allowed(policy, "b", map[string]int{"a": 1})).To(BeTrue())
+	})
+
+	It("allows any domain for a preference", func() {
+		policy := &v1.TopologyPolicy{Mode: v1.TopologySpread}
+		Expect(Allowed(policy, "a", map[string]int{"a": 1})).To(BeTrue())
+		Expect(Allowed(nil, "a", map[string]int{"a": 1})).To(BeTrue())
+	})
+})
+
+var _ = Describe("Apply", func() {
+	It("requires the pods of a gang to be packed by the policy key", func() {
+		off := newOffline(v1.TopologyPack, true, newTask("1", "1Gi"))
+		spec := &corev1.PodSpec{}
+		Apply(off, spec)
+
+		terms := spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		Expect(terms).To(HaveLen(1))
+		Expect(terms[0].TopologyKey).To(Equal(zoneKey))
+		Expect(terms[0].Namespaces).To(Equal([]string{"default"}))
+		Expect(terms[0].LabelSelector.MatchLabels).To(HaveKeyWithValue(v1.PodGroupLabel, "gang"))
+		Expect(spec.Affinity.PodAntiAffinity).To(BeNil())
+	})
+
+	It("prefers keeping the pods of a spread gang apart", func() {
+		off := newOffline(v1.TopologySpread, false, newTask("1", "1Gi"))
+		spec := &corev1.PodSpec{}
+		Apply(off, spec)
+
+		terms := spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+		Expect(terms).To(HaveLen(1))
+		Expect(terms[0].Weight).To(BeEquivalentTo(PreferredWeight))
+		Expect(terms[0].PodAffinityTerm.TopologyKey).To(Equal(zoneKey))
+	})
+
+	It("uses the hostname label for SameNode", func() {
+		off := newOffline(v1.TopologySameNode, true, newTask("1", "1Gi"))
+		spec := &corev1.PodSpec{}
+		Apply(off, spec)
+
+		Expect(spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey).To(Equal(corev1.LabelHostname))
+	})
+
+	It("keeps the affinity the template has", func() {
+		off := newOffline(v1.TopologyPack, true, newTask("1", "1Gi"))
+		own := corev1.PodAffinityTerm{TopologyKey: "rack"}
+		spec := &corev1.PodSpec{Affinity: &corev1.Affinity{PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{own},
+		}}}
+		Apply(off, spec)
+
+		terms := spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		Expect(terms).To(HaveLen(2))
+		Expect(terms[0]).To(Equal(own))
+	})
+
+	It("leaves the pods of an Offline without a policy alone", func() {
+		off := newOffline(v1.TopologyPack, true)
+		off.Spec.Topology = nil
+		spec := &corev1.PodSpec{}
+		Apply(off, spec)
+		Expect(spec.Affinity).To(BeNil())
+	})
+})
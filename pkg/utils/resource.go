@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"github.com/YunWang/colocation/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodRequests returns the resources a pod asks for, which is the larger of
+// the sum of its containers' requests and any single init container's.
+func PodRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	reqs := corev1.ResourceList{}
+	for _, c := range spec.Containers {
+		AddResources(reqs, c.Resources.Requests)
+	}
+	for _, c := range spec.InitContainers {
+		for name, quantity := range c.Resources.Requests {
+			if value, ok := reqs[name]; !ok || quantity.Cmp(value) > 0 {
+				reqs[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return reqs
+}
+
+// OfflineRequests returns the resources needed to run every task of an
+// Offline, with elastic tasks at their minimum size.
+func OfflineRequests(off *v1.Offline) corev1.ResourceList {
+	reqs := corev1.ResourceList{}
+	for _, task := range off.Spec.Tasks {
+		AddResources(reqs, PodRequests(&task.Spec))
+	}
+	for _, task := range off.Spec.Elastic {
+		podReqs := PodRequests(&task.Template.Spec)
+		for i := int32(0); i < task.MinReplicas; i++ {
+			AddResources(reqs, podReqs)
+		}
+	}
+	return reqs
+}
+
+// AddResources adds every quantity of delta to total.
+func AddResources(total, delta corev1.ResourceList) {
+	for name, quantity := range delta {
+		value := total[name]
+		value.Add(quantity)
+		total[name] = value
+	}
+}
+
+// SubResources subtracts every quantity of delta from total.
+func SubResources(total, delta corev1.ResourceList) {
+	for name, quantity := range delta {
+		value := total[name]
+		value.Sub(quantity)
+		total[name] = value
+	}
+}
+
+// FitsResources reports whether every quantity of reqs is available in free.
+func FitsResources(reqs, free corev1.ResourceList) bool {
+	for name, quantity := range reqs {
+		value, ok := free[name]
+		if !ok {
+			if quantity.IsZero() {
+				continue
+			}
+			return false
+		}
+		if quantity.Cmp(value) > 0 {
+			return false
+		}
+	}
+	return true
+}
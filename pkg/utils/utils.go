@@ -6,22 +6,34 @@ import (
 )
 
 func LessFn(offline1, offline2 interface{}) bool {
-	o1 := offline1.(v1.Offline)
-	o2 := offline2.(v1.Offline)
-	prio1 := o1.Spec.Level
-	prio2 := o2.Spec.Level
+	o1 := offline1.(*v1.Offline)
+	o2 := offline2.(*v1.Offline)
+	prio1 := Priority(o1)
+	prio2 := Priority(o2)
 	return (prio1 > prio2) || (prio1 == prio2 && o1.ObjectMeta.CreationTimestamp.Before(&o2.ObjectMeta.CreationTimestamp))
 }
 
+// Priority returns the priority an Offline is ordered by, which is its Level
+// until aging has set its EffectivePriority.
+func Priority(off *v1.Offline) int32 {
+	if off.Status.EffectivePriority != nil {
+		return *off.Status.EffectivePriority
+	}
+	return off.Spec.Level
+}
+
 func KeyFn(obj interface{}) (string, error) {
 	offline:=obj.(*v1.Offline)
 	return types.NamespacedName{Namespace:offline.Namespace,Name:offline.Name}.String(), nil
 }
 
+// DefaultQueue is the queue of Offlines that don't name one.
+var DefaultQueue = "default"
+
 func GetOfflineQueueName(off *v1.Offline)string{
 	queueName:=off.Spec.Queue
 	if queueName ==""{
-		queueName="default"
+		queueName=DefaultQueue
 	}
 	return queueName
 }